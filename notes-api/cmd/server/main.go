@@ -1,14 +1,17 @@
 package main
 
 import (
+	"context"
 	"log"
 	"net/http"
 
+	"github.com/icestormerrr/notes-api/internal/core"
 	router "github.com/icestormerrr/notes-api/internal/delivery/http"
 	"github.com/icestormerrr/notes-api/internal/delivery/http/handlers"
 	"github.com/icestormerrr/notes-api/internal/repos"
 	"github.com/icestormerrr/notes-api/internal/services"
 	"github.com/icestormerrr/notes-api/internal/utils/config"
+	"github.com/icestormerrr/notes-api/internal/utils/jwt"
 )
 
 // Package main Notes API server.
@@ -19,14 +22,98 @@ import (
 // @contact.name    Backend Course
 // @contact.email   yurkin.v.i@edu.mirea.ru
 // @BasePath        /api/v1
+//
+// @securityDefinitions.apikey BearerAuth
+// @in                         header
+// @name                       Authorization
+// @description                Access токен в формате "Bearer <token>"
 func main() {
 	cfg := config.Load()
 
-	notesInmemoryRepo := repos.NewNoteRepoMem()
-	notesService := services.NewNotesService(notesInmemoryRepo)
+	notesRepo, closeRepo, err := newNotesRepo(cfg)
+	if err != nil {
+		log.Fatalf("failed to init %s notes repository: %v", cfg.DBDriver, err)
+	}
+	defer closeRepo()
+
+	blobs, err := newBlobStore(cfg)
+	if err != nil {
+		log.Fatalf("failed to init %s blob store: %v", cfg.BlobStore, err)
+	}
+
+	notesService := services.NewNotesService(notesRepo, notesRepo, blobs)
 	notesHandler := handlers.NewNoteHandler(notesService)
-	mux := router.Build(notesHandler)
 
-	log.Println("listening on", cfg.Port)
+	attachmentsService := services.NewAttachmentsService(services.AttachmentsConfig{
+		MaxSize:      cfg.AttachmentsMaxSize,
+		AllowedTypes: cfg.AttachmentsAllowedTypes,
+	}, notesRepo, notesRepo, blobs)
+	attachmentsHandler := handlers.NewAttachmentHandler(attachmentsService, cfg.AttachmentsMaxSize)
+
+	verifier, err := jwt.NewVerifier(jwt.VerifierConfig{
+		Secret:   cfg.JWTSecret,
+		JWKSURL:  cfg.JWKSURL,
+		Issuer:   cfg.JWTIssuer,
+		Audience: cfg.JWTAudience,
+	})
+	if err != nil {
+		log.Fatalf("failed to init token verifier: %v", err)
+	}
+
+	mux := router.Build(notesHandler, attachmentsHandler, verifier)
+
+	log.Println("listening on", cfg.Port, "storage:", cfg.DBDriver)
 	log.Fatal(http.ListenAndServe(cfg.Port, mux))
 }
+
+// notesStorage заметки и метаданные вложений хранятся в одной базе
+type notesStorage interface {
+	core.NotesRepository
+	core.AttachmentsRepository
+}
+
+// newNotesRepo создает репозиторий notes по DB_DRIVER
+func newNotesRepo(cfg config.Config) (notesStorage, func() error, error) {
+	var (
+		repo *repos.NotesSQLRepo
+		err  error
+	)
+	switch cfg.DBDriver {
+	case config.DriverPostgres:
+		repo, err = repos.NewNotesPostgresRepo(cfg.DatabaseURL)
+	case config.DriverSQLite:
+		repo, err = repos.NewNotesSQLiteRepo(cfg.SQLitePath)
+	default:
+		return repos.NewNoteRepoMem(), func() error { return nil }, nil
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if cfg.LegacyNotesOwnerID != 0 {
+		assigned, err := repo.AssignLegacyNotes(context.Background(), cfg.LegacyNotesOwnerID)
+		if err != nil {
+			repo.Close()
+			return nil, nil, err
+		}
+		if assigned > 0 {
+			log.Printf("assigned %d legacy notes to user %d", assigned, cfg.LegacyNotesOwnerID)
+		}
+	}
+	return repo, repo.Close, nil
+}
+
+// newBlobStore создает хранилище содержимого вложений по BLOB_STORE
+func newBlobStore(cfg config.Config) (core.BlobStore, error) {
+	if cfg.BlobStore == config.BlobStoreS3 {
+		return repos.NewS3BlobStore(context.Background(), repos.S3BlobStoreConfig{
+			Endpoint:  cfg.S3Endpoint,
+			Region:    cfg.S3Region,
+			Bucket:    cfg.S3Bucket,
+			AccessKey: cfg.S3AccessKey,
+			SecretKey: cfg.S3SecretKey,
+			UseSSL:    cfg.S3UseSSL,
+		})
+	}
+	return repos.NewLocalBlobStore(cfg.BlobDir)
+}
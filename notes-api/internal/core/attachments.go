@@ -0,0 +1,50 @@
+package core
+
+import (
+	"context"
+	"io"
+	"time"
+)
+
+// Attachment метаданные файла, прикреплённого к заметке. Содержимое хранится в BlobStore под ключом BlobKey
+type Attachment struct {
+	ID          int64     `json:"id"`
+	NoteID      int64     `json:"note_id"`
+	Filename    string    `json:"filename"`
+	ContentType string    `json:"content_type"`
+	Size        int64     `json:"size"`
+	BlobKey     string    `json:"-"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// AttachmentUpload загружаемый файл; Size уже известен (multipart спулится сервером)
+type AttachmentUpload struct {
+	Filename string
+	Size     int64
+	Content  io.Reader
+}
+
+// AttachmentsRepository метаданные вложений; удаляются вместе с заметкой.
+// Права доступа проверяет сервис по NotesRepository.
+type AttachmentsRepository interface {
+	CreateAttachment(ctx context.Context, a Attachment) (int64, error)
+	ListAttachments(ctx context.Context, noteID int64) ([]Attachment, error)
+	GetAttachment(ctx context.Context, noteID, id int64) (Attachment, error)
+	DeleteAttachment(ctx context.Context, noteID, id int64) error
+}
+
+// BlobStore хранилище содержимого файлов (локальный диск, S3-совместимое хранилище)
+type BlobStore interface {
+	Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error
+	// Get возвращает ErrNotFound, если объекта нет
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	Delete(ctx context.Context, key string) error
+}
+
+type AttachmentsService interface {
+	Upload(ctx context.Context, userID, noteID int64, upload AttachmentUpload) (Attachment, error)
+	List(ctx context.Context, userID, noteID int64) ([]Attachment, error)
+	// Open возвращает метаданные и содержимое вложения; вызывающий закрывает ReadCloser
+	Open(ctx context.Context, userID, noteID, id int64) (Attachment, io.ReadCloser, error)
+	Delete(ctx context.Context, userID, noteID, id int64) error
+}
@@ -0,0 +1,20 @@
+package core
+
+import "context"
+
+// TokenVerifier проверяет bearer токен и возвращает ID пользователя
+type TokenVerifier interface {
+	Verify(ctx context.Context, token string) (int64, error)
+}
+
+type userIDCtxKey struct{}
+
+func ContextWithUserID(ctx context.Context, userID int64) context.Context {
+	return context.WithValue(ctx, userIDCtxKey{}, userID)
+}
+
+// UserIDFromContext ID пользователя, проставленный middleware аутентификации
+func UserIDFromContext(ctx context.Context) (int64, bool) {
+	id, ok := ctx.Value(userIDCtxKey{}).(int64)
+	return id, ok && id > 0
+}
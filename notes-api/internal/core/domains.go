@@ -2,10 +2,36 @@ package core
 
 import "time"
 
+// Уровни доступа к заметке. Владелец может всё; write — читать и редактировать; read — только читать
+const (
+	AccessOwner = "owner"
+	AccessWrite = "write"
+	AccessRead  = "read"
+)
+
 type Note struct {
-	ID        int64
-	Title     string
-	Content   string
+	ID      int64
+	OwnerID int64
+	Title   string
+	Content string
+	// Access уровень доступа пользователя, запросившего заметку
+	Access    string
 	CreatedAt time.Time
 	UpdatedAt time.Time
 }
+
+func (n Note) CanWrite() bool {
+	return n.Access == AccessOwner || n.Access == AccessWrite
+}
+
+// NoteShare доступ к чужой заметке, выданный владельцем
+type NoteShare struct {
+	NoteID     int64     `json:"note_id"`
+	UserID     int64     `json:"user_id"`
+	Permission string    `json:"permission"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+func ValidSharePermission(p string) bool {
+	return p == AccessRead || p == AccessWrite
+}
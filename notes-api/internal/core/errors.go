@@ -0,0 +1,55 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var (
+	// ErrNotFound запрошенный ресурс не существует
+	ErrNotFound = errors.New("not found")
+	// ErrValidation входные данные не прошли проверку; подробности в *ValidationError
+	ErrValidation = errors.New("validation failed")
+	// ErrUnauthorized запрос без действительного токена
+	ErrUnauthorized = errors.New("unauthorized")
+	// ErrForbidden у пользователя нет прав на действие
+	ErrForbidden = errors.New("forbidden")
+	// ErrTooLarge загружаемый файл превышает допустимый размер
+	ErrTooLarge = errors.New("payload too large")
+	// ErrUnsupportedMediaType тип файла не входит в разрешённые
+	ErrUnsupportedMediaType = errors.New("unsupported media type")
+)
+
+var (
+	ErrNoteNotFound       = fmt.Errorf("note %w", ErrNotFound)
+	ErrShareNotFound      = fmt.Errorf("share %w", ErrNotFound)
+	ErrAttachmentNotFound = fmt.Errorf("attachment %w", ErrNotFound)
+)
+
+// FieldError ошибка проверки одного поля
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// ValidationError набор ошибок проверки; errors.Is(err, ErrValidation) возвращает true
+type ValidationError struct {
+	Fields []FieldError
+}
+
+func NewValidationError(field, message string) *ValidationError {
+	return &ValidationError{Fields: []FieldError{{Field: field, Message: message}}}
+}
+
+func (e *ValidationError) Error() string {
+	parts := make([]string, 0, len(e.Fields))
+	for _, f := range e.Fields {
+		parts = append(parts, f.Field+" "+f.Message)
+	}
+	return strings.Join(parts, "; ")
+}
+
+func (e *ValidationError) Is(target error) bool {
+	return target == ErrValidation
+}
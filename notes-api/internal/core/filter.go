@@ -0,0 +1,112 @@
+package core
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+)
+
+const (
+	DefaultNotesLimit = 20
+	MaxNotesLimit     = 100
+)
+
+// Поля сортировки списка заметок; префикс "-" означает обратный порядок, например "-created_at"
+const (
+	SortID        = "id"
+	SortCreatedAt = "created_at"
+	SortUpdatedAt = "updated_at"
+	SortTitle     = "title"
+)
+
+var NoteSortFields = []string{SortID, SortCreatedAt, SortUpdatedAt, SortTitle}
+
+// NoteFilter параметры выборки списка заметок
+type NoteFilter struct {
+	// Query подстрока заголовка или содержимого без учёта регистра
+	Query         string
+	CreatedAfter  *time.Time
+	CreatedBefore *time.Time
+	Sort          string
+	Limit         int
+	// Cursor непрозрачный курсор из NotePage.NextCursor предыдущей страницы
+	Cursor string
+}
+
+func (f NoteFilter) SortField() string {
+	return strings.TrimPrefix(f.Sort, "-")
+}
+
+func (f NoteFilter) SortDesc() bool {
+	return strings.HasPrefix(f.Sort, "-")
+}
+
+// NotePage страница списка заметок; NextCursor пуст на последней странице
+type NotePage struct {
+	Items      []*Note `json:"items"`
+	NextCursor string  `json:"next_cursor,omitempty"`
+}
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// NoteCursor позиция последней выданной заметки для keyset-пагинации:
+// значение поля сортировки и ID (ID разрешает равные значения)
+type NoteCursor struct {
+	Sort  string `json:"s"`
+	Value string `json:"v,omitempty"`
+	ID    int64  `json:"id"`
+}
+
+func NewNoteCursor(sort string, n *Note) NoteCursor {
+	c := NoteCursor{Sort: sort, ID: n.ID}
+	switch strings.TrimPrefix(sort, "-") {
+	case SortCreatedAt:
+		c.Value = n.CreatedAt.UTC().Format(time.RFC3339Nano)
+	case SortUpdatedAt:
+		c.Value = n.UpdatedAt.UTC().Format(time.RFC3339Nano)
+	case SortTitle:
+		c.Value = n.Title
+	}
+	return c
+}
+
+func (c NoteCursor) Encode() string {
+	raw, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+func DecodeNoteCursor(s string) (NoteCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return NoteCursor{}, ErrInvalidCursor
+	}
+	var c NoteCursor
+	if err := json.Unmarshal(raw, &c); err != nil || c.ID <= 0 {
+		return NoteCursor{}, ErrInvalidCursor
+	}
+	if _, err := c.Position(); err != nil {
+		return NoteCursor{}, err
+	}
+	return c, nil
+}
+
+// Position заметка-ориентир с заполненными ID и полем сортировки курсора
+func (c NoteCursor) Position() (Note, error) {
+	n := Note{ID: c.ID}
+	switch strings.TrimPrefix(c.Sort, "-") {
+	case SortID:
+	case SortCreatedAt, SortUpdatedAt:
+		t, err := time.Parse(time.RFC3339Nano, c.Value)
+		if err != nil {
+			return Note{}, ErrInvalidCursor
+		}
+		n.CreatedAt, n.UpdatedAt = t, t
+	case SortTitle:
+		n.Title = c.Value
+	default:
+		return Note{}, ErrInvalidCursor
+	}
+	return n, nil
+}
@@ -1,9 +1,20 @@
 package core
 
+import "context"
+
+// NotesRepository хранилище заметок. Чтение и изменение ограничены заметками,
+// доступными пользователю userID: своими и выданными ему через NoteShare.
 type NotesRepository interface {
-	GetAll() ([]*Note, error)
-	GetById(id int64) (Note, error)
-	Create(note Note) (int64, error)
-	Update(note Note) error
-	Delete(id int64) error
+	// List возвращает страницу заметок; фильтр уже проверен сервисом
+	List(ctx context.Context, userID int64, filter NoteFilter) (NotePage, error)
+	GetById(ctx context.Context, userID, id int64) (Note, error)
+	Create(ctx context.Context, note Note) (int64, error)
+	// Update изменяет заметку, если userID владелец или имеет доступ write
+	Update(ctx context.Context, userID int64, note Note) error
+	// Delete удаляет заметку владельца ownerID вместе с выданными доступами
+	Delete(ctx context.Context, ownerID, id int64) error
+
+	Share(ctx context.Context, share NoteShare) error
+	Unshare(ctx context.Context, noteID, userID int64) error
+	ListShares(ctx context.Context, noteID int64) ([]NoteShare, error)
 }
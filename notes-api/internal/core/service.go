@@ -1,5 +1,7 @@
 package core
 
+import "context"
+
 type NoteCreatePayload struct {
 	Title   string
 	Content string
@@ -11,9 +13,13 @@ type NoteUpdatePayload struct {
 }
 
 type NotesService interface {
-	GetAll() ([]*Note, error)
-	GetById(id int64) (Note, error)
-	CreateNote(payload NoteCreatePayload) (int64, error)
-	UpdateNote(id int64, payload NoteUpdatePayload) error
-	Delete(id int64) error
+	List(ctx context.Context, userID int64, filter NoteFilter) (NotePage, error)
+	GetById(ctx context.Context, userID, id int64) (Note, error)
+	CreateNote(ctx context.Context, userID int64, payload NoteCreatePayload) (int64, error)
+	UpdateNote(ctx context.Context, userID, id int64, payload NoteUpdatePayload) error
+	Delete(ctx context.Context, userID, id int64) error
+
+	ShareNote(ctx context.Context, userID, noteID, targetID int64, permission string) error
+	UnshareNote(ctx context.Context, userID, noteID, targetID int64) error
+	ListShares(ctx context.Context, userID, noteID int64) ([]NoteShare, error)
 }
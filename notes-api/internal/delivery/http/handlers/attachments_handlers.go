@@ -0,0 +1,203 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/icestormerrr/notes-api/internal/core"
+	http_utils "github.com/icestormerrr/notes-api/internal/utils/http"
+)
+
+// multipartMemory часть multipart-формы, которая держится в памяти; остальное спулится во временные файлы
+const multipartMemory = 1 << 20
+
+type AttachmentHandler struct {
+	service core.AttachmentsService
+	maxSize int64
+}
+
+func NewAttachmentHandler(service core.AttachmentsService, maxSize int64) *AttachmentHandler {
+	return &AttachmentHandler{service: service, maxSize: maxSize}
+}
+
+// UploadAttachment godoc
+// @Summary      Загрузить вложение
+// @Description  multipart/form-data с полем file. Тип файла определяется по содержимому и должен входить в разрешённые.
+// @Tags         attachments
+// @Accept       multipart/form-data
+// @Produce      json
+// @Param        id    path      int   true  "Идентификатор заметки"
+// @Param        file  formData  file  true  "Файл"
+// @Success      200  {object}  core.Attachment
+// @Failure      400  {object}  map[string]any
+// @Failure      401  {object}  map[string]string
+// @Failure      403  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Failure      413  {object}  map[string]string
+// @Failure      415  {object}  map[string]string
+// @Security     BearerAuth
+// @Router       /notes/{id}/attachments [post]
+func (h *AttachmentHandler) UploadAttachment(w http.ResponseWriter, r *http.Request) {
+	userID, ok := currentUser(w, r)
+	if !ok {
+		return
+	}
+
+	noteID, err := parseID(chi.URLParam(r, "id"))
+	if err != nil {
+		http_utils.WriteError(w, http.StatusBadRequest, "invalid note id", err.Error())
+		return
+	}
+
+	// запас на заголовки multipart сверх размера самого файла
+	r.Body = http.MaxBytesReader(w, r.Body, h.maxSize+multipartMemory)
+	if err := r.ParseMultipartForm(multipartMemory); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			writeServiceError(w, r, fmt.Errorf("%w: file exceeds %d bytes", core.ErrTooLarge, h.maxSize))
+			return
+		}
+		http_utils.WriteError(w, http.StatusBadRequest, "invalid multipart form", err.Error())
+		return
+	}
+	defer r.MultipartForm.RemoveAll()
+
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		writeServiceError(w, r, core.NewValidationError("file", "is required"))
+		return
+	}
+	defer file.Close()
+
+	attachment, err := h.service.Upload(r.Context(), userID, noteID, core.AttachmentUpload{
+		Filename: header.Filename,
+		Size:     header.Size,
+		Content:  file,
+	})
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+
+	http_utils.WriteJSON(w, attachment)
+}
+
+// ListAttachments godoc
+// @Summary      Вложения заметки
+// @Tags         attachments
+// @Produce      json
+// @Param        id   path  int  true  "Идентификатор заметки"
+// @Success      200  {array}   core.Attachment
+// @Failure      401  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Security     BearerAuth
+// @Router       /notes/{id}/attachments [get]
+func (h *AttachmentHandler) ListAttachments(w http.ResponseWriter, r *http.Request) {
+	userID, ok := currentUser(w, r)
+	if !ok {
+		return
+	}
+
+	noteID, err := parseID(chi.URLParam(r, "id"))
+	if err != nil {
+		http_utils.WriteError(w, http.StatusBadRequest, "invalid note id", err.Error())
+		return
+	}
+
+	attachments, err := h.service.List(r.Context(), userID, noteID)
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+
+	http_utils.WriteJSON(w, attachments)
+}
+
+// DownloadAttachment godoc
+// @Summary      Скачать вложение
+// @Tags         attachments
+// @Produce      octet-stream
+// @Param        id            path  int  true  "Идентификатор заметки"
+// @Param        attachmentId  path  int  true  "Идентификатор вложения"
+// @Success      200  {file}    file
+// @Failure      401  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Security     BearerAuth
+// @Router       /notes/{id}/attachments/{attachmentId} [get]
+func (h *AttachmentHandler) DownloadAttachment(w http.ResponseWriter, r *http.Request) {
+	userID, ok := currentUser(w, r)
+	if !ok {
+		return
+	}
+
+	noteID, attachmentID, ok := parseAttachmentPath(w, r)
+	if !ok {
+		return
+	}
+
+	attachment, content, err := h.service.Open(r.Context(), userID, noteID, attachmentID)
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+	defer content.Close()
+
+	w.Header().Set("Content-Type", attachment.ContentType)
+	w.Header().Set("Content-Length", strconv.FormatInt(attachment.Size, 10))
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": attachment.Filename}))
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(http.StatusOK)
+	if _, err := io.Copy(w, content); err != nil {
+		log.Printf("[ERROR] failed to stream attachment %d: %v", attachment.ID, err)
+	}
+}
+
+// DeleteAttachment godoc
+// @Summary      Удалить вложение
+// @Tags         attachments
+// @Param        id            path  int  true  "Идентификатор заметки"
+// @Param        attachmentId  path  int  true  "Идентификатор вложения"
+// @Success      204  "No Content"
+// @Failure      401  {object}  map[string]string
+// @Failure      403  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Security     BearerAuth
+// @Router       /notes/{id}/attachments/{attachmentId} [delete]
+func (h *AttachmentHandler) DeleteAttachment(w http.ResponseWriter, r *http.Request) {
+	userID, ok := currentUser(w, r)
+	if !ok {
+		return
+	}
+
+	noteID, attachmentID, ok := parseAttachmentPath(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.service.Delete(r.Context(), userID, noteID, attachmentID); err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func parseAttachmentPath(w http.ResponseWriter, r *http.Request) (int64, int64, bool) {
+	noteID, err := parseID(chi.URLParam(r, "id"))
+	if err != nil {
+		http_utils.WriteError(w, http.StatusBadRequest, "invalid note id", err.Error())
+		return 0, 0, false
+	}
+	attachmentID, err := parseID(chi.URLParam(r, "attachmentId"))
+	if err != nil {
+		http_utils.WriteError(w, http.StatusBadRequest, "invalid attachment id", err.Error())
+		return 0, 0, false
+	}
+	return noteID, attachmentID, true
+}
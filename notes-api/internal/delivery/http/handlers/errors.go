@@ -0,0 +1,41 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/icestormerrr/notes-api/internal/core"
+	http_utils "github.com/icestormerrr/notes-api/internal/utils/http"
+)
+
+// writeServiceError переводит доменную ошибку в HTTP-ответ. Единая точка для всех обработчиков:
+// текст внутренних ошибок клиенту не отдаётся, только логируется.
+func writeServiceError(w http.ResponseWriter, r *http.Request, err error) {
+	var validation *core.ValidationError
+	switch {
+	case errors.As(err, &validation):
+		http_utils.WriteError(w, http.StatusBadRequest, validation.Error(), validation.Fields)
+	case errors.Is(err, core.ErrValidation):
+		http_utils.WriteError(w, http.StatusBadRequest, err.Error(), nil)
+	case errors.Is(err, core.ErrNotFound):
+		http_utils.WriteError(w, http.StatusNotFound, err.Error(), nil)
+	case errors.Is(err, core.ErrUnauthorized):
+		http_utils.WriteError(w, http.StatusUnauthorized, "unauthorized", nil)
+	case errors.Is(err, core.ErrForbidden):
+		http_utils.WriteError(w, http.StatusForbidden, "forbidden", nil)
+	case errors.Is(err, core.ErrTooLarge):
+		http_utils.WriteError(w, http.StatusRequestEntityTooLarge, err.Error(), nil)
+	case errors.Is(err, core.ErrUnsupportedMediaType):
+		http_utils.WriteError(w, http.StatusUnsupportedMediaType, err.Error(), nil)
+	case errors.Is(err, context.Canceled):
+		// клиент закрыл соединение, отвечать некому
+		log.Printf("[CANCELED] %s %s: %v", r.Method, r.URL.Path, err)
+	case errors.Is(err, context.DeadlineExceeded):
+		http_utils.WriteError(w, http.StatusGatewayTimeout, "request timeout", nil)
+	default:
+		log.Printf("[ERROR] %s %s: %v", r.Method, r.URL.Path, err)
+		http_utils.WriteError(w, http.StatusInternalServerError, "internal server error", nil)
+	}
+}
@@ -0,0 +1,56 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/icestormerrr/notes-api/internal/core"
+)
+
+func TestWriteServiceError(t *testing.T) {
+	cases := []struct {
+		name   string
+		err    error
+		status int
+		msg    string
+	}{
+		{"not found", core.ErrNoteNotFound, http.StatusNotFound, "note not found"},
+		{"wrapped not found", fmt.Errorf("update: %w", core.ErrNoteNotFound), http.StatusNotFound, "update: note not found"},
+		{"validation", core.NewValidationError("title", "cannot be empty"), http.StatusBadRequest, "title cannot be empty"},
+		{"deadline", context.DeadlineExceeded, http.StatusGatewayTimeout, "request timeout"},
+		{"internal", errors.New("connection refused"), http.StatusInternalServerError, "internal server error"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			writeServiceError(w, httptest.NewRequest(http.MethodGet, "/api/v1/notes/1", nil), tc.err)
+			if w.Code != tc.status {
+				t.Fatalf("want status %d, got %d", tc.status, w.Code)
+			}
+			var body map[string]any
+			if err := json.NewDecoder(w.Body).Decode(&body); err != nil {
+				t.Fatal(err)
+			}
+			if body["error"] != tc.msg {
+				t.Fatalf("want error %q, got %v", tc.msg, body["error"])
+			}
+		})
+	}
+
+	w := httptest.NewRecorder()
+	writeServiceError(w, httptest.NewRequest(http.MethodGet, "/", nil), core.NewValidationError("title", "cannot be empty"))
+	var body struct {
+		Details []core.FieldError `json:"details"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&body); err != nil {
+		t.Fatal(err)
+	}
+	if len(body.Details) != 1 || body.Details[0].Field != "title" {
+		t.Fatalf("validation details must list fields, got %+v", body.Details)
+	}
+}
@@ -2,12 +2,17 @@ package handlers
 
 import (
 	"encoding/json"
+	"io"
 	"net/http"
+	"net/url"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/icestormerrr/notes-api/internal/core"
 	http_utils "github.com/icestormerrr/notes-api/internal/utils/http"
+	"github.com/icestormerrr/notes-api/internal/utils/markdown"
 )
 
 type NoteHandler struct {
@@ -30,39 +35,94 @@ type NoteUpdateRequest struct {
 
 // GetAllNotes godoc
 // @Summary      Список заметок
-// @Description  Возвращает список заметок с пагинацией и фильтром по заголовку
+// @Description  Возвращает страницу заметок. Следующая страница запрашивается с cursor из next_cursor
+// @Description  и тем же sort; на последней странице next_cursor отсутствует.
 // @Tags         notes
-// @Success      200    {array}  core.Note
+// @Produce      json
+// @Param        limit           query  int     false  "Размер страницы (1-100)"  default(20)
+// @Param        cursor          query  string  false  "Курсор следующей страницы"
+// @Param        q               query  string  false  "Поиск по заголовку и содержимому без учёта регистра"
+// @Param        sort            query  string  false  "Поле сортировки, префикс - для обратного порядка"  Enums(id, -id, created_at, -created_at, updated_at, -updated_at, title, -title)  default(id)
+// @Param        created_after   query  string  false  "Созданы позже (RFC 3339)"
+// @Param        created_before  query  string  false  "Созданы раньше (RFC 3339)"
+// @Success      200    {object}  core.NotePage
+// @Failure      400    {object}  map[string]any
 // @Failure      500    {object}  map[string]string
+// @Security     BearerAuth
+// @Failure      401  {object}  map[string]string
 // @Router       /notes [get]
 func (h *NoteHandler) GetAllNotes(w http.ResponseWriter, r *http.Request) {
-	notes, err := h.service.GetAll()
+	userID, ok := currentUser(w, r)
+	if !ok {
+		return
+	}
+
+	filter, err := parseNoteFilter(r.URL.Query())
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+
+	page, err := h.service.List(r.Context(), userID, filter)
 	if err != nil {
-		http_utils.WriteError(w, http.StatusInternalServerError, "failed to get notes", err.Error())
+		writeServiceError(w, r, err)
 		return
 	}
 
-	http_utils.WriteJSON(w, notes)
+	http_utils.WriteJSON(w, page)
 }
 
 // GetNoteById godoc
 // @Summary      Получить заметку
+// @Description  format=html возвращает содержимое заметки (Markdown), отрисованное в безопасный HTML-фрагмент
 // @Tags         notes
-// @Param        id   path   int  true  "ID"
+// @Produce      json
+// @Produce      html
+// @Param        id      path   int     true   "ID"
+// @Param        format  query  string  false  "Формат ответа"  Enums(json, html)  default(json)
 // @Success      200  {object}  core.Note
+// @Failure      400  {object}  map[string]any
 // @Failure      404  {object}  map[string]string
 // @Failure      500  {object}  map[string]string
+// @Security     BearerAuth
+// @Failure      401  {object}  map[string]string
 // @Router       /notes/{id} [get]
 func (h *NoteHandler) GetNoteById(w http.ResponseWriter, r *http.Request) {
+	userID, ok := currentUser(w, r)
+	if !ok {
+		return
+	}
+
 	id, err := parseID(chi.URLParam(r, "id"))
 	if err != nil {
 		http_utils.WriteError(w, http.StatusBadRequest, "invalid note id", err.Error())
 		return
 	}
 
-	note, err := h.service.GetById(id)
+	format := r.URL.Query().Get("format")
+	if format != "" && format != "json" && format != "html" {
+		writeServiceError(w, r, core.NewValidationError("format", "must be json or html"))
+		return
+	}
+
+	note, err := h.service.GetById(r.Context(), userID, id)
 	if err != nil {
-		http_utils.WriteError(w, http.StatusNotFound, "note not found", nil)
+		writeServiceError(w, r, err)
+		return
+	}
+
+	if format == "html" {
+		html, err := markdown.Render(note.Content)
+		if err != nil {
+			writeServiceError(w, r, err)
+			return
+		}
+		// HTML пользовательский: запрещаем скрипты, даже если санитайзер что-то пропустит
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Header().Set("Content-Security-Policy", "default-src 'none'; img-src https: data:; style-src 'unsafe-inline'")
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		w.WriteHeader(http.StatusOK)
+		io.WriteString(w, html)
 		return
 	}
 
@@ -78,8 +138,15 @@ func (h *NoteHandler) GetNoteById(w http.ResponseWriter, r *http.Request) {
 // @Success      201    {object} core.Note
 // @Failure      400    {object} map[string]string
 // @Failure      500    {object} map[string]string
+// @Security     BearerAuth
+// @Failure      401  {object}  map[string]string
 // @Router       /notes [post]
 func (h *NoteHandler) CreateNote(w http.ResponseWriter, r *http.Request) {
+	userID, ok := currentUser(w, r)
+	if !ok {
+		return
+	}
+
 	var req NoteCreateRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		http_utils.WriteError(w, http.StatusBadRequest, "invalid request body", err.Error())
@@ -91,9 +158,9 @@ func (h *NoteHandler) CreateNote(w http.ResponseWriter, r *http.Request) {
 		Content: req.Content,
 	}
 
-	id, err := h.service.CreateNote(payload)
+	id, err := h.service.CreateNote(r.Context(), userID, payload)
 	if err != nil {
-		http_utils.WriteError(w, http.StatusBadRequest, err.Error(), nil)
+		writeServiceError(w, r, err)
 		return
 	}
 
@@ -110,8 +177,16 @@ func (h *NoteHandler) CreateNote(w http.ResponseWriter, r *http.Request) {
 // @Failure      400    {object}  map[string]string
 // @Failure      404    {object}  map[string]string
 // @Failure      500    {object}  map[string]string
+// @Security     BearerAuth
+// @Failure      401  {object}  map[string]string
+// @Failure      403  {object}  map[string]string
 // @Router       /notes/{id} [patch]
 func (h *NoteHandler) UpdateNote(w http.ResponseWriter, r *http.Request) {
+	userID, ok := currentUser(w, r)
+	if !ok {
+		return
+	}
+
 	id, err := parseID(chi.URLParam(r, "id"))
 	if err != nil {
 		http_utils.WriteError(w, http.StatusBadRequest, "invalid note id", err.Error())
@@ -129,12 +204,8 @@ func (h *NoteHandler) UpdateNote(w http.ResponseWriter, r *http.Request) {
 		Content: req.Content,
 	}
 
-	if err := h.service.UpdateNote(id, payload); err != nil {
-		if err.Error() == "note not found" {
-			http_utils.WriteError(w, http.StatusNotFound, err.Error(), nil)
-			return
-		}
-		http_utils.WriteError(w, http.StatusBadRequest, err.Error(), nil)
+	if err := h.service.UpdateNote(r.Context(), userID, id, payload); err != nil {
+		writeServiceError(w, r, err)
 		return
 	}
 
@@ -144,26 +215,81 @@ func (h *NoteHandler) UpdateNote(w http.ResponseWriter, r *http.Request) {
 // DeleteNote godoc
 // @Summary      Удалить заметку
 // @Tags         notes
-// @Param        id  path  int  true  "Bltynbabrfnjh pfvtnrb"
+// @Param        id  path  int  true  "Идентификатор заметки"
 // @Success      204  "No Content"
 // @Failure      404  {object}  map[string]string
 // @Failure      500  {object}  map[string]string
+// @Security     BearerAuth
+// @Failure      401  {object}  map[string]string
+// @Failure      403  {object}  map[string]string
 // @Router       /notes/{id} [delete]
 func (h *NoteHandler) DeleteNote(w http.ResponseWriter, r *http.Request) {
+	userID, ok := currentUser(w, r)
+	if !ok {
+		return
+	}
+
 	id, err := parseID(chi.URLParam(r, "id"))
 	if err != nil {
 		http_utils.WriteError(w, http.StatusBadRequest, "invalid note id", err.Error())
 		return
 	}
 
-	if err := h.service.Delete(id); err != nil {
-		http_utils.WriteError(w, http.StatusNotFound, err.Error(), nil)
+	if err := h.service.Delete(r.Context(), userID, id); err != nil {
+		writeServiceError(w, r, err)
 		return
 	}
 
 	w.WriteHeader(http.StatusNoContent)
 }
 
+// currentUser ID пользователя из контекста; без middleware Auth запрос отклоняется
+func currentUser(w http.ResponseWriter, r *http.Request) (int64, bool) {
+	userID, ok := core.UserIDFromContext(r.Context())
+	if !ok {
+		writeServiceError(w, r, core.ErrUnauthorized)
+	}
+	return userID, ok
+}
+
 func parseID(idStr string) (int64, error) {
 	return strconv.ParseInt(idStr, 10, 64)
 }
+
+func parseNoteFilter(q url.Values) (core.NoteFilter, error) {
+	filter := core.NoteFilter{
+		Query:  strings.TrimSpace(q.Get("q")),
+		Sort:   q.Get("sort"),
+		Cursor: q.Get("cursor"),
+	}
+	var fields []core.FieldError
+
+	if v := q.Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil {
+			fields = append(fields, core.FieldError{Field: "limit", Message: "must be an integer"})
+		}
+		filter.Limit = limit
+	}
+
+	for _, p := range []struct {
+		name   string
+		target **time.Time
+	}{{"created_after", &filter.CreatedAfter}, {"created_before", &filter.CreatedBefore}} {
+		v := q.Get(p.name)
+		if v == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			fields = append(fields, core.FieldError{Field: p.name, Message: "must be an RFC 3339 timestamp"})
+			continue
+		}
+		*p.target = &t
+	}
+
+	if len(fields) > 0 {
+		return filter, &core.ValidationError{Fields: fields}
+	}
+	return filter, nil
+}
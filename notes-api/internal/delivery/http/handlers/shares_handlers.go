@@ -0,0 +1,128 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	http_utils "github.com/icestormerrr/notes-api/internal/utils/http"
+)
+
+type ShareRequest struct {
+	Permission string `json:"permission" enums:"read,write"`
+}
+
+// ListShares godoc
+// @Summary      Доступы к заметке
+// @Description  Список пользователей, которым владелец выдал доступ к заметке
+// @Tags         shares
+// @Produce      json
+// @Param        id   path  int  true  "Идентификатор заметки"
+// @Success      200  {array}   core.NoteShare
+// @Failure      401  {object}  map[string]string
+// @Failure      403  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Security     BearerAuth
+// @Router       /notes/{id}/shares [get]
+func (h *NoteHandler) ListShares(w http.ResponseWriter, r *http.Request) {
+	userID, ok := currentUser(w, r)
+	if !ok {
+		return
+	}
+
+	id, err := parseID(chi.URLParam(r, "id"))
+	if err != nil {
+		http_utils.WriteError(w, http.StatusBadRequest, "invalid note id", err.Error())
+		return
+	}
+
+	shares, err := h.service.ListShares(r.Context(), userID, id)
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+
+	http_utils.WriteJSON(w, shares)
+}
+
+// ShareNote godoc
+// @Summary      Выдать доступ к заметке
+// @Description  Выдаёт пользователю доступ read или write; повторный вызов меняет уровень доступа. Только для владельца.
+// @Tags         shares
+// @Accept       json
+// @Param        id       path  int           true  "Идентификатор заметки"
+// @Param        userId   path  int           true  "Идентификатор пользователя"
+// @Param        input    body  ShareRequest  true  "Уровень доступа"
+// @Success      204  "No Content"
+// @Failure      400  {object}  map[string]any
+// @Failure      401  {object}  map[string]string
+// @Failure      403  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Security     BearerAuth
+// @Router       /notes/{id}/shares/{userId} [put]
+func (h *NoteHandler) ShareNote(w http.ResponseWriter, r *http.Request) {
+	userID, ok := currentUser(w, r)
+	if !ok {
+		return
+	}
+
+	id, err := parseID(chi.URLParam(r, "id"))
+	if err != nil {
+		http_utils.WriteError(w, http.StatusBadRequest, "invalid note id", err.Error())
+		return
+	}
+	targetID, err := parseID(chi.URLParam(r, "userId"))
+	if err != nil {
+		http_utils.WriteError(w, http.StatusBadRequest, "invalid user id", err.Error())
+		return
+	}
+
+	var req ShareRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http_utils.WriteError(w, http.StatusBadRequest, "invalid request body", err.Error())
+		return
+	}
+
+	if err := h.service.ShareNote(r.Context(), userID, id, targetID, req.Permission); err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// UnshareNote godoc
+// @Summary      Отозвать доступ к заметке
+// @Tags         shares
+// @Param        id      path  int  true  "Идентификатор заметки"
+// @Param        userId  path  int  true  "Идентификатор пользователя"
+// @Success      204  "No Content"
+// @Failure      401  {object}  map[string]string
+// @Failure      403  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Security     BearerAuth
+// @Router       /notes/{id}/shares/{userId} [delete]
+func (h *NoteHandler) UnshareNote(w http.ResponseWriter, r *http.Request) {
+	userID, ok := currentUser(w, r)
+	if !ok {
+		return
+	}
+
+	id, err := parseID(chi.URLParam(r, "id"))
+	if err != nil {
+		http_utils.WriteError(w, http.StatusBadRequest, "invalid note id", err.Error())
+		return
+	}
+	targetID, err := parseID(chi.URLParam(r, "userId"))
+	if err != nil {
+		http_utils.WriteError(w, http.StatusBadRequest, "invalid user id", err.Error())
+		return
+	}
+
+	if err := h.service.UnshareNote(r.Context(), userID, id, targetID); err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
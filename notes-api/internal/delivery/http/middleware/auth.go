@@ -0,0 +1,36 @@
+package middleware
+
+import (
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/icestormerrr/notes-api/internal/core"
+	http_utils "github.com/icestormerrr/notes-api/internal/utils/http"
+)
+
+// Auth — middleware, который проверяет заголовок Authorization: Bearer <token>
+// и кладёт ID пользователя в контекст запроса (core.UserIDFromContext).
+func Auth(verifier core.TokenVerifier) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			header := r.Header.Get("Authorization")
+			token, ok := strings.CutPrefix(header, "Bearer ")
+			if !ok || token == "" {
+				w.Header().Set("WWW-Authenticate", `Bearer`)
+				http_utils.WriteError(w, http.StatusUnauthorized, "missing bearer token", nil)
+				return
+			}
+
+			userID, err := verifier.Verify(r.Context(), token)
+			if err != nil {
+				log.Printf("[AUTH] %s %s: %v", r.Method, r.URL.Path, err)
+				w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+				http_utils.WriteError(w, http.StatusUnauthorized, "invalid token", nil)
+				return
+			}
+
+			next.ServeHTTP(w, r.WithContext(core.ContextWithUserID(r.Context(), userID)))
+		})
+	}
+}
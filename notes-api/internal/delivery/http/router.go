@@ -5,11 +5,13 @@ import (
 
 	"github.com/go-chi/chi/v5"
 	_ "github.com/icestormerrr/notes-api/docs"
+	"github.com/icestormerrr/notes-api/internal/core"
 	"github.com/icestormerrr/notes-api/internal/delivery/http/handlers"
+	"github.com/icestormerrr/notes-api/internal/delivery/http/middleware"
 	httpSwagger "github.com/swaggo/http-swagger"
 )
 
-func Build(notesHandler *handlers.NoteHandler) http.Handler {
+func Build(notesHandler *handlers.NoteHandler, attachmentsHandler *handlers.AttachmentHandler, verifier core.TokenVerifier) http.Handler {
 	r := chi.NewRouter()
 
 	// требование практической работы
@@ -25,11 +27,19 @@ func Build(notesHandler *handlers.NoteHandler) http.Handler {
 		http.ServeFile(w, r, "docs/redoc.html")
 	})
 	r.Route("/api/v1/notes", func(r chi.Router) {
+		r.Use(middleware.Auth(verifier))
 		r.Get("/", notesHandler.GetAllNotes)
 		r.Post("/", notesHandler.CreateNote)
 		r.Get("/{id}", notesHandler.GetNoteById)
 		r.Patch("/{id}", notesHandler.UpdateNote)
 		r.Delete("/{id}", notesHandler.DeleteNote)
+		r.Get("/{id}/shares", notesHandler.ListShares)
+		r.Put("/{id}/shares/{userId}", notesHandler.ShareNote)
+		r.Delete("/{id}/shares/{userId}", notesHandler.UnshareNote)
+		r.Get("/{id}/attachments", attachmentsHandler.ListAttachments)
+		r.Post("/{id}/attachments", attachmentsHandler.UploadAttachment)
+		r.Get("/{id}/attachments/{attachmentId}", attachmentsHandler.DownloadAttachment)
+		r.Delete("/{id}/attachments/{attachmentId}", attachmentsHandler.DeleteAttachment)
 	})
 
 	return r
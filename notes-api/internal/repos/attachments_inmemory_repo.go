@@ -0,0 +1,74 @@
+package repos
+
+import (
+	"context"
+	"sort"
+
+	"github.com/icestormerrr/notes-api/internal/core"
+)
+
+func (r *NotesInmemoryRepo) CreateAttachment(ctx context.Context, a core.Attachment) (int64, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.notes[a.NoteID]; !ok {
+		return 0, core.ErrNoteNotFound
+	}
+	r.nextAttachment++
+	a.ID = r.nextAttachment
+	if r.attachments[a.NoteID] == nil {
+		r.attachments[a.NoteID] = make(map[int64]core.Attachment)
+	}
+	r.attachments[a.NoteID][a.ID] = a
+	return a.ID, nil
+}
+
+func (r *NotesInmemoryRepo) ListAttachments(ctx context.Context, noteID int64) ([]core.Attachment, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	result := []core.Attachment{}
+	for _, a := range r.attachments[noteID] {
+		result = append(result, a)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].ID < result[j].ID })
+	return result, nil
+}
+
+func (r *NotesInmemoryRepo) GetAttachment(ctx context.Context, noteID, id int64) (core.Attachment, error) {
+	if err := ctx.Err(); err != nil {
+		return core.Attachment{}, err
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	a, ok := r.attachments[noteID][id]
+	if !ok {
+		return core.Attachment{}, core.ErrAttachmentNotFound
+	}
+	return a, nil
+}
+
+func (r *NotesInmemoryRepo) DeleteAttachment(ctx context.Context, noteID, id int64) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.attachments[noteID][id]; !ok {
+		return core.ErrAttachmentNotFound
+	}
+	delete(r.attachments[noteID], id)
+	return nil
+}
@@ -0,0 +1,62 @@
+package repos
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/icestormerrr/notes-api/internal/core"
+)
+
+const attachmentColumns = "id, note_id, filename, content_type, size, blob_key, created_at"
+
+func scanAttachment(row interface{ Scan(...any) error }) (core.Attachment, error) {
+	var a core.Attachment
+	err := row.Scan(&a.ID, &a.NoteID, &a.Filename, &a.ContentType, &a.Size, &a.BlobKey, &a.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return core.Attachment{}, core.ErrAttachmentNotFound
+	}
+	return a, err
+}
+
+func (r *NotesSQLRepo) CreateAttachment(ctx context.Context, a core.Attachment) (int64, error) {
+	var id int64
+	err := r.db.QueryRowContext(ctx, `
+		INSERT INTO attachments (note_id, filename, content_type, size, blob_key, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6) RETURNING id`,
+		a.NoteID, a.Filename, a.ContentType, a.Size, a.BlobKey, a.CreatedAt.UTC(),
+	).Scan(&id)
+	return id, err
+}
+
+func (r *NotesSQLRepo) ListAttachments(ctx context.Context, noteID int64) ([]core.Attachment, error) {
+	rows, err := r.db.QueryContext(ctx,
+		`SELECT `+attachmentColumns+` FROM attachments WHERE note_id = $1 ORDER BY id`, noteID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := []core.Attachment{}
+	for rows.Next() {
+		a, err := scanAttachment(rows)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, a)
+	}
+	return result, rows.Err()
+}
+
+func (r *NotesSQLRepo) GetAttachment(ctx context.Context, noteID, id int64) (core.Attachment, error) {
+	return scanAttachment(r.db.QueryRowContext(ctx,
+		`SELECT `+attachmentColumns+` FROM attachments WHERE note_id = $1 AND id = $2`, noteID, id))
+}
+
+func (r *NotesSQLRepo) DeleteAttachment(ctx context.Context, noteID, id int64) error {
+	res, err := r.db.ExecContext(ctx, `DELETE FROM attachments WHERE note_id = $1 AND id = $2`, noteID, id)
+	if err != nil {
+		return err
+	}
+	return requireAffected(res, core.ErrAttachmentNotFound)
+}
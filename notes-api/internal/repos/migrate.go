@@ -0,0 +1,73 @@
+package repos
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"fmt"
+	"io/fs"
+	"path"
+)
+
+//go:embed migrations
+var migrationsFS embed.FS
+
+// migrate применяет встроенные миграции диалекта (migrations/<dialect>/*.sql) в порядке имён файлов.
+// Применённые версии хранятся в schema_migrations, поэтому повторный запуск ничего не меняет.
+func migrate(ctx context.Context, db *sql.DB, dialect string) error {
+	if _, err := db.ExecContext(ctx, `
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version    TEXT PRIMARY KEY,
+			applied_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+		)`); err != nil {
+		return err
+	}
+
+	dir := path.Join("migrations", dialect)
+	entries, err := fs.ReadDir(migrationsFS, dir)
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		version := entry.Name()
+		if entry.IsDir() || path.Ext(version) != ".sql" {
+			continue
+		}
+
+		var applied int
+		if err := db.QueryRowContext(ctx,
+			`SELECT COUNT(*) FROM schema_migrations WHERE version = $1`, version,
+		).Scan(&applied); err != nil {
+			return err
+		}
+		if applied > 0 {
+			continue
+		}
+
+		script, err := fs.ReadFile(migrationsFS, path.Join(dir, version))
+		if err != nil {
+			return err
+		}
+		if err := applyMigration(ctx, db, version, string(script)); err != nil {
+			return fmt.Errorf("migration %s: %w", version, err)
+		}
+	}
+	return nil
+}
+
+func applyMigration(ctx context.Context, db *sql.DB, version, script string) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, script); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `INSERT INTO schema_migrations (version) VALUES ($1)`, version); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
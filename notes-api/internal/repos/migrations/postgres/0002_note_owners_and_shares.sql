@@ -0,0 +1,12 @@
+-- заметки, созданные до появления аутентификации, получают owner_id = 0 и никому не доступны
+ALTER TABLE notes ADD COLUMN IF NOT EXISTS owner_id BIGINT NOT NULL DEFAULT 0;
+CREATE INDEX IF NOT EXISTS notes_owner_id_idx ON notes (owner_id);
+
+CREATE TABLE IF NOT EXISTS note_shares (
+    note_id    BIGINT      NOT NULL REFERENCES notes (id) ON DELETE CASCADE,
+    user_id    BIGINT      NOT NULL,
+    permission TEXT        NOT NULL CHECK (permission IN ('read', 'write')),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (note_id, user_id)
+);
+CREATE INDEX IF NOT EXISTS note_shares_user_id_idx ON note_shares (user_id);
@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS attachments (
+    id           BIGSERIAL PRIMARY KEY,
+    note_id      BIGINT      NOT NULL REFERENCES notes (id) ON DELETE CASCADE,
+    filename     TEXT        NOT NULL,
+    content_type TEXT        NOT NULL,
+    size         BIGINT      NOT NULL,
+    blob_key     TEXT        NOT NULL UNIQUE,
+    created_at   TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+CREATE INDEX IF NOT EXISTS attachments_note_id_idx ON attachments (note_id);
@@ -0,0 +1,12 @@
+-- заметки, созданные до появления аутентификации, получают owner_id = 0 и никому не доступны
+ALTER TABLE notes ADD COLUMN owner_id INTEGER NOT NULL DEFAULT 0;
+CREATE INDEX IF NOT EXISTS notes_owner_id_idx ON notes (owner_id);
+
+CREATE TABLE IF NOT EXISTS note_shares (
+    note_id    INTEGER  NOT NULL REFERENCES notes (id) ON DELETE CASCADE,
+    user_id    INTEGER  NOT NULL,
+    permission TEXT     NOT NULL CHECK (permission IN ('read', 'write')),
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (note_id, user_id)
+);
+CREATE INDEX IF NOT EXISTS note_shares_user_id_idx ON note_shares (user_id);
@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS attachments (
+    id           INTEGER PRIMARY KEY AUTOINCREMENT,
+    note_id      INTEGER  NOT NULL REFERENCES notes (id) ON DELETE CASCADE,
+    filename     TEXT     NOT NULL,
+    content_type TEXT     NOT NULL,
+    size         INTEGER  NOT NULL,
+    blob_key     TEXT     NOT NULL UNIQUE,
+    created_at   DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX IF NOT EXISTS attachments_note_id_idx ON attachments (note_id);
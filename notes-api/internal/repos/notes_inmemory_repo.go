@@ -1,73 +1,247 @@
 package repos
 
 import (
-	"errors"
+	"cmp"
+	"context"
+	"sort"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/icestormerrr/notes-api/internal/core"
 )
 
 type NotesInmemoryRepo struct {
-	mu    sync.RWMutex
-	notes map[int64]*core.Note
-	next  int64
+	mu     sync.RWMutex
+	notes  map[int64]*core.Note
+	shares map[int64]map[int64]core.NoteShare // note id -> user id -> доступ
+	next   int64
+
+	attachments    map[int64]map[int64]core.Attachment // note id -> attachment id -> вложение
+	nextAttachment int64
 }
 
 func NewNoteRepoMem() *NotesInmemoryRepo {
-	return &NotesInmemoryRepo{notes: make(map[int64]*core.Note)}
+	return &NotesInmemoryRepo{
+		notes:  make(map[int64]*core.Note),
+		shares: make(map[int64]map[int64]core.NoteShare),
+
+		attachments: make(map[int64]map[int64]core.Attachment),
+	}
 }
 
-func (r *NotesInmemoryRepo) GetAll() ([]*core.Note, error) {
-	r.mu.RLock()
-	defer r.mu.RUnlock()
+// accessLocked уровень доступа userID к заметке или пустая строка
+func (r *NotesInmemoryRepo) accessLocked(note *core.Note, userID int64) string {
+	if note.OwnerID == userID {
+		return core.AccessOwner
+	}
+	return r.shares[note.ID][userID].Permission
+}
+
+func (r *NotesInmemoryRepo) List(ctx context.Context, userID int64, filter core.NoteFilter) (core.NotePage, error) {
+	if err := ctx.Err(); err != nil {
+		return core.NotePage{}, err
+	}
+
+	var after *core.Note
+	if filter.Cursor != "" {
+		cursor, err := core.DecodeNoteCursor(filter.Cursor)
+		if err != nil {
+			return core.NotePage{}, err
+		}
+		position, _ := cursor.Position()
+		after = &position
+	}
+
+	field, desc := filter.SortField(), filter.SortDesc()
+	query := strings.ToLower(filter.Query)
 
-	var result []*core.Note
+	r.mu.RLock()
+	var matched []*core.Note
 	for _, note := range r.notes {
-		result = append(result, note)
+		access := r.accessLocked(note, userID)
+		if access == "" {
+			continue
+		}
+		if query != "" && !strings.Contains(strings.ToLower(note.Title), query) &&
+			!strings.Contains(strings.ToLower(note.Content), query) {
+			continue
+		}
+		if filter.CreatedAfter != nil && !note.CreatedAt.After(*filter.CreatedAfter) {
+			continue
+		}
+		if filter.CreatedBefore != nil && !note.CreatedAt.Before(*filter.CreatedBefore) {
+			continue
+		}
+		if after != nil && !notePrecedes(after, note, field, desc) {
+			continue
+		}
+		copied := *note
+		copied.Access = access
+		matched = append(matched, &copied)
 	}
+	r.mu.RUnlock()
 
-	return result, nil
+	sort.Slice(matched, func(i, j int) bool { return notePrecedes(matched[i], matched[j], field, desc) })
+
+	page := core.NotePage{Items: matched}
+	if len(matched) > filter.Limit {
+		page.Items = matched[:filter.Limit]
+		page.NextCursor = core.NewNoteCursor(filter.Sort, page.Items[filter.Limit-1]).Encode()
+	}
+	if page.Items == nil {
+		page.Items = []*core.Note{}
+	}
+	return page, nil
+}
+
+// notePrecedes стоит ли a раньше b при сортировке по field; равные значения упорядочиваются по ID
+func notePrecedes(a, b *core.Note, field string, desc bool) bool {
+	var order int
+	switch field {
+	case core.SortCreatedAt:
+		order = a.CreatedAt.Compare(b.CreatedAt)
+	case core.SortUpdatedAt:
+		order = a.UpdatedAt.Compare(b.UpdatedAt)
+	case core.SortTitle:
+		order = strings.Compare(a.Title, b.Title)
+	}
+	if order == 0 {
+		order = cmp.Compare(a.ID, b.ID)
+	}
+	if desc {
+		return order > 0
+	}
+	return order < 0
 }
 
-func (r *NotesInmemoryRepo) GetById(id int64) (core.Note, error) {
+func (r *NotesInmemoryRepo) GetById(ctx context.Context, userID, id int64) (core.Note, error) {
+	if err := ctx.Err(); err != nil {
+		return core.Note{}, err
+	}
+
 	r.mu.RLock()
 	defer r.mu.RUnlock()
 
 	result, ok := r.notes[id]
 	if !ok {
-		return core.Note{}, errors.New("note not found")
+		return core.Note{}, core.ErrNoteNotFound
+	}
+	access := r.accessLocked(result, userID)
+	if access == "" {
+		return core.Note{}, core.ErrNoteNotFound
 	}
 
-	return *result, nil
+	note := *result
+	note.Access = access
+	return note, nil
 }
 
-func (r *NotesInmemoryRepo) Create(noteToCreate core.Note) (int64, error) {
+func (r *NotesInmemoryRepo) Create(ctx context.Context, noteToCreate core.Note) (int64, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
 	r.next++
 	noteToCreate.ID = r.next
+	noteToCreate.Access = ""
 	r.notes[noteToCreate.ID] = &noteToCreate
 
 	return noteToCreate.ID, nil
 }
 
-func (r *NotesInmemoryRepo) Update(noteToUpdate core.Note) error {
+func (r *NotesInmemoryRepo) Update(ctx context.Context, userID int64, noteToUpdate core.Note) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
-	r.notes[noteToUpdate.ID] = &noteToUpdate
+	current, ok := r.notes[noteToUpdate.ID]
+	if !ok {
+		return core.ErrNoteNotFound
+	}
+	if access := r.accessLocked(current, userID); access != core.AccessOwner && access != core.AccessWrite {
+		return core.ErrNoteNotFound
+	}
+	updated := *current
+	updated.Title, updated.Content, updated.UpdatedAt = noteToUpdate.Title, noteToUpdate.Content, noteToUpdate.UpdatedAt
+	r.notes[noteToUpdate.ID] = &updated
 
 	return nil
 }
 
-func (r *NotesInmemoryRepo) Delete(id int64) error {
+func (r *NotesInmemoryRepo) Delete(ctx context.Context, ownerID, id int64) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
 	r.mu.Lock()
 	defer r.mu.Unlock()
-	_, ok := r.notes[id]
-	if !ok {
-		return errors.New("note not found")
+	note, ok := r.notes[id]
+	if !ok || note.OwnerID != ownerID {
+		return core.ErrNoteNotFound
 	}
 	delete(r.notes, id)
+	delete(r.shares, id)
+	delete(r.attachments, id)
+	return nil
+}
+
+func (r *NotesInmemoryRepo) Share(ctx context.Context, share core.NoteShare) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.notes[share.NoteID]; !ok {
+		return core.ErrNoteNotFound
+	}
+	if r.shares[share.NoteID] == nil {
+		r.shares[share.NoteID] = make(map[int64]core.NoteShare)
+	}
+	if existing, ok := r.shares[share.NoteID][share.UserID]; ok {
+		share.CreatedAt = existing.CreatedAt
+	} else if share.CreatedAt.IsZero() {
+		share.CreatedAt = time.Now()
+	}
+	r.shares[share.NoteID][share.UserID] = share
+	return nil
+}
+
+func (r *NotesInmemoryRepo) Unshare(ctx context.Context, noteID, userID int64) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.shares[noteID][userID]; !ok {
+		return core.ErrShareNotFound
+	}
+	delete(r.shares[noteID], userID)
 	return nil
 }
+
+func (r *NotesInmemoryRepo) ListShares(ctx context.Context, noteID int64) ([]core.NoteShare, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	result := []core.NoteShare{}
+	for _, share := range r.shares[noteID] {
+		result = append(result, share)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].UserID < result[j].UserID })
+	return result, nil
+}
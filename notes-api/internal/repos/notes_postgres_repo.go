@@ -0,0 +1,32 @@
+package repos
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	_ "github.com/jackc/pgx/v5/stdlib"
+)
+
+// NewNotesPostgresRepo подключается к Postgres и применяет миграции
+func NewNotesPostgresRepo(databaseURL string) (*NotesSQLRepo, error) {
+	db, err := sql.Open("pgx", databaseURL)
+	if err != nil {
+		return nil, err
+	}
+	db.SetMaxOpenConns(10)
+	db.SetMaxIdleConns(5)
+	db.SetConnMaxLifetime(30 * time.Minute)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := db.PingContext(ctx); err != nil {
+		db.Close()
+		return nil, err
+	}
+	if err := migrate(ctx, db, "postgres"); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &NotesSQLRepo{db: db}, nil
+}
@@ -0,0 +1,429 @@
+package repos
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/icestormerrr/notes-api/internal/core"
+)
+
+// testNotesRepository общий контракт core.NotesRepository: каждая реализация обязана его проходить.
+// newRepo должен возвращать пустой репозиторий.
+func testNotesRepository(t *testing.T, newRepo func(t *testing.T) core.NotesRepository) {
+	ctx := context.Background()
+	const owner, friend, stranger = int64(1), int64(2), int64(3)
+	at := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	t.Run("create and get", func(t *testing.T) {
+		repo := newRepo(t)
+		id, err := repo.Create(ctx, core.Note{OwnerID: owner, Title: "first", Content: "body", CreatedAt: at, UpdatedAt: at})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if id <= 0 {
+			t.Fatalf("want positive id, got %d", id)
+		}
+
+		got, err := repo.GetById(ctx, owner, id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.ID != id || got.OwnerID != owner || got.Access != core.AccessOwner || got.Title != "first" || got.Content != "body" {
+			t.Fatalf("unexpected note %+v", got)
+		}
+		if !got.CreatedAt.Equal(at) || !got.UpdatedAt.Equal(at) {
+			t.Fatalf("timestamps not preserved: %v %v", got.CreatedAt, got.UpdatedAt)
+		}
+	})
+
+	t.Run("list pagination", func(t *testing.T) {
+		repo := newRepo(t)
+		page, err := repo.List(ctx, owner, core.NoteFilter{Sort: core.SortID, Limit: 10})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(page.Items) != 0 || page.NextCursor != "" {
+			t.Fatalf("want empty page, got %+v", page)
+		}
+
+		// у второй и третьей заметок одинаковое created_at: порядок между ними задаёт ID
+		fixtures := []struct {
+			title   string
+			created time.Duration
+		}{{"d", 0}, {"b", time.Hour}, {"e", time.Hour}, {"a", 2 * time.Hour}, {"c", 3 * time.Hour}}
+		var ids []int64
+		for _, f := range fixtures {
+			id, err := repo.Create(ctx, core.Note{OwnerID: owner, Title: f.title, CreatedAt: at.Add(f.created), UpdatedAt: at})
+			if err != nil {
+				t.Fatal(err)
+			}
+			ids = append(ids, id)
+		}
+
+		order := func(idx ...int) []int64 {
+			var res []int64
+			for _, i := range idx {
+				res = append(res, ids[i])
+			}
+			return res
+		}
+		cases := map[string][]int64{
+			"id":          order(0, 1, 2, 3, 4),
+			"-id":         order(4, 3, 2, 1, 0),
+			"created_at":  order(0, 1, 2, 3, 4),
+			"-created_at": order(4, 3, 2, 1, 0),
+			"updated_at":  order(0, 1, 2, 3, 4),
+			"title":       order(3, 1, 4, 0, 2),
+			"-title":      order(2, 0, 4, 1, 3),
+		}
+		for sort, want := range cases {
+			var got []int64
+			filter := core.NoteFilter{Sort: sort, Limit: 2}
+			for pages := 0; ; pages++ {
+				if pages > len(want) {
+					t.Fatalf("sort %s: pagination does not terminate", sort)
+				}
+				page, err := repo.List(ctx, owner, filter)
+				if err != nil {
+					t.Fatalf("sort %s: %v", sort, err)
+				}
+				for _, n := range page.Items {
+					got = append(got, n.ID)
+				}
+				if page.NextCursor == "" {
+					break
+				}
+				filter.Cursor = page.NextCursor
+			}
+			if !slices.Equal(got, want) {
+				t.Fatalf("sort %s: want %v, got %v", sort, want, got)
+			}
+		}
+
+		page, err = repo.List(ctx, owner, core.NoteFilter{Sort: core.SortID, Limit: len(ids)})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(page.Items) != len(ids) || page.NextCursor != "" {
+			t.Fatalf("full page must have no next cursor, got %d items cursor %q", len(page.Items), page.NextCursor)
+		}
+	})
+
+	t.Run("list filters", func(t *testing.T) {
+		repo := newRepo(t)
+		shopping, _ := repo.Create(ctx, core.Note{OwnerID: owner, Title: "Shopping list", Content: "eggs", CreatedAt: at, UpdatedAt: at})
+		milk, _ := repo.Create(ctx, core.Note{OwnerID: owner, Title: "Errands", Content: "buy MILK", CreatedAt: at.Add(time.Hour), UpdatedAt: at})
+		percent, _ := repo.Create(ctx, core.Note{OwnerID: owner, Title: "100% done", CreatedAt: at.Add(2 * time.Hour), UpdatedAt: at})
+
+		ids := func(f core.NoteFilter) []int64 {
+			t.Helper()
+			f.Sort, f.Limit = core.SortID, 10
+			page, err := repo.List(ctx, owner, f)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var res []int64
+			for _, n := range page.Items {
+				res = append(res, n.ID)
+			}
+			return res
+		}
+		after, before := at, at.Add(2*time.Hour)
+
+		for name, tc := range map[string]struct {
+			filter core.NoteFilter
+			want   []int64
+		}{
+			"title":          {core.NoteFilter{Query: "SHOP"}, []int64{shopping}},
+			"content":        {core.NoteFilter{Query: "milk"}, []int64{milk}},
+			"like wildcards": {core.NoteFilter{Query: "%"}, []int64{percent}},
+			"created after":  {core.NoteFilter{CreatedAfter: &after}, []int64{milk, percent}},
+			"created before": {core.NoteFilter{CreatedBefore: &before}, []int64{shopping, milk}},
+			"range":          {core.NoteFilter{CreatedAfter: &after, CreatedBefore: &before}, []int64{milk}},
+		} {
+			if got := ids(tc.filter); !slices.Equal(got, tc.want) {
+				t.Fatalf("%s: want %v, got %v", name, tc.want, got)
+			}
+		}
+	})
+
+	t.Run("update", func(t *testing.T) {
+		repo := newRepo(t)
+		id, err := repo.Create(ctx, core.Note{OwnerID: owner, Title: "old", Content: "old", CreatedAt: at, UpdatedAt: at})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		later := at.Add(time.Hour)
+		if err := repo.Update(ctx, owner, core.Note{ID: id, Title: "new", Content: "new", CreatedAt: at, UpdatedAt: later}); err != nil {
+			t.Fatal(err)
+		}
+		got, err := repo.GetById(ctx, owner, id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.Title != "new" || got.Content != "new" || !got.UpdatedAt.Equal(later) || !got.CreatedAt.Equal(at) {
+			t.Fatalf("unexpected note after update %+v", got)
+		}
+
+		if err := repo.Update(ctx, owner, core.Note{ID: id + 1000, Title: "x", UpdatedAt: later}); !errors.Is(err, core.ErrNotFound) {
+			t.Fatalf("update missing: want core.ErrNotFound, got %v", err)
+		}
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		repo := newRepo(t)
+		id, err := repo.Create(ctx, core.Note{OwnerID: owner, Title: "tmp", CreatedAt: at, UpdatedAt: at})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := repo.Delete(ctx, owner, id); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := repo.GetById(ctx, owner, id); !errors.Is(err, core.ErrNotFound) {
+			t.Fatalf("get deleted: want core.ErrNotFound, got %v", err)
+		}
+		if err := repo.Delete(ctx, owner, id); !errors.Is(err, core.ErrNotFound) {
+			t.Fatalf("delete twice: want core.ErrNotFound, got %v", err)
+		}
+	})
+
+	t.Run("owner scoping and shares", func(t *testing.T) {
+		repo := newRepo(t)
+		id, err := repo.Create(ctx, core.Note{OwnerID: owner, Title: "private", CreatedAt: at, UpdatedAt: at})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := repo.Create(ctx, core.Note{OwnerID: friend, Title: "friend's", CreatedAt: at, UpdatedAt: at}); err != nil {
+			t.Fatal(err)
+		}
+
+		visible := func(userID int64) []int64 {
+			t.Helper()
+			page, err := repo.List(ctx, userID, core.NoteFilter{Sort: core.SortID, Limit: 10})
+			if err != nil {
+				t.Fatal(err)
+			}
+			var res []int64
+			for _, n := range page.Items {
+				res = append(res, n.ID)
+			}
+			return res
+		}
+		if got := visible(stranger); len(got) != 0 {
+			t.Fatalf("stranger must see nothing, got %v", got)
+		}
+		if _, err := repo.GetById(ctx, stranger, id); !errors.Is(err, core.ErrNotFound) {
+			t.Fatalf("get foreign note: want core.ErrNotFound, got %v", err)
+		}
+		if err := repo.Update(ctx, stranger, core.Note{ID: id, Title: "hacked", UpdatedAt: at}); !errors.Is(err, core.ErrNotFound) {
+			t.Fatalf("update foreign note: want core.ErrNotFound, got %v", err)
+		}
+		if err := repo.Delete(ctx, stranger, id); !errors.Is(err, core.ErrNotFound) {
+			t.Fatalf("delete foreign note: want core.ErrNotFound, got %v", err)
+		}
+
+		if err := repo.Share(ctx, core.NoteShare{NoteID: id, UserID: friend, Permission: core.AccessRead, CreatedAt: at}); err != nil {
+			t.Fatal(err)
+		}
+		got, err := repo.GetById(ctx, friend, id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.Access != core.AccessRead || got.OwnerID != owner {
+			t.Fatalf("shared note: unexpected %+v", got)
+		}
+		if ids := visible(friend); len(ids) != 2 || ids[0] != id {
+			t.Fatalf("friend must see own and shared notes, got %v", ids)
+		}
+		if err := repo.Update(ctx, friend, core.Note{ID: id, Title: "edited", UpdatedAt: at}); !errors.Is(err, core.ErrNotFound) {
+			t.Fatalf("update with read access: want core.ErrNotFound, got %v", err)
+		}
+
+		// повторная выдача меняет уровень доступа
+		if err := repo.Share(ctx, core.NoteShare{NoteID: id, UserID: friend, Permission: core.AccessWrite, CreatedAt: at}); err != nil {
+			t.Fatal(err)
+		}
+		if err := repo.Update(ctx, friend, core.Note{ID: id, Title: "edited", UpdatedAt: at}); err != nil {
+			t.Fatalf("update with write access: %v", err)
+		}
+		if err := repo.Delete(ctx, friend, id); !errors.Is(err, core.ErrNotFound) {
+			t.Fatalf("only owner may delete, got %v", err)
+		}
+		shares, err := repo.ListShares(ctx, id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(shares) != 1 || shares[0].UserID != friend || shares[0].Permission != core.AccessWrite {
+			t.Fatalf("unexpected shares %+v", shares)
+		}
+
+		if err := repo.Unshare(ctx, id, friend); err != nil {
+			t.Fatal(err)
+		}
+		if err := repo.Unshare(ctx, id, friend); !errors.Is(err, core.ErrNotFound) {
+			t.Fatalf("unshare twice: want core.ErrNotFound, got %v", err)
+		}
+		if _, err := repo.GetById(ctx, friend, id); !errors.Is(err, core.ErrNotFound) {
+			t.Fatalf("revoked share: want core.ErrNotFound, got %v", err)
+		}
+
+		// удаление заметки удаляет и выданные доступы
+		if err := repo.Share(ctx, core.NoteShare{NoteID: id, UserID: friend, Permission: core.AccessRead, CreatedAt: at}); err != nil {
+			t.Fatal(err)
+		}
+		if err := repo.Delete(ctx, owner, id); err != nil {
+			t.Fatal(err)
+		}
+		if shares, _ := repo.ListShares(ctx, id); len(shares) != 0 {
+			t.Fatalf("shares must be removed with note, got %+v", shares)
+		}
+	})
+
+	t.Run("attachments", func(t *testing.T) {
+		repo := newRepo(t)
+		attachments, ok := repo.(core.AttachmentsRepository)
+		if !ok {
+			t.Skip("repository does not store attachments")
+		}
+		noteID, err := repo.Create(ctx, core.Note{OwnerID: owner, Title: "files", CreatedAt: at, UpdatedAt: at})
+		if err != nil {
+			t.Fatal(err)
+		}
+		otherID, err := repo.Create(ctx, core.Note{OwnerID: owner, Title: "other", CreatedAt: at, UpdatedAt: at})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		a := core.Attachment{NoteID: noteID, Filename: "cat.png", ContentType: "image/png", Size: 3, BlobKey: "notes/1/k", CreatedAt: at}
+		a.ID, err = attachments.CreateAttachment(ctx, a)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := attachments.GetAttachment(ctx, noteID, a.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.ID != a.ID || got.Filename != a.Filename || got.BlobKey != a.BlobKey || got.Size != a.Size || !got.CreatedAt.Equal(at) {
+			t.Fatalf("unexpected attachment %+v", got)
+		}
+		if _, err := attachments.GetAttachment(ctx, otherID, a.ID); !errors.Is(err, core.ErrNotFound) {
+			t.Fatalf("attachment of other note: want not found, got %v", err)
+		}
+		if list, err := attachments.ListAttachments(ctx, otherID); err != nil || len(list) != 0 {
+			t.Fatalf("other note: want no attachments, got %+v %v", list, err)
+		}
+
+		if err := repo.Delete(ctx, owner, noteID); err != nil {
+			t.Fatal(err)
+		}
+		if list, err := attachments.ListAttachments(ctx, noteID); err != nil || len(list) != 0 {
+			t.Fatalf("attachments must be removed with note, got %+v %v", list, err)
+		}
+		if err := attachments.DeleteAttachment(ctx, noteID, a.ID); !errors.Is(err, core.ErrNotFound) {
+			t.Fatalf("delete removed attachment: want not found, got %v", err)
+		}
+	})
+
+	t.Run("canceled context", func(t *testing.T) {
+		repo := newRepo(t)
+		canceled, cancel := context.WithCancel(ctx)
+		cancel()
+		if _, err := repo.Create(canceled, core.Note{OwnerID: owner, Title: "late", CreatedAt: at, UpdatedAt: at}); !errors.Is(err, context.Canceled) {
+			t.Fatalf("create: want context.Canceled, got %v", err)
+		}
+		if _, err := repo.List(canceled, owner, core.NoteFilter{Sort: core.SortID, Limit: 1}); !errors.Is(err, context.Canceled) {
+			t.Fatalf("list: want context.Canceled, got %v", err)
+		}
+	})
+}
+
+func TestNotesInmemoryRepo(t *testing.T) {
+	testNotesRepository(t, func(t *testing.T) core.NotesRepository {
+		return NewNoteRepoMem()
+	})
+}
+
+func TestNotesSQLiteRepo(t *testing.T) {
+	testNotesRepository(t, func(t *testing.T) core.NotesRepository {
+		repo, err := NewNotesSQLiteRepo(filepath.Join(t.TempDir(), "notes.db"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { repo.Close() })
+		return repo
+	})
+}
+
+func TestNotesSQLiteRepoMigrationsIdempotent(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "notes.db")
+	for i := 0; i < 2; i++ {
+		repo, err := NewNotesSQLiteRepo(path)
+		if err != nil {
+			t.Fatalf("open #%d: %v", i+1, err)
+		}
+		repo.Close()
+	}
+}
+
+func TestNotesSQLiteRepoAssignLegacyNotes(t *testing.T) {
+	ctx := context.Background()
+	repo, err := NewNotesSQLiteRepo(filepath.Join(t.TempDir(), "notes.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer repo.Close()
+
+	// заметка из времён до аутентификации и обычная заметка другого пользователя
+	legacyID, err := repo.Create(ctx, core.Note{Title: "legacy", CreatedAt: time.Now(), UpdatedAt: time.Now()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ownedID, err := repo.Create(ctx, core.Note{OwnerID: 2, Title: "owned", CreatedAt: time.Now(), UpdatedAt: time.Now()})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assigned, err := repo.AssignLegacyNotes(ctx, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if assigned != 1 {
+		t.Fatalf("want 1 assigned note, got %d", assigned)
+	}
+	if n, err := repo.GetById(ctx, 1, legacyID); err != nil || n.Access != core.AccessOwner {
+		t.Fatalf("legacy note must belong to user 1: %+v %v", n, err)
+	}
+	if _, err := repo.GetById(ctx, 1, ownedID); !errors.Is(err, core.ErrNoteNotFound) {
+		t.Fatalf("owned note must keep its owner, got %v", err)
+	}
+
+	// повторный запуск ничего не меняет
+	if assigned, err := repo.AssignLegacyNotes(ctx, 1); err != nil || assigned != 0 {
+		t.Fatalf("second run: %d %v", assigned, err)
+	}
+}
+
+// TestNotesPostgresRepo запускается только при заданном TEST_DATABASE_URL; таблица notes очищается перед каждым подтестом
+func TestNotesPostgresRepo(t *testing.T) {
+	url := os.Getenv("TEST_DATABASE_URL")
+	if url == "" {
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+	testNotesRepository(t, func(t *testing.T) core.NotesRepository {
+		repo, err := NewNotesPostgresRepo(url)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { repo.Close() })
+		if _, err := repo.db.Exec(`TRUNCATE notes, note_shares, attachments RESTART IDENTITY`); err != nil {
+			t.Fatal(err)
+		}
+		return repo
+	})
+}
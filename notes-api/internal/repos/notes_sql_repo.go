@@ -0,0 +1,223 @@
+package repos
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/icestormerrr/notes-api/internal/core"
+)
+
+// NotesSQLRepo репозиторий notes поверх database/sql. Запросы используют плейсхолдеры $N, RETURNING
+// и ON CONFLICT, которые понимают и Postgres, и SQLite, поэтому реализация общая для обоих драйверов.
+type NotesSQLRepo struct {
+	db *sql.DB
+}
+
+func (r *NotesSQLRepo) Close() error {
+	return r.db.Close()
+}
+
+// noteSelect выбирает заметки, доступные пользователю $1, вместе с его уровнем доступа
+const noteSelect = `
+	SELECT n.id, n.owner_id, n.title, n.content, n.created_at, n.updated_at,
+		CASE WHEN n.owner_id = $1 THEN 'owner' ELSE s.permission END
+	FROM notes n
+	LEFT JOIN note_shares s ON s.note_id = n.id AND s.user_id = $1
+	WHERE (n.owner_id = $1 OR s.user_id IS NOT NULL)`
+
+func scanNote(row interface{ Scan(...any) error }) (core.Note, error) {
+	var n core.Note
+	err := row.Scan(&n.ID, &n.OwnerID, &n.Title, &n.Content, &n.CreatedAt, &n.UpdatedAt, &n.Access)
+	if errors.Is(err, sql.ErrNoRows) {
+		return core.Note{}, core.ErrNoteNotFound
+	}
+	return n, err
+}
+
+// noteSortColumns колонки, по которым разрешена сортировка; значения подставляются в SQL напрямую
+var noteSortColumns = map[string]string{
+	core.SortID:        "n.id",
+	core.SortCreatedAt: "n.created_at",
+	core.SortUpdatedAt: "n.updated_at",
+	core.SortTitle:     "n.title",
+}
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
+func (r *NotesSQLRepo) List(ctx context.Context, userID int64, filter core.NoteFilter) (core.NotePage, error) {
+	column, ok := noteSortColumns[filter.SortField()]
+	if !ok {
+		return core.NotePage{}, core.NewValidationError("sort", "unsupported field")
+	}
+	direction, compare := "ASC", ">"
+	if filter.SortDesc() {
+		direction, compare = "DESC", "<"
+	}
+
+	args := []any{userID}
+	arg := func(v any) string {
+		args = append(args, v)
+		return "$" + strconv.Itoa(len(args))
+	}
+	var where []string
+
+	if filter.Query != "" {
+		pattern := arg("%" + likeEscaper.Replace(strings.ToLower(filter.Query)) + "%")
+		where = append(where, "(LOWER(n.title) LIKE "+pattern+` ESCAPE '\' OR LOWER(n.content) LIKE `+pattern+` ESCAPE '\')`)
+	}
+	if filter.CreatedAfter != nil {
+		where = append(where, "n.created_at > "+arg(filter.CreatedAfter.UTC()))
+	}
+	if filter.CreatedBefore != nil {
+		where = append(where, "n.created_at < "+arg(filter.CreatedBefore.UTC()))
+	}
+	if filter.Cursor != "" {
+		cursor, err := core.DecodeNoteCursor(filter.Cursor)
+		if err != nil {
+			return core.NotePage{}, err
+		}
+		position, _ := cursor.Position()
+		switch filter.SortField() {
+		case core.SortID:
+			where = append(where, "n.id "+compare+" "+arg(position.ID))
+		case core.SortCreatedAt:
+			where = append(where, "(n.created_at, n.id) "+compare+" ("+arg(position.CreatedAt.UTC())+", "+arg(position.ID)+")")
+		case core.SortUpdatedAt:
+			where = append(where, "(n.updated_at, n.id) "+compare+" ("+arg(position.UpdatedAt.UTC())+", "+arg(position.ID)+")")
+		case core.SortTitle:
+			where = append(where, "(n.title, n.id) "+compare+" ("+arg(position.Title)+", "+arg(position.ID)+")")
+		}
+	}
+
+	query := noteSelect
+	for _, cond := range where {
+		query += " AND " + cond
+	}
+	query += " ORDER BY " + column + " " + direction
+	if column != "n.id" {
+		query += ", n.id " + direction
+	}
+	// лишняя запись показывает, что есть следующая страница
+	query += " LIMIT " + arg(filter.Limit+1)
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return core.NotePage{}, err
+	}
+	defer rows.Close()
+
+	page := core.NotePage{Items: []*core.Note{}}
+	for rows.Next() {
+		note, err := scanNote(rows)
+		if err != nil {
+			return core.NotePage{}, err
+		}
+		page.Items = append(page.Items, &note)
+	}
+	if err := rows.Err(); err != nil {
+		return core.NotePage{}, err
+	}
+
+	if len(page.Items) > filter.Limit {
+		page.Items = page.Items[:filter.Limit]
+		page.NextCursor = core.NewNoteCursor(filter.Sort, page.Items[filter.Limit-1]).Encode()
+	}
+	return page, nil
+}
+
+func (r *NotesSQLRepo) GetById(ctx context.Context, userID, id int64) (core.Note, error) {
+	return scanNote(r.db.QueryRowContext(ctx, noteSelect+` AND n.id = $2`, userID, id))
+}
+
+func (r *NotesSQLRepo) Create(ctx context.Context, note core.Note) (int64, error) {
+	var id int64
+	err := r.db.QueryRowContext(ctx,
+		`INSERT INTO notes (owner_id, title, content, created_at, updated_at) VALUES ($1, $2, $3, $4, $5) RETURNING id`,
+		note.OwnerID, note.Title, note.Content, note.CreatedAt.UTC(), note.UpdatedAt.UTC(),
+	).Scan(&id)
+	return id, err
+}
+
+func (r *NotesSQLRepo) Update(ctx context.Context, userID int64, note core.Note) error {
+	res, err := r.db.ExecContext(ctx, `
+		UPDATE notes SET title = $1, content = $2, updated_at = $3
+		WHERE id = $4 AND (owner_id = $5 OR EXISTS (
+			SELECT 1 FROM note_shares s WHERE s.note_id = notes.id AND s.user_id = $5 AND s.permission = 'write'))`,
+		note.Title, note.Content, note.UpdatedAt.UTC(), note.ID, userID,
+	)
+	if err != nil {
+		return err
+	}
+	return requireAffected(res, core.ErrNoteNotFound)
+}
+
+func (r *NotesSQLRepo) Delete(ctx context.Context, ownerID, id int64) error {
+	res, err := r.db.ExecContext(ctx, `DELETE FROM notes WHERE id = $1 AND owner_id = $2`, id, ownerID)
+	if err != nil {
+		return err
+	}
+	return requireAffected(res, core.ErrNoteNotFound)
+}
+
+// AssignLegacyNotes передаёт владельцу заметки без владельца (owner_id = 0), созданные до появления аутентификации
+func (r *NotesSQLRepo) AssignLegacyNotes(ctx context.Context, ownerID int64) (int64, error) {
+	res, err := r.db.ExecContext(ctx, `UPDATE notes SET owner_id = $1 WHERE owner_id = 0`, ownerID)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+func (r *NotesSQLRepo) Share(ctx context.Context, share core.NoteShare) error {
+	if share.CreatedAt.IsZero() {
+		share.CreatedAt = time.Now()
+	}
+	_, err := r.db.ExecContext(ctx, `
+		INSERT INTO note_shares (note_id, user_id, permission, created_at) VALUES ($1, $2, $3, $4)
+		ON CONFLICT (note_id, user_id) DO UPDATE SET permission = excluded.permission`,
+		share.NoteID, share.UserID, share.Permission, share.CreatedAt.UTC(),
+	)
+	return err
+}
+
+func (r *NotesSQLRepo) Unshare(ctx context.Context, noteID, userID int64) error {
+	res, err := r.db.ExecContext(ctx, `DELETE FROM note_shares WHERE note_id = $1 AND user_id = $2`, noteID, userID)
+	if err != nil {
+		return err
+	}
+	return requireAffected(res, core.ErrShareNotFound)
+}
+
+func (r *NotesSQLRepo) ListShares(ctx context.Context, noteID int64) ([]core.NoteShare, error) {
+	rows, err := r.db.QueryContext(ctx,
+		`SELECT note_id, user_id, permission, created_at FROM note_shares WHERE note_id = $1 ORDER BY user_id`, noteID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	shares := []core.NoteShare{}
+	for rows.Next() {
+		var s core.NoteShare
+		if err := rows.Scan(&s.NoteID, &s.UserID, &s.Permission, &s.CreatedAt); err != nil {
+			return nil, err
+		}
+		shares = append(shares, s)
+	}
+	return shares, rows.Err()
+}
+
+func requireAffected(res sql.Result, notFound error) error {
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return notFound
+	}
+	return nil
+}
@@ -0,0 +1,27 @@
+package repos
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+// NewNotesSQLiteRepo открывает файл базы SQLite (создаёт при отсутствии) и применяет миграции
+func NewNotesSQLiteRepo(path string) (*NotesSQLRepo, error) {
+	db, err := sql.Open("sqlite", path+"?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)&_pragma=foreign_keys(1)&_time_format=sqlite")
+	if err != nil {
+		return nil, err
+	}
+	// SQLite допускает одного писателя, пул из одного соединения исключает SQLITE_BUSY
+	db.SetMaxOpenConns(1)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := migrate(ctx, db, "sqlite"); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &NotesSQLRepo{db: db}, nil
+}
@@ -0,0 +1,178 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/icestormerrr/notes-api/internal/core"
+)
+
+type AttachmentsConfig struct {
+	// MaxSize максимальный размер файла в байтах
+	MaxSize int64
+	// AllowedTypes разрешённые MIME-типы; "image/*" разрешает все подтипы
+	AllowedTypes []string
+}
+
+type AttachmentsService struct {
+	config          AttachmentsConfig
+	notesRepo       core.NotesRepository
+	attachmentsRepo core.AttachmentsRepository
+	blobs           core.BlobStore
+}
+
+func NewAttachmentsService(config AttachmentsConfig, notesRepo core.NotesRepository, attachmentsRepo core.AttachmentsRepository, blobs core.BlobStore) *AttachmentsService {
+	return &AttachmentsService{config: config, notesRepo: notesRepo, attachmentsRepo: attachmentsRepo, blobs: blobs}
+}
+
+// Upload сохраняет файл во вложения заметки. Тип определяется по содержимому, заявленный клиентом игнорируется.
+func (s *AttachmentsService) Upload(ctx context.Context, userID, noteID int64, upload core.AttachmentUpload) (core.Attachment, error) {
+	if upload.Size > s.config.MaxSize {
+		return core.Attachment{}, fmt.Errorf("%w: file exceeds %d bytes", core.ErrTooLarge, s.config.MaxSize)
+	}
+	if upload.Size <= 0 {
+		return core.Attachment{}, core.NewValidationError("file", "cannot be empty")
+	}
+	filename := sanitizeFilename(upload.Filename)
+	if filename == "" {
+		return core.Attachment{}, core.NewValidationError("filename", "cannot be empty")
+	}
+
+	note, err := s.notesRepo.GetById(ctx, userID, noteID)
+	if err != nil {
+		return core.Attachment{}, err
+	}
+	if !note.CanWrite() {
+		return core.Attachment{}, core.ErrForbidden
+	}
+
+	head := make([]byte, 512)
+	n, err := io.ReadFull(upload.Content, head)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return core.Attachment{}, err
+	}
+	head = head[:n]
+	contentType, _, _ := mime.ParseMediaType(http.DetectContentType(head))
+	if !s.allowedType(contentType) {
+		return core.Attachment{}, fmt.Errorf("%w: %s", core.ErrUnsupportedMediaType, contentType)
+	}
+
+	key := fmt.Sprintf("notes/%d/%s", noteID, randomKey())
+	content := io.LimitReader(io.MultiReader(bytes.NewReader(head), upload.Content), upload.Size)
+	if err := s.blobs.Put(ctx, key, content, upload.Size, contentType); err != nil {
+		return core.Attachment{}, err
+	}
+
+	attachment := core.Attachment{
+		NoteID:      noteID,
+		Filename:    filename,
+		ContentType: contentType,
+		Size:        upload.Size,
+		BlobKey:     key,
+		CreatedAt:   time.Now(),
+	}
+	attachment.ID, err = s.attachmentsRepo.CreateAttachment(ctx, attachment)
+	if err != nil {
+		s.deleteBlob(ctx, key)
+		return core.Attachment{}, err
+	}
+	return attachment, nil
+}
+
+func (s *AttachmentsService) List(ctx context.Context, userID, noteID int64) ([]core.Attachment, error) {
+	if _, err := s.notesRepo.GetById(ctx, userID, noteID); err != nil {
+		return nil, err
+	}
+	return s.attachmentsRepo.ListAttachments(ctx, noteID)
+}
+
+func (s *AttachmentsService) Open(ctx context.Context, userID, noteID, id int64) (core.Attachment, io.ReadCloser, error) {
+	if _, err := s.notesRepo.GetById(ctx, userID, noteID); err != nil {
+		return core.Attachment{}, nil, err
+	}
+	attachment, err := s.attachmentsRepo.GetAttachment(ctx, noteID, id)
+	if err != nil {
+		return core.Attachment{}, nil, err
+	}
+	content, err := s.blobs.Get(ctx, attachment.BlobKey)
+	if err != nil {
+		return core.Attachment{}, nil, err
+	}
+	return attachment, content, nil
+}
+
+func (s *AttachmentsService) Delete(ctx context.Context, userID, noteID, id int64) error {
+	note, err := s.notesRepo.GetById(ctx, userID, noteID)
+	if err != nil {
+		return err
+	}
+	if !note.CanWrite() {
+		return core.ErrForbidden
+	}
+	attachment, err := s.attachmentsRepo.GetAttachment(ctx, noteID, id)
+	if err != nil {
+		return err
+	}
+	if err := s.attachmentsRepo.DeleteAttachment(ctx, noteID, id); err != nil {
+		return err
+	}
+	s.deleteBlob(ctx, attachment.BlobKey)
+	return nil
+}
+
+// deleteBlob удаляет содержимое, на которое больше нет ссылок. Ошибка только логируется:
+// метаданные уже удалены, а осиротевший объект не виден пользователям.
+func (s *AttachmentsService) deleteBlob(ctx context.Context, key string) {
+	if err := s.blobs.Delete(context.WithoutCancel(ctx), key); err != nil {
+		log.Printf("[ERROR] failed to delete blob %s: %v", key, err)
+	}
+}
+
+func (s *AttachmentsService) allowedType(contentType string) bool {
+	for _, allowed := range s.config.AllowedTypes {
+		if allowed == contentType {
+			return true
+		}
+		if prefix, ok := strings.CutSuffix(allowed, "/*"); ok && strings.HasPrefix(contentType, prefix+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// sanitizeFilename оставляет только имя файла без каталогов и управляющих символов
+func sanitizeFilename(name string) string {
+	name = path.Base(strings.ReplaceAll(name, `\`, "/"))
+	name = strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) || r == '"' {
+			return -1
+		}
+		return r
+	}, name)
+	name = strings.TrimSpace(name)
+	if name == "." || name == "/" {
+		return ""
+	}
+	if len(name) > 255 {
+		name = name[:255]
+	}
+	return name
+}
+
+func randomKey() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,83 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/icestormerrr/notes-api/internal/core"
+	"github.com/icestormerrr/notes-api/internal/repos"
+)
+
+var pngHeader = []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+
+func upload(name string, content []byte) core.AttachmentUpload {
+	return core.AttachmentUpload{Filename: name, Size: int64(len(content)), Content: bytes.NewReader(content)}
+}
+
+func TestAttachmentsUpload(t *testing.T) {
+	ctx := context.Background()
+	repo := repos.NewNoteRepoMem()
+	blobs, err := repos.NewLocalBlobStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	notes := NewNotesService(repo, repo, blobs)
+	svc := NewAttachmentsService(AttachmentsConfig{MaxSize: 64, AllowedTypes: []string{"image/*"}}, repo, repo, blobs)
+	const owner, reader = int64(1), int64(2)
+
+	noteID, err := notes.CreateNote(ctx, owner, core.NoteCreatePayload{Title: "photos"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := notes.ShareNote(ctx, owner, noteID, reader, core.AccessRead); err != nil {
+		t.Fatal(err)
+	}
+
+	for name, tc := range map[string]struct {
+		userID int64
+		upload core.AttachmentUpload
+		want   error
+	}{
+		"too large":    {owner, upload("big.png", append(pngHeader, make([]byte, 64)...)), core.ErrTooLarge},
+		"not an image": {owner, upload("doc.png", []byte("%PDF-1.7 fake")), core.ErrUnsupportedMediaType},
+		"empty":        {owner, upload("empty.png", nil), core.ErrValidation},
+		"read access":  {reader, upload("a.png", pngHeader), core.ErrForbidden},
+	} {
+		if _, err := svc.Upload(ctx, tc.userID, noteID, tc.upload); !errors.Is(err, tc.want) {
+			t.Fatalf("%s: want %v, got %v", name, tc.want, err)
+		}
+	}
+
+	attachment, err := svc.Upload(ctx, owner, noteID, upload(`..\..\cat.png`, pngHeader))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if attachment.Filename != "cat.png" || attachment.ContentType != "image/png" {
+		t.Fatalf("unexpected attachment %+v", attachment)
+	}
+
+	// читатель видит вложения, но не может их удалять
+	_, content, err := svc.Open(ctx, reader, noteID, attachment.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := io.ReadAll(content)
+	content.Close()
+	if !bytes.Equal(data, pngHeader) {
+		t.Fatalf("content mismatch: %q", data)
+	}
+	if err := svc.Delete(ctx, reader, noteID, attachment.ID); !errors.Is(err, core.ErrForbidden) {
+		t.Fatalf("reader delete: want forbidden, got %v", err)
+	}
+
+	// удаление заметки удаляет и содержимое вложений
+	if err := notes.Delete(ctx, owner, noteID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := blobs.Get(ctx, attachment.BlobKey); !errors.Is(err, core.ErrNotFound) {
+		t.Fatalf("blob must be deleted with note, got %v", err)
+	}
+}
@@ -1,53 +1,104 @@
 package services
 
 import (
-	"errors"
+	"context"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/icestormerrr/notes-api/internal/core"
 )
 
 type NotesService struct {
-	notesRepo core.NotesRepository
+	notesRepo       core.NotesRepository
+	attachmentsRepo core.AttachmentsRepository
+	blobs           core.BlobStore
 }
 
-func NewNotesService(notesRepo core.NotesRepository) *NotesService {
-	return &NotesService{notesRepo: notesRepo}
+func NewNotesService(notesRepo core.NotesRepository, attachmentsRepo core.AttachmentsRepository, blobs core.BlobStore) *NotesService {
+	return &NotesService{notesRepo: notesRepo, attachmentsRepo: attachmentsRepo, blobs: blobs}
 }
 
-func (s *NotesService) GetAll() ([]*core.Note, error) {
-	return s.notesRepo.GetAll()
+func (s *NotesService) List(ctx context.Context, userID int64, filter core.NoteFilter) (core.NotePage, error) {
+	filter, err := normalizeFilter(filter)
+	if err != nil {
+		return core.NotePage{}, err
+	}
+	return s.notesRepo.List(ctx, userID, filter)
+}
+
+// normalizeFilter подставляет значения по умолчанию и проверяет параметры списка
+func normalizeFilter(f core.NoteFilter) (core.NoteFilter, error) {
+	var fields []core.FieldError
+
+	if f.Limit == 0 {
+		f.Limit = core.DefaultNotesLimit
+	}
+	if f.Limit < 0 || f.Limit > core.MaxNotesLimit {
+		fields = append(fields, core.FieldError{Field: "limit", Message: fmt.Sprintf("must be between 1 and %d", core.MaxNotesLimit)})
+	}
+
+	if f.Sort == "" {
+		f.Sort = core.SortID
+	}
+	if !slices.Contains(core.NoteSortFields, f.SortField()) {
+		fields = append(fields, core.FieldError{Field: "sort", Message: "must be one of " + strings.Join(core.NoteSortFields, ", ") + " with optional - prefix"})
+	}
+
+	if f.CreatedAfter != nil && f.CreatedBefore != nil && !f.CreatedAfter.Before(*f.CreatedBefore) {
+		fields = append(fields, core.FieldError{Field: "created_before", Message: "must be later than created_after"})
+	}
+
+	if f.Cursor != "" {
+		cursor, err := core.DecodeNoteCursor(f.Cursor)
+		if err != nil {
+			fields = append(fields, core.FieldError{Field: "cursor", Message: "is malformed"})
+		} else if cursor.Sort != f.Sort {
+			fields = append(fields, core.FieldError{Field: "cursor", Message: "was issued for another sort order"})
+		}
+	}
+
+	if len(fields) > 0 {
+		return f, &core.ValidationError{Fields: fields}
+	}
+	return f, nil
 }
 
-func (s *NotesService) GetById(id int64) (core.Note, error) {
-	return s.notesRepo.GetById(id)
+func (s *NotesService) GetById(ctx context.Context, userID, id int64) (core.Note, error) {
+	return s.notesRepo.GetById(ctx, userID, id)
 }
 
-func (s *NotesService) CreateNote(payload core.NoteCreatePayload) (int64, error) {
+func (s *NotesService) CreateNote(ctx context.Context, userID int64, payload core.NoteCreatePayload) (int64, error) {
 	if payload.Title == "" {
-		return 0, errors.New("title cannot be empty")
+		return 0, core.NewValidationError("title", "cannot be empty")
 	}
 
 	now := time.Now()
 
 	n := core.Note{
+		OwnerID:   userID,
 		Title:     payload.Title,
 		Content:   payload.Content,
 		CreatedAt: now,
 		UpdatedAt: now,
 	}
 
-	return s.notesRepo.Create(n)
+	return s.notesRepo.Create(ctx, n)
 }
 
-func (s *NotesService) UpdateNote(id int64, payload core.NoteUpdatePayload) error {
-	note, err := s.notesRepo.GetById(id)
-	if err != nil {
-		return errors.New("note not found")
+func (s *NotesService) UpdateNote(ctx context.Context, userID, id int64, payload core.NoteUpdatePayload) error {
+	if payload.Title != nil && *payload.Title == "" {
+		return core.NewValidationError("title", "cannot be empty")
 	}
 
-	if payload.Title != nil && *payload.Title == "" {
-		return errors.New("title cannot be empty")
+	note, err := s.notesRepo.GetById(ctx, userID, id)
+	if err != nil {
+		return err
+	}
+	if !note.CanWrite() {
+		return core.ErrForbidden
 	}
 
 	if payload.Title != nil {
@@ -60,9 +111,72 @@ func (s *NotesService) UpdateNote(id int64, payload core.NoteUpdatePayload) erro
 
 	note.UpdatedAt = time.Now()
 
-	return s.notesRepo.Update(note)
+	return s.notesRepo.Update(ctx, userID, note)
+}
+
+func (s *NotesService) Delete(ctx context.Context, userID, id int64) error {
+	if _, err := s.ownedNote(ctx, userID, id); err != nil {
+		return err
+	}
+	// метаданные вложений удаляются вместе с заметкой, содержимое в BlobStore — отдельно
+	attachments, err := s.attachmentsRepo.ListAttachments(ctx, id)
+	if err != nil {
+		return err
+	}
+	if err := s.notesRepo.Delete(ctx, userID, id); err != nil {
+		return err
+	}
+	for _, a := range attachments {
+		if err := s.blobs.Delete(context.WithoutCancel(ctx), a.BlobKey); err != nil {
+			log.Printf("[ERROR] failed to delete blob %s of note %d: %v", a.BlobKey, id, err)
+		}
+	}
+	return nil
+}
+
+func (s *NotesService) ShareNote(ctx context.Context, userID, noteID, targetID int64, permission string) error {
+	var fields []core.FieldError
+	if targetID <= 0 {
+		fields = append(fields, core.FieldError{Field: "user_id", Message: "must be a positive integer"})
+	} else if targetID == userID {
+		fields = append(fields, core.FieldError{Field: "user_id", Message: "cannot share a note with its owner"})
+	}
+	if !core.ValidSharePermission(permission) {
+		fields = append(fields, core.FieldError{Field: "permission", Message: "must be read or write"})
+	}
+	if len(fields) > 0 {
+		return &core.ValidationError{Fields: fields}
+	}
+
+	if _, err := s.ownedNote(ctx, userID, noteID); err != nil {
+		return err
+	}
+	return s.notesRepo.Share(ctx, core.NoteShare{NoteID: noteID, UserID: targetID, Permission: permission, CreatedAt: time.Now()})
+}
+
+func (s *NotesService) UnshareNote(ctx context.Context, userID, noteID, targetID int64) error {
+	if _, err := s.ownedNote(ctx, userID, noteID); err != nil {
+		return err
+	}
+	return s.notesRepo.Unshare(ctx, noteID, targetID)
 }
 
-func (s *NotesService) Delete(id int64) error {
-	return s.notesRepo.Delete(id)
+func (s *NotesService) ListShares(ctx context.Context, userID, noteID int64) ([]core.NoteShare, error) {
+	if _, err := s.ownedNote(ctx, userID, noteID); err != nil {
+		return nil, err
+	}
+	return s.notesRepo.ListShares(ctx, noteID)
+}
+
+// ownedNote заметка, если userID её владелец. Недоступная заметка — ErrNoteNotFound,
+// заметка, выданная через доступ, — ErrForbidden
+func (s *NotesService) ownedNote(ctx context.Context, userID, id int64) (core.Note, error) {
+	note, err := s.notesRepo.GetById(ctx, userID, id)
+	if err != nil {
+		return core.Note{}, err
+	}
+	if note.Access != core.AccessOwner {
+		return core.Note{}, core.ErrForbidden
+	}
+	return note, nil
 }
@@ -0,0 +1,99 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/icestormerrr/notes-api/internal/core"
+	"github.com/icestormerrr/notes-api/internal/repos"
+)
+
+func newTestNotesService(t *testing.T) *NotesService {
+	t.Helper()
+	repo := repos.NewNoteRepoMem()
+	blobs, err := repos.NewLocalBlobStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewNotesService(repo, repo, blobs)
+}
+
+func TestListValidation(t *testing.T) {
+	ctx := context.Background()
+	svc := newTestNotesService(t)
+	for i := 0; i < 3; i++ {
+		if _, err := svc.CreateNote(ctx, 1, core.NoteCreatePayload{Title: "note"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	page, err := svc.List(ctx, 1, core.NoteFilter{Limit: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(page.Items) != 2 || page.NextCursor == "" {
+		t.Fatalf("unexpected first page %+v", page)
+	}
+
+	now := time.Now()
+	for name, filter := range map[string]core.NoteFilter{
+		"limit too big":   {Limit: core.MaxNotesLimit + 1},
+		"unknown sort":    {Sort: "content"},
+		"empty range":     {CreatedAfter: &now, CreatedBefore: &now},
+		"garbage cursor":  {Cursor: "???"},
+		"cursor for sort": {Sort: "-id", Cursor: page.NextCursor},
+	} {
+		var validation *core.ValidationError
+		if _, err := svc.List(ctx, 1, filter); !errors.As(err, &validation) {
+			t.Fatalf("%s: want validation error, got %v", name, err)
+		}
+	}
+}
+
+func TestSharingPermissions(t *testing.T) {
+	ctx := context.Background()
+	svc := newTestNotesService(t)
+	const owner, friend, stranger = int64(1), int64(2), int64(3)
+
+	id, err := svc.CreateNote(ctx, owner, core.NoteCreatePayload{Title: "plan"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	title := "changed"
+	update := core.NoteUpdatePayload{Title: &title}
+
+	if err := svc.ShareNote(ctx, owner, id, owner, core.AccessRead); !errors.Is(err, core.ErrValidation) {
+		t.Fatalf("share with self: want validation error, got %v", err)
+	}
+	if err := svc.ShareNote(ctx, owner, id, friend, "admin"); !errors.Is(err, core.ErrValidation) {
+		t.Fatalf("unknown permission: want validation error, got %v", err)
+	}
+	if err := svc.ShareNote(ctx, stranger, id, stranger+1, core.AccessRead); !errors.Is(err, core.ErrNotFound) {
+		t.Fatalf("stranger share: want not found, got %v", err)
+	}
+
+	if err := svc.ShareNote(ctx, owner, id, friend, core.AccessRead); err != nil {
+		t.Fatal(err)
+	}
+	if err := svc.UpdateNote(ctx, friend, id, update); !errors.Is(err, core.ErrForbidden) {
+		t.Fatalf("read access update: want forbidden, got %v", err)
+	}
+
+	if err := svc.ShareNote(ctx, owner, id, friend, core.AccessWrite); err != nil {
+		t.Fatal(err)
+	}
+	if err := svc.UpdateNote(ctx, friend, id, update); err != nil {
+		t.Fatalf("write access update: %v", err)
+	}
+	if err := svc.ShareNote(ctx, friend, id, stranger, core.AccessRead); !errors.Is(err, core.ErrForbidden) {
+		t.Fatalf("only owner may share: want forbidden, got %v", err)
+	}
+	if err := svc.Delete(ctx, friend, id); !errors.Is(err, core.ErrForbidden) {
+		t.Fatalf("only owner may delete: want forbidden, got %v", err)
+	}
+	if _, err := svc.GetById(ctx, stranger, id); !errors.Is(err, core.ErrNotFound) {
+		t.Fatalf("stranger get: want not found, got %v", err)
+	}
+}
@@ -3,12 +3,48 @@ package config
 import (
 	"log"
 	"os"
+	"strconv"
+	"strings"
 
 	"github.com/joho/godotenv"
 )
 
+const (
+	DriverMemory   = "memory"
+	DriverPostgres = "postgres"
+	DriverSQLite   = "sqlite"
+
+	BlobStoreLocal = "local"
+	BlobStoreS3    = "s3"
+)
+
+// defaultAttachmentTypes разрешённые по умолчанию типы вложений
+var defaultAttachmentTypes = []string{"image/png", "image/jpeg", "image/gif", "image/webp", "application/pdf", "text/plain"}
+
 type Config struct {
-	Port string
+	Port        string
+	DBDriver    string
+	DatabaseURL string
+	SQLitePath  string
+	// Владелец заметок, созданных до появления аутентификации (0 — не назначать)
+	LegacyNotesOwnerID int64
+	// Проверка токенов: JWKSURL (токены pz10-auth) либо JWTSecret (HS256)
+	JWKSURL     string
+	JWTSecret   string
+	JWTIssuer   string
+	JWTAudience string
+	// Хранилище содержимого вложений: local (BlobDir) либо S3-совместимое
+	BlobStore   string
+	BlobDir     string
+	S3Endpoint  string
+	S3Region    string
+	S3Bucket    string
+	S3AccessKey string
+	S3SecretKey string
+	S3UseSSL    bool
+	// Ограничения вложений
+	AttachmentsMaxSize      int64
+	AttachmentsAllowedTypes []string
 }
 
 func Load() Config {
@@ -22,7 +58,101 @@ func Load() Config {
 		port = "8080"
 	}
 
+	driver := os.Getenv("DB_DRIVER")
+	if driver == "" {
+		driver = DriverMemory
+	}
+
+	databaseURL := os.Getenv("DATABASE_URL")
+	switch driver {
+	case DriverMemory, DriverSQLite:
+	case DriverPostgres:
+		if databaseURL == "" {
+			log.Fatal("DATABASE_URL is required for DB_DRIVER=postgres")
+		}
+	default:
+		log.Fatalf("unknown DB_DRIVER %q (memory, postgres, sqlite)", driver)
+	}
+
+	sqlitePath := os.Getenv("SQLITE_PATH")
+	if sqlitePath == "" {
+		sqlitePath = "notes.db"
+	}
+
+	var legacyOwnerID int64
+	if v := os.Getenv("LEGACY_NOTES_OWNER_ID"); v != "" {
+		legacyOwnerID, err = strconv.ParseInt(v, 10, 64)
+		if err != nil || legacyOwnerID <= 0 {
+			log.Fatalf("invalid LEGACY_NOTES_OWNER_ID %q", v)
+		}
+	}
+
+	jwksURL := os.Getenv("JWT_JWKS_URL")
+	jwtSecret := os.Getenv("JWT_SECRET")
+	if jwksURL == "" && jwtSecret == "" {
+		log.Fatal("JWT_JWKS_URL or JWT_SECRET is required")
+	}
+
+	blobStore := os.Getenv("BLOB_STORE")
+	if blobStore == "" {
+		blobStore = BlobStoreLocal
+	}
+	switch blobStore {
+	case BlobStoreLocal:
+	case BlobStoreS3:
+		if os.Getenv("S3_ENDPOINT") == "" || os.Getenv("S3_BUCKET") == "" {
+			log.Fatal("S3_ENDPOINT and S3_BUCKET are required for BLOB_STORE=s3")
+		}
+	default:
+		log.Fatalf("unknown BLOB_STORE %q (local, s3)", blobStore)
+	}
+
+	blobDir := os.Getenv("BLOB_DIR")
+	if blobDir == "" {
+		blobDir = "data/attachments"
+	}
+
+	maxSize := int64(10 << 20)
+	if v := os.Getenv("ATTACHMENTS_MAX_SIZE"); v != "" {
+		maxSize, err = strconv.ParseInt(v, 10, 64)
+		if err != nil || maxSize <= 0 {
+			log.Fatalf("invalid ATTACHMENTS_MAX_SIZE %q", v)
+		}
+	}
+
+	allowedTypes := defaultAttachmentTypes
+	if v := os.Getenv("ATTACHMENTS_ALLOWED_TYPES"); v != "" {
+		allowedTypes = nil
+		for _, t := range strings.Split(v, ",") {
+			if t = strings.TrimSpace(t); t != "" {
+				allowedTypes = append(allowedTypes, t)
+			}
+		}
+	}
+
 	return Config{
-		Port: ":" + port,
+		Port:        ":" + port,
+		DBDriver:    driver,
+		DatabaseURL: databaseURL,
+		SQLitePath:  sqlitePath,
+
+		LegacyNotesOwnerID: legacyOwnerID,
+
+		JWKSURL:     jwksURL,
+		JWTSecret:   jwtSecret,
+		JWTIssuer:   os.Getenv("JWT_ISSUER"),
+		JWTAudience: os.Getenv("JWT_AUDIENCE"),
+
+		BlobStore:   blobStore,
+		BlobDir:     blobDir,
+		S3Endpoint:  os.Getenv("S3_ENDPOINT"),
+		S3Region:    os.Getenv("S3_REGION"),
+		S3Bucket:    os.Getenv("S3_BUCKET"),
+		S3AccessKey: os.Getenv("S3_ACCESS_KEY"),
+		S3SecretKey: os.Getenv("S3_SECRET_KEY"),
+		S3UseSSL:    os.Getenv("S3_USE_SSL") == "true",
+
+		AttachmentsMaxSize:      maxSize,
+		AttachmentsAllowedTypes: allowedTypes,
 	}
 }
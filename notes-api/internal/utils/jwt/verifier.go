@@ -0,0 +1,82 @@
+package jwt
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/icestormerrr/notes-api/internal/core"
+)
+
+// VerifierConfig источник ключей проверки: общий секрет HS256 либо JWKS сервиса авторизации (pz10-auth).
+// Issuer и Audience проверяются, если заданы.
+type VerifierConfig struct {
+	Secret   string
+	JWKSURL  string
+	Issuer   string
+	Audience string
+}
+
+// Verifier проверяет access токены и реализует core.TokenVerifier
+type Verifier struct {
+	parser *jwt.Parser
+	secret []byte
+	keys   *jwks
+}
+
+func NewVerifier(config VerifierConfig) (*Verifier, error) {
+	opts := []jwt.ParserOption{jwt.WithExpirationRequired(), jwt.WithIssuedAt()}
+	if config.Issuer != "" {
+		opts = append(opts, jwt.WithIssuer(config.Issuer))
+	}
+	if config.Audience != "" {
+		opts = append(opts, jwt.WithAudience(config.Audience))
+	}
+
+	v := &Verifier{}
+	switch {
+	case config.JWKSURL != "":
+		v.keys = newJWKS(config.JWKSURL)
+		opts = append(opts, jwt.WithValidMethods([]string{"RS256", "ES256", "EdDSA"}))
+	case config.Secret != "":
+		v.secret = []byte(config.Secret)
+		opts = append(opts, jwt.WithValidMethods([]string{"HS256"}))
+	default:
+		return nil, errors.New("either JWT secret or JWKS URL is required")
+	}
+	v.parser = jwt.NewParser(opts...)
+	return v, nil
+}
+
+// Verify возвращает ID пользователя из claim sub. Refresh токены и токены клиентов (sub не число) отклоняются.
+func (v *Verifier) Verify(ctx context.Context, tokenString string) (int64, error) {
+	claims := jwt.MapClaims{}
+	_, err := v.parser.ParseWithClaims(tokenString, claims, func(t *jwt.Token) (any, error) {
+		if v.keys == nil {
+			return v.secret, nil
+		}
+		kid, _ := t.Header["kid"].(string)
+		return v.keys.key(ctx, kid)
+	})
+	if err != nil {
+		return 0, fmt.Errorf("%w: %v", core.ErrUnauthorized, err)
+	}
+
+	if typ, _ := claims["typ"].(string); typ != "" && typ != "access" {
+		return 0, fmt.Errorf("%w: %s token", core.ErrUnauthorized, typ)
+	}
+
+	var userID int64
+	switch sub := claims["sub"].(type) {
+	case float64:
+		userID = int64(sub)
+	case string:
+		userID, _ = strconv.ParseInt(sub, 10, 64)
+	}
+	if userID <= 0 {
+		return 0, fmt.Errorf("%w: subject is not a user", core.ErrUnauthorized)
+	}
+	return userID, nil
+}
@@ -0,0 +1,128 @@
+package jwt
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/icestormerrr/notes-api/internal/core"
+)
+
+func claims(extra jwt.MapClaims) jwt.MapClaims {
+	c := jwt.MapClaims{
+		"sub": 7, "typ": "access", "iss": "auth", "aud": "notes",
+		"iat": time.Now().Unix(), "exp": time.Now().Add(time.Minute).Unix(),
+	}
+	for k, v := range extra {
+		c[k] = v
+	}
+	return c
+}
+
+func TestHMACVerifier(t *testing.T) {
+	v, err := NewVerifier(VerifierConfig{Secret: "secret", Issuer: "auth", Audience: "notes"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sign := func(c jwt.MapClaims, secret string) string {
+		s, err := jwt.NewWithClaims(jwt.SigningMethodHS256, c).SignedString([]byte(secret))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return s
+	}
+
+	userID, err := v.Verify(context.Background(), sign(claims(nil), "secret"))
+	if err != nil || userID != 7 {
+		t.Fatalf("want user 7, got %d %v", userID, err)
+	}
+
+	for name, token := range map[string]string{
+		"wrong secret":   sign(claims(nil), "other"),
+		"expired":        sign(claims(jwt.MapClaims{"exp": time.Now().Add(-time.Minute).Unix()}), "secret"),
+		"refresh token":  sign(claims(jwt.MapClaims{"typ": "refresh"}), "secret"),
+		"client subject": sign(claims(jwt.MapClaims{"sub": "billing-client"}), "secret"),
+		"wrong audience": sign(claims(jwt.MapClaims{"aud": "other"}), "secret"),
+		"garbage":        "not-a-token",
+	} {
+		if _, err := v.Verify(context.Background(), token); !errors.Is(err, core.ErrUnauthorized) {
+			t.Fatalf("%s: want ErrUnauthorized, got %v", name, err)
+		}
+	}
+}
+
+// jwksServer отдаёт набор ключей, который тест может подменить (ротация ключа)
+type jwksServer struct {
+	mu       sync.Mutex
+	keys     map[string]ed25519.PublicKey
+	requests int
+}
+
+func (s *jwksServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests++
+	set := struct {
+		Keys []jwk `json:"keys"`
+	}{}
+	for kid, pub := range s.keys {
+		set.Keys = append(set.Keys, jwk{Kty: "OKP", Crv: "Ed25519", Kid: kid, Use: "sig", X: base64.RawURLEncoding.EncodeToString(pub)})
+	}
+	json.NewEncoder(w).Encode(set)
+}
+
+func TestJWKSVerifierRotation(t *testing.T) {
+	pub1, priv1, _ := ed25519.GenerateKey(rand.Reader)
+	pub2, priv2, _ := ed25519.GenerateKey(rand.Reader)
+	keys := &jwksServer{keys: map[string]ed25519.PublicKey{"k1": pub1}}
+	srv := httptest.NewServer(keys)
+	defer srv.Close()
+
+	v, err := NewVerifier(VerifierConfig{JWKSURL: srv.URL, Issuer: "auth"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sign := func(kid string, priv ed25519.PrivateKey) string {
+		tok := jwt.NewWithClaims(jwt.SigningMethodEdDSA, claims(nil))
+		tok.Header["kid"] = kid
+		s, err := tok.SignedString(priv)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return s
+	}
+
+	if _, err := v.Verify(context.Background(), sign("k1", priv1)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := v.Verify(context.Background(), sign("k1", priv2)); !errors.Is(err, core.ErrUnauthorized) {
+		t.Fatalf("forged signature: want ErrUnauthorized, got %v", err)
+	}
+
+	// новый ключ появился после ротации: неизвестный kid вызывает повторную загрузку,
+	// но не чаще jwksMinRefreshInterval
+	keys.mu.Lock()
+	keys.keys["k2"] = pub2
+	keys.mu.Unlock()
+	if _, err := v.Verify(context.Background(), sign("k2", priv2)); !errors.Is(err, core.ErrUnauthorized) {
+		t.Fatalf("refresh must be rate limited, got %v", err)
+	}
+	v.keys.mu.Lock()
+	v.keys.fetchedAt = time.Now().Add(-jwksMinRefreshInterval)
+	v.keys.mu.Unlock()
+	if _, err := v.Verify(context.Background(), sign("k2", priv2)); err != nil {
+		t.Fatalf("rotated key: %v", err)
+	}
+	if keys.requests != 2 {
+		t.Fatalf("want 2 jwks requests, got %d", keys.requests)
+	}
+}
@@ -0,0 +1,23 @@
+package markdown
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRenderSanitizes(t *testing.T) {
+	html, err := Render("# Title\n\n**bold** [link](https://example.com) [bad](javascript:alert(1))\n\n<script>alert(1)</script>\n\n<img src=x onerror=alert(1)>\n\n- [x] done")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"<h1>Title</h1>", "<strong>bold</strong>", `href="https://example.com"`, `rel="nofollow noopener"`, "checked"} {
+		if !strings.Contains(html, want) {
+			t.Fatalf("want %q in %s", want, html)
+		}
+	}
+	for _, banned := range []string{"<script", "javascript:", "onerror"} {
+		if strings.Contains(html, banned) {
+			t.Fatalf("unsafe %q in %s", banned, html)
+		}
+	}
+}
@@ -1,9 +1,14 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"log"
 	"net/http"
+	"strings"
+	"time"
 
+	"github.com/icestormerrr/pz10-auth/internal/core"
 	router "github.com/icestormerrr/pz10-auth/internal/delivery/http"
 	"github.com/icestormerrr/pz10-auth/internal/delivery/http/handlers"
 	"github.com/icestormerrr/pz10-auth/internal/repos"
@@ -22,19 +27,63 @@ func main() {
 		RedisPassword: cfg.RedisPassword,
 		RedisDB:       cfg.RedisDB,
 	})
-	userRepo := repos.NewUserInMemoryRepo()
-	jwtValidator, err := jwt.NewRS256TokenManager(cfg.PrivateRsaKey, cfg.PublicRsaKey)
+	userRepo, err := repos.NewUserPostgresRepo(repos.UserPostgresRepoConfig{
+		DatabaseURL: cfg.DatabaseURL,
+		BcryptCost:  cfg.BcryptCost,
+	})
+	if err != nil {
+		log.Fatal("cannot connect to postgres: ", err)
+	}
+	defer userRepo.Close()
+	oauthRepo, err := repos.NewOAuthPostgresRepo(userRepo.DB())
+	if err != nil {
+		log.Fatal("cannot migrate oauth tables: ", err)
+	}
+	if err := seedAdmin(userRepo, cfg.AdminEmail, cfg.AdminPassword); err != nil {
+		log.Fatal("cannot create admin: ", err)
+	}
+
+	jwtValidator, err := jwt.NewKeyRingTokenManager(jwt.KeyRingConfig{
+		Alg:         cfg.JWTAlg,
+		File:        cfg.JWTKeysFile,
+		RotateEvery: cfg.KeyRotationInterval,
+		Retention:   cfg.KeyRetention,
+		Issuer:      cfg.Issuer,
+		Audience:    "pz10-clients",
+	}, cfg.PrivateRsaKey)
 	if err != nil {
-		log.Fatal("cannot parse RSA keys: ", err)
+		log.Fatal("cannot load signing keys: ", err)
 	}
+	go jwtValidator.Run(context.Background(), time.Hour)
 
-	userService := services.NewUserService(userRepo)
+	userService := services.NewUserService(userRepo, sessionRepo)
 	authService := services.NewAuthService(services.AuthServiceConfig{AccessTTL: cfg.AccessTTL, RefreshTTL: cfg.RefreshTTL, MaxLoginAttempts: 2}, userRepo, sessionRepo, jwtValidator)
+	oauthService := services.NewOAuthService(services.OAuthServiceConfig{
+		AccessTTL:        cfg.AccessTTL,
+		RefreshTTL:       cfg.RefreshTTL,
+		CodeTTL:          time.Minute,
+		MaxLoginAttempts: 2,
+	}, oauthRepo, userRepo, sessionRepo, jwtValidator)
 
 	userHandler := handlers.NewUserHandler(userService)
 	authHandler := handlers.NewAuthHandler(authService)
+	oauthHandler := handlers.NewOAuthHandler(oauthService)
+
+	wellKnownHandler := handlers.NewWellKnownHandler(jwtValidator)
 
-	mux := router.Build(authHandler, userHandler, jwtValidator)
+	mux := router.Build(authHandler, userHandler, oauthHandler, wellKnownHandler, jwtValidator, authService)
 	log.Println("listening on", cfg.Port)
 	log.Fatal(http.ListenAndServe(cfg.Port, mux))
 }
+
+// seedAdmin создаёт администратора из ADMIN_EMAIL/ADMIN_PASSWORD, если его ещё нет
+func seedAdmin(users core.UserRepo, email, password string) error {
+	if email == "" || password == "" {
+		return nil
+	}
+	u := core.User{Email: strings.ToLower(email), Role: core.RoleAdmin}
+	if _, err := users.Create(u, password); err != nil && !errors.Is(err, repos.ErrEmailTaken) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,94 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"time"
+)
+
+var ErrInvalidClaims = errors.New("invalid_claims")
+
+// Claims проверенное содержимое токена
+type Claims struct {
+	UserID    int64
+	Email     string
+	Role      string
+	Type      string
+	SessionID string
+	ID        string
+	ClientID  string
+	Scope     string
+	IssuedAt  time.Time
+	ExpiresAt time.Time
+}
+
+// NewClaims разбирает клеймы, полученные от TokenManager.Parse.
+// Числа в JSON приходят как float64; токен без sub или exp считается некорректным.
+func NewClaims(m map[string]any) (Claims, error) {
+	sub, ok := m["sub"].(float64)
+	if !ok || sub <= 0 {
+		return Claims{}, ErrInvalidClaims
+	}
+	exp, ok := m["exp"].(float64)
+	if !ok {
+		return Claims{}, ErrInvalidClaims
+	}
+
+	c := Claims{
+		UserID:    int64(sub),
+		IssuedAt:  IssuedAt(m),
+		ExpiresAt: time.Unix(int64(exp), 0),
+	}
+	c.Email, _ = m["email"].(string)
+	c.Role, _ = m["role"].(string)
+	c.Type, _ = m["typ"].(string)
+	c.SessionID, _ = m["sid"].(string)
+	c.ID, _ = m["jti"].(string)
+	c.ClientID, _ = m["client_id"].(string)
+	c.Scope, _ = m["scope"].(string)
+	return c, nil
+}
+
+// IssuedAt время выпуска токена с точностью до миллисекунд (клейм iat_ms); у токенов
+// прежних версий есть только iat в секундах
+func IssuedAt(m map[string]any) time.Time {
+	if ms, ok := m["iat_ms"].(float64); ok {
+		return time.UnixMilli(int64(ms))
+	}
+	iat, _ := m["iat"].(float64)
+	return time.Unix(int64(iat), 0)
+}
+
+func (c Claims) IsRefresh() bool { return c.Type == TokenRefresh }
+
+// Can есть ли у роли владельца токена разрешение perm. Токен, выданный OAuth-клиенту,
+// дополнительно ограничен scopes, на которые пользователь дал согласие.
+func (c Claims) Can(perm string) bool {
+	if !RoleHasPermission(c.Role, perm) {
+		return false
+	}
+	return c.ClientID == "" || slices.Contains(strings.Fields(c.Scope), perm)
+}
+
+// Owns принадлежит ли ресурс пользователя ownerID владельцу токена
+func (c Claims) Owns(ownerID int64) bool { return c.UserID == ownerID }
+
+// CanAccess владелец ресурса с разрешением ownPerm или пользователь с разрешением anyPerm на чужие ресурсы.
+// Для владельца тоже проверяется разрешение: токен OAuth-клиента ограничен scopes и на своих ресурсах.
+func (c Claims) CanAccess(ownPerm, anyPerm string, ownerID int64) bool {
+	return (c.Owns(ownerID) && c.Can(ownPerm)) || c.Can(anyPerm)
+}
+
+type claimsCtxKey struct{}
+
+func ContextWithClaims(ctx context.Context, c Claims) context.Context {
+	return context.WithValue(ctx, claimsCtxKey{}, c)
+}
+
+// ClaimsFromContext клеймы, сохранённые middleware.AuthN
+func ClaimsFromContext(ctx context.Context) (Claims, bool) {
+	c, ok := ctx.Value(claimsCtxKey{}).(Claims)
+	return c, ok
+}
@@ -0,0 +1,60 @@
+package core
+
+import "testing"
+
+func TestNewClaims(t *testing.T) {
+	c, err := NewClaims(map[string]any{"sub": float64(5), "exp": float64(100), "role": RoleUser, "typ": TokenAccess, "jti": "x"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.UserID != 5 || c.ID != "x" || c.IsRefresh() || c.ExpiresAt.Unix() != 100 {
+		t.Fatalf("unexpected claims %+v", c)
+	}
+
+	// некорректные клеймы не должны приводить к панике
+	for _, m := range []map[string]any{
+		{},
+		{"sub": "5", "exp": float64(100)},
+		{"sub": float64(5)},
+	} {
+		if _, err := NewClaims(m); err != ErrInvalidClaims {
+			t.Fatalf("want ErrInvalidClaims for %v, got %v", m, err)
+		}
+	}
+}
+
+func TestPermissions(t *testing.T) {
+	user := Claims{UserID: 1, Role: RoleUser}
+	admin := Claims{UserID: 2, Role: RoleAdmin}
+
+	if !user.CanAccess(PermProfileRead, PermUsersRead, 1) {
+		t.Fatal("owner must access own resource")
+	}
+	if user.CanAccess(PermProfileRead, PermUsersRead, 2) {
+		t.Fatal("user must not access foreign resource")
+	}
+	if !admin.CanAccess(PermProfileRead, PermUsersRead, 1) {
+		t.Fatal("admin must access any user")
+	}
+	if user.Can(PermKeysRotate) || (Claims{Role: "unknown"}).Can(PermProfileRead) {
+		t.Fatal("unexpected permission")
+	}
+
+	// токен стороннего клиента не шире запрошенных scopes, даже у администратора
+	client := Claims{UserID: 2, Role: RoleAdmin, ClientID: "spa", Scope: "profile:read offline_access"}
+	if !client.Can(PermProfileRead) || client.Can(PermKeysRotate) || client.Can(PermProfileWrite) {
+		t.Fatal("client token must be limited by scope")
+	}
+	if (Claims{UserID: 1, Role: RoleUser, ClientID: "spa", Scope: PermUsersRead}).Can(PermUsersRead) {
+		t.Fatal("scope must not exceed role permissions")
+	}
+
+	// владелец через OAuth-клиента читает свой профиль только со scope profile:read
+	offline := Claims{UserID: 1, Role: RoleUser, ClientID: "spa", Scope: "offline_access"}
+	if offline.CanAccess(PermProfileRead, PermUsersRead, 1) {
+		t.Fatal("client token without profile:read must not access own profile")
+	}
+	if !(Claims{UserID: 1, Role: RoleUser, ClientID: "spa", Scope: PermProfileRead}).CanAccess(PermProfileRead, PermUsersRead, 1) {
+		t.Fatal("client token with profile:read must access own profile")
+	}
+}
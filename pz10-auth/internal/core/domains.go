@@ -1,9 +1,93 @@
 package core
 
+import "time"
+
+const (
+	RoleAdmin = "admin"
+	RoleUser  = "user"
+)
+
+var Roles = []string{RoleAdmin, RoleUser}
+
+func ValidRole(role string) bool {
+	for _, r := range Roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
 type User struct {
 	ID    int64
 	Email string
+	Name  string
 	Role  string
+	// Disabled заблокированный пользователь не может войти, его токены отозваны
+	Disabled  bool
+	CreatedAt time.Time
+}
+
+// UserFilter параметры списка пользователей для администратора
+type UserFilter struct {
+	Role string
+	// Query подстрока email или имени
+	Query    string
+	Disabled *bool
+	Limit    int
+	Offset   int
+}
+
+// AdminStats сводка для панели администратора
+type AdminStats struct {
+	UsersTotal            int            `json:"usersTotal"`
+	UsersByRole           map[string]int `json:"usersByRole"`
+	DisabledUsers         int            `json:"disabledUsers"`
+	ActiveSessions        int64          `json:"activeSessions"`
+	LoginFailuresLastHour int64          `json:"loginFailuresLastHour"`
 }
 
-const CtxClaimsKey string = "claims"
+const (
+	TokenAccess  = "access"
+	TokenRefresh = "refresh"
+)
+
+// TokenParams содержимое выпускаемого токена
+type TokenParams struct {
+	UserID int64
+	Email  string
+	Role   string
+	Type   string
+	// SessionID идентификатор семейства refresh токенов (одно устройство)
+	SessionID string
+	// ID уникальный идентификатор токена (jti)
+	ID string
+	// ClientID OAuth клиент, которому выдан токен; у токена клиента (client credentials) нет UserID
+	ClientID string
+	Scope    string
+}
+
+// ClientInfo сведения об устройстве, с которого выполнен вход
+type ClientInfo struct {
+	UserAgent string
+	IP        string
+}
+
+// IssuedTokens идентификаторы текущей пары токенов сессии. Access токен запоминается,
+// чтобы отозвать его при обновлении пары или завершении сессии.
+type IssuedTokens struct {
+	RefreshID       string
+	AccessID        string
+	AccessExpiresAt time.Time
+}
+
+// Session семейство refresh токенов одного устройства.
+// При каждом обновлении выдаётся новый токен, действителен только последний.
+type Session struct {
+	ID         string
+	UserID     int64
+	UserAgent  string
+	IP         string
+	CreatedAt  time.Time
+	LastUsedAt time.Time
+}
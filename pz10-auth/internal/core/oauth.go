@@ -0,0 +1,86 @@
+package core
+
+import "time"
+
+const (
+	GrantAuthorizationCode = "authorization_code"
+	GrantRefreshToken      = "refresh_token"
+	GrantClientCredentials = "client_credentials"
+)
+
+var GrantTypes = []string{GrantAuthorizationCode, GrantRefreshToken, GrantClientCredentials}
+
+func AllowedGrant(grant string) bool { return contains(GrantTypes, grant) }
+
+// ScopeOfflineAccess при наличии в запросе вместе с access токеном выдаётся refresh токен
+const ScopeOfflineAccess = "offline_access"
+
+// OAuthClient зарегистрированное приложение. Публичный клиент (SPA, CLI) не может хранить секрет
+// и обязан использовать PKCE; конфиденциальный аутентифицируется секретом.
+type OAuthClient struct {
+	ID           string
+	Name         string
+	SecretHash   string
+	Public       bool
+	RedirectURIs []string
+	GrantTypes   []string
+	Scopes       []string
+	CreatedAt    time.Time
+}
+
+func (c OAuthClient) AllowsGrant(grant string) bool { return contains(c.GrantTypes, grant) }
+
+func (c OAuthClient) AllowsRedirect(uri string) bool { return contains(c.RedirectURIs, uri) }
+
+// AuthorizationCode одноразовый код authorization code flow; хранится только хэш кода
+type AuthorizationCode struct {
+	CodeHash      string
+	ClientID      string
+	UserID        int64
+	RedirectURI   string
+	Scope         string
+	CodeChallenge string
+	ExpiresAt     time.Time
+}
+
+// AuthorizeRequest параметры запроса к /oauth/authorize
+type AuthorizeRequest struct {
+	ResponseType        string
+	ClientID            string
+	RedirectURI         string
+	Scope               string
+	State               string
+	CodeChallenge       string
+	CodeChallengeMethod string
+}
+
+// TokenRequest параметры запроса к /oauth/token
+type TokenRequest struct {
+	GrantType    string
+	ClientID     string
+	ClientSecret string
+	Code         string
+	RedirectURI  string
+	CodeVerifier string
+	RefreshToken string
+	Scope        string
+	Client       ClientInfo
+}
+
+// TokenResponse ответ /oauth/token (RFC 6749, раздел 5.1)
+type TokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int64  `json:"expires_in"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+	Scope        string `json:"scope,omitempty"`
+}
+
+func contains(list []string, v string) bool {
+	for _, s := range list {
+		if s == v {
+			return true
+		}
+	}
+	return false
+}
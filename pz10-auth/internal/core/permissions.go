@@ -0,0 +1,33 @@
+package core
+
+// Разрешения в формате ресурс:действие. Разрешение даёт доступ к ресурсам всех пользователей;
+// к своим ресурсам (профиль, сессии) пользователь имеет доступ без отдельного разрешения.
+const (
+	PermProfileRead  = "profile:read"
+	PermProfileWrite = "profile:write"
+	PermSessions     = "sessions:manage"
+	PermUsersRead    = "users:read"
+	PermUsersWrite   = "users:write"
+	PermUsersRole    = "users:role"
+	PermStatsRead    = "stats:read"
+	PermKeysRotate   = "keys:rotate"
+	PermOAuthClients = "oauth:clients"
+)
+
+// RolePermissions разрешения каждой роли
+var RolePermissions = map[string][]string{
+	RoleUser: {PermProfileRead, PermProfileWrite, PermSessions},
+	RoleAdmin: {
+		PermProfileRead, PermProfileWrite, PermSessions,
+		PermUsersRead, PermUsersWrite, PermUsersRole, PermStatsRead, PermKeysRotate, PermOAuthClients,
+	},
+}
+
+func RoleHasPermission(role, perm string) bool {
+	for _, p := range RolePermissions[role] {
+		if p == perm {
+			return true
+		}
+	}
+	return false
+}
@@ -1,13 +1,62 @@
 package core
 
+import "time"
+
 type UserRepo interface {
 	GetById(id int64) (User, error)
 	CheckPassword(email, pass string) (User, error)
+	// Create сохраняет пользователя с хэшем пароля; занятый email — ErrEmailTaken репозитория
+	Create(u User, pass string) (User, error)
+	UpdateProfile(id int64, name string) (User, error)
+	SetRole(id int64, role string) (User, error)
+	SetPassword(id int64, pass string) error
+	SetDisabled(id int64, disabled bool) (User, error)
+	// List пользователи по фильтру и общее число подходящих
+	List(f UserFilter) ([]User, int, error)
+	// CountByRole число пользователей по ролям и число заблокированных
+	CountByRole() (byRole map[string]int, disabled int, err error)
 }
 
 type SessionRepo interface {
-	SetRefreshToken(userID int64, refreshToken string) error
-	GetRefreshToken(userID int64) (string, error)
+	// CreateSession сохраняет новое семейство с jti выданной пары токенов
+	CreateSession(s Session, tokens IssuedTokens) error
+	// RotateRefreshToken заменяет пару токенов семейства, прежний access токен отзывается;
+	// предъявление устаревшего jti означает кражу токена — семейство удаляется
+	// и возвращается ошибка повторного использования
+	RotateRefreshToken(sessionID, oldRefreshJTI string, next IssuedTokens, ip string) (Session, error)
+	ListSessions(userID int64) ([]Session, error)
+	// DeleteSession удаляет сессию и отзывает её access токен
+	DeleteSession(userID int64, sessionID string) error
+	// DeleteUserSessions удаляет все сессии пользователя, кроме exceptID
+	DeleteUserSessions(userID int64, exceptID string) (int, error)
+	// RevokeToken добавляет jti в список отозванных до истечения срока действия токена
+	RevokeToken(jti string, expiresAt time.Time) error
+	// RevokeUserTokens отзывает все токены пользователя, выпущенные раньше before (с точностью до миллисекунд)
+	RevokeUserTokens(userID int64, before time.Time) error
+	// RefreshTokenActive является ли jti текущим refresh токеном сессии
+	RefreshTokenActive(sessionID, jti string) (bool, error)
+	// TokenRevoked проверяет jti и время выпуска токена по обоим спискам
+	TokenRevoked(jti string, userID int64, issuedAt time.Time) (bool, error)
 	IncLoginAttempts(email string) (int64, error)
 	ResetLoginAttempts(email string) error
+	// RecordLoginFailure учитывает неудачный вход для статистики
+	RecordLoginFailure(email string) error
+	CountLoginFailures(since time.Time) (int64, error)
+	CountActiveSessions() (int64, error)
+	// SaveConsentTicket запоминает пользователя, вошедшего на странице авторизации, до подтверждения доступа
+	SaveConsentTicket(ticketHash string, userID int64, clientID string, ttl time.Duration) error
+	// TakeConsentTicket возвращает и удаляет билет: подтвердить доступ по нему можно один раз
+	TakeConsentTicket(ticketHash string) (userID int64, clientID string, err error)
+}
+
+type OAuthRepo interface {
+	CreateClient(c OAuthClient) error
+	GetClient(id string) (OAuthClient, error)
+	ListClients() ([]OAuthClient, error)
+	SaveCode(c AuthorizationCode) error
+	// TakeCode возвращает и удаляет код: повторно обменять его нельзя
+	TakeCode(codeHash string) (AuthorizationCode, error)
+	// GrantConsent добавляет scopes к уже выданному пользователем согласию
+	GrantConsent(userID int64, clientID string, scopes []string) error
+	ConsentedScopes(userID int64, clientID string) ([]string, error)
 }
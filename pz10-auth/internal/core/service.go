@@ -4,15 +4,55 @@ import "time"
 
 type UserService interface {
 	GetById(userID int64) (User, error)
-	GetStats() (map[string]any, error)
+	GetStats() (AdminStats, error)
+	ListUsers(f UserFilter) ([]User, int, error)
+	// SetDisabled блокировка пользователя сразу завершает все его сессии; последнего активного администратора не блокирует
+	SetDisabled(userID int64, disabled bool) (User, error)
+	// ForceLogout завершает все сессии пользователя и отзывает его токены
+	ForceLogout(userID int64) (int, error)
+	Register(email, password, name string) (User, error)
+	UpdateProfile(userID int64, name string) (User, error)
+	ChangePassword(userID int64, sessionID, oldPassword, newPassword string) error
+	SetRole(userID int64, role string) (User, error)
 }
 
 type AuthService interface {
-	Login(email, password string) (accessToken, refreshToken string, userID int64, err error)
-	RefreshTokens(oldRefreshToken string) (newAccessToken, newRefreshToken string, err error)
+	Login(email, password string, client ClientInfo) (accessToken, refreshToken string, userID int64, err error)
+	RefreshTokens(oldRefreshToken string, client ClientInfo) (newAccessToken, newRefreshToken string, err error)
+	ListSessions(userID int64) ([]Session, error)
+	RevokeSession(userID int64, sessionID string) error
+	RevokeOtherSessions(userID int64, currentSessionID string) (int, error)
+	// Logout завершает сессию и отзывает предъявленный access токен
+	Logout(userID int64, sessionID, accessJTI string, accessExpiresAt time.Time) error
+	// LogoutAll завершает все сессии и отзывает все выпущенные пользователю токены
+	LogoutAll(userID int64) (int, error)
+	TokenRevocationChecker
+}
+
+// TokenRevocationChecker проверка access токена на отзыв (выход из системы, logout-all)
+type TokenRevocationChecker interface {
+	IsTokenRevoked(jti string, userID int64, issuedAt time.Time) (bool, error)
 }
 
 type TokenManager interface {
-	Sign(userID int64, email, role string, ttl time.Duration) (string, error)
+	Sign(p TokenParams, ttl time.Duration) (string, error)
 	Parse(tokenStr string) (map[string]any, error)
 }
+
+type OAuthService interface {
+	RegisterClient(c OAuthClient) (client OAuthClient, secret string, err error)
+	ListClients() ([]OAuthClient, error)
+	// ValidateAuthorize проверяет запрос авторизации и возвращает клиента и запрошенные scopes
+	ValidateAuthorize(req AuthorizeRequest) (OAuthClient, []string, error)
+	// NeedsConsent нужно ли спрашивать согласие на scopes, которые пользователь ещё не разрешал клиенту
+	NeedsConsent(userID int64, clientID string, scopes []string) (bool, error)
+	AuthenticateUser(email, password string) (User, error)
+	// ConsentTicket связывает вошедшего пользователя со страницей согласия, не передавая пароль повторно
+	ConsentTicket(userID int64, clientID string) (string, error)
+	RedeemConsentTicket(ticket, clientID string) (userID int64, err error)
+	// Authorize фиксирует согласие пользователя и выпускает одноразовый код
+	Authorize(userID int64, req AuthorizeRequest) (code string, err error)
+	Token(req TokenRequest) (TokenResponse, error)
+	Introspect(clientID, clientSecret, token string) (map[string]any, error)
+	Revoke(clientID, clientSecret, token string) error
+}
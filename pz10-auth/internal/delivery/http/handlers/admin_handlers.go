@@ -0,0 +1,94 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/icestormerrr/pz10-auth/internal/core"
+	http_utils "github.com/icestormerrr/pz10-auth/internal/utils/http"
+)
+
+// ListUsers список пользователей: ?role=&q=&disabled=&limit=&offset=
+func (h *UserHandler) ListUsers(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	f := core.UserFilter{Role: q.Get("role"), Query: q.Get("q")}
+	var err error
+	if v := q.Get("limit"); v != "" {
+		if f.Limit, err = strconv.Atoi(v); err != nil {
+			http_utils.WriteError(w, http.StatusBadRequest, "invalid_filter", nil)
+			return
+		}
+	}
+	if v := q.Get("offset"); v != "" {
+		if f.Offset, err = strconv.Atoi(v); err != nil {
+			http_utils.WriteError(w, http.StatusBadRequest, "invalid_filter", nil)
+			return
+		}
+	}
+	if v := q.Get("disabled"); v != "" {
+		disabled, err := strconv.ParseBool(v)
+		if err != nil {
+			http_utils.WriteError(w, http.StatusBadRequest, "invalid_filter", nil)
+			return
+		}
+		f.Disabled = &disabled
+	}
+
+	users, total, err := h.userService.ListUsers(f)
+	if err != nil {
+		writeUserErr(w, err)
+		return
+	}
+	http_utils.WriteJSON(w, map[string]any{"items": users, "total": total})
+}
+
+// targetUserID id пользователя из пути; администратор не может заблокировать, разлогинить или понизить сам себя
+func targetUserID(w http.ResponseWriter, r *http.Request) (int64, bool) {
+	userID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		http_utils.WriteError(w, http.StatusBadRequest, "invalid_user_id", nil)
+		return 0, false
+	}
+	if self, _ := currentUserID(r); self == userID {
+		http_utils.WriteError(w, http.StatusBadRequest, "cannot_modify_self", nil)
+		return 0, false
+	}
+	return userID, true
+}
+
+func (h *UserHandler) setDisabled(w http.ResponseWriter, r *http.Request, disabled bool) {
+	userID, ok := targetUserID(w, r)
+	if !ok {
+		return
+	}
+	u, err := h.userService.SetDisabled(userID, disabled)
+	if err != nil {
+		writeUserErr(w, err)
+		return
+	}
+	http_utils.WriteJSON(w, u)
+}
+
+// DisableUser блокирует пользователя и завершает все его сессии
+func (h *UserHandler) DisableUser(w http.ResponseWriter, r *http.Request) {
+	h.setDisabled(w, r, true)
+}
+
+func (h *UserHandler) EnableUser(w http.ResponseWriter, r *http.Request) {
+	h.setDisabled(w, r, false)
+}
+
+// ForceLogout завершает все сессии пользователя и отзывает его токены
+func (h *UserHandler) ForceLogout(w http.ResponseWriter, r *http.Request) {
+	userID, ok := targetUserID(w, r)
+	if !ok {
+		return
+	}
+	n, err := h.userService.ForceLogout(userID)
+	if err != nil {
+		writeUserErr(w, err)
+		return
+	}
+	http_utils.WriteJSON(w, map[string]any{"revoked": n})
+}
@@ -2,6 +2,7 @@ package handlers
 
 import (
 	"encoding/json"
+	"net"
 	"net/http"
 	"time"
 
@@ -25,7 +26,7 @@ func (h *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	accessToken, refreshToken, userID, err := h.authService.Login(in.Email, in.Password)
+	accessToken, refreshToken, userID, err := h.authService.Login(in.Email, in.Password, clientInfo(r))
 	if err != nil {
 		http_utils.WriteError(w, http.StatusUnauthorized, err.Error(), nil)
 		return
@@ -52,8 +53,10 @@ func (h *AuthHandler) Refresh(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	newAccess, newRefresh, err := h.authService.RefreshTokens(cookie.Value)
+	newAccess, newRefresh, err := h.authService.RefreshTokens(cookie.Value, clientInfo(r))
 	if err != nil {
+		// токен больше не действителен, клиенту незачем его хранить
+		clearRefreshCookie(w)
 		http_utils.WriteError(w, http.StatusUnauthorized, err.Error(), nil)
 		return
 	}
@@ -71,3 +74,19 @@ func (h *AuthHandler) Refresh(w http.ResponseWriter, r *http.Request) {
 
 	http_utils.WriteJSON(w, map[string]any{"token": newAccess})
 }
+
+func clearRefreshCookie(w http.ResponseWriter) {
+	http.SetCookie(w, &http.Cookie{Name: "refreshToken", Value: "", Path: "/", MaxAge: -1, HttpOnly: true, Secure: true})
+}
+
+func clientInfo(r *http.Request) core.ClientInfo {
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		ip = r.RemoteAddr
+	}
+	ua := r.UserAgent()
+	if len(ua) > 255 {
+		ua = ua[:255]
+	}
+	return core.ClientInfo{UserAgent: ua, IP: ip}
+}
@@ -0,0 +1,360 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"html/template"
+	"log"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/icestormerrr/pz10-auth/internal/core"
+	"github.com/icestormerrr/pz10-auth/internal/services"
+	http_utils "github.com/icestormerrr/pz10-auth/internal/utils/http"
+)
+
+type OAuthHandler struct {
+	oauthService core.OAuthService
+}
+
+func NewOAuthHandler(oauthSvc core.OAuthService) *OAuthHandler {
+	return &OAuthHandler{oauthService: oauthSvc}
+}
+
+// authorizePage сначала вход, затем (если scopes ещё не разрешены) подтверждение доступа по билету Ticket
+var authorizePage = template.Must(template.New("authorize").Parse(`<!DOCTYPE html>
+<html lang="ru">
+<head><meta charset="utf-8"><title>Вход — {{.Client}}</title></head>
+<body>
+<h1>{{.Client}}</h1>
+<p>Приложение запрашивает доступ к вашей учётной записи:</p>
+<ul>{{range .Scopes}}<li>{{.}}</li>{{else}}<li>базовый доступ</li>{{end}}</ul>
+{{if .Error}}<p style="color:red">{{.Error}}</p>{{end}}
+<form method="post">
+{{range $k, $v := .Params}}<input type="hidden" name="{{$k}}" value="{{$v}}">{{end}}
+{{if .Ticket}}<input type="hidden" name="consent_ticket" value="{{.Ticket}}">
+<button name="action" value="approve">Разрешить</button>
+{{else}}<p><input name="email" type="email" placeholder="email" required autofocus></p>
+<p><input name="password" type="password" placeholder="пароль" required></p>
+<button name="action" value="login">Войти</button>
+{{end}}<button name="action" value="deny" formnovalidate>Отклонить</button>
+</form>
+</body>
+</html>`))
+
+func authorizeRequest(v url.Values) core.AuthorizeRequest {
+	return core.AuthorizeRequest{
+		ResponseType:        v.Get("response_type"),
+		ClientID:            v.Get("client_id"),
+		RedirectURI:         v.Get("redirect_uri"),
+		Scope:               v.Get("scope"),
+		State:               v.Get("state"),
+		CodeChallenge:       v.Get("code_challenge"),
+		CodeChallengeMethod: v.Get("code_challenge_method"),
+	}
+}
+
+// params параметры запроса для скрытых полей формы
+func params(req core.AuthorizeRequest) map[string]string {
+	return map[string]string{
+		"response_type":         req.ResponseType,
+		"client_id":             req.ClientID,
+		"redirect_uri":          req.RedirectURI,
+		"scope":                 req.Scope,
+		"state":                 req.State,
+		"code_challenge":        req.CodeChallenge,
+		"code_challenge_method": req.CodeChallengeMethod,
+	}
+}
+
+func renderAuthorize(w http.ResponseWriter, code int, client core.OAuthClient, scopes []string, req core.AuthorizeRequest, ticket, errMsg string) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	// страницу с паролем нельзя встраивать в чужие сайты
+	w.Header().Set("X-Frame-Options", "DENY")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(code)
+	err := authorizePage.Execute(w, map[string]any{
+		"Client": client.Name, "Scopes": scopes, "Params": params(req), "Ticket": ticket, "Error": errMsg,
+	})
+	if err != nil {
+		log.Printf("[ERROR] render authorize page: %v", err)
+	}
+}
+
+// redirectWithError ошибка авторизации передаётся клиенту через redirect_uri (RFC 6749, 4.1.2.1)
+func redirectWithError(w http.ResponseWriter, r *http.Request, req core.AuthorizeRequest, err error) {
+	code := err.Error()
+	if !isOAuthError(err) {
+		log.Printf("[ERROR] oauth authorize: %v", err)
+		code = "server_error"
+	}
+	redirectTo(w, r, req, url.Values{"error": {code}})
+}
+
+func redirectTo(w http.ResponseWriter, r *http.Request, req core.AuthorizeRequest, q url.Values) {
+	u, _ := url.Parse(req.RedirectURI)
+	values := u.Query()
+	for k, v := range q {
+		values[k] = v
+	}
+	if req.State != "" {
+		values.Set("state", req.State)
+	}
+	u.RawQuery = values.Encode()
+	http.Redirect(w, r, u.String(), http.StatusFound)
+}
+
+func isOAuthError(err error) bool {
+	for _, e := range []error{
+		services.ErrOAuthInvalidRequest, services.ErrOAuthInvalidClient, services.ErrOAuthInvalidGrant,
+		services.ErrOAuthUnauthorizedClient, services.ErrOAuthUnsupportedGrant, services.ErrOAuthUnsupportedResponse,
+		services.ErrOAuthInvalidScope, services.ErrOAuthInvalidRedirectURI, services.ErrOAuthInvalidClientMetadata,
+	} {
+		if errors.Is(err, e) {
+			return true
+		}
+	}
+	return false
+}
+
+// validateAuthorize при неизвестном клиенте или redirect_uri перенаправлять некуда — ошибка показывается пользователю
+func (h *OAuthHandler) validateAuthorize(w http.ResponseWriter, r *http.Request, req core.AuthorizeRequest) (core.OAuthClient, []string, bool) {
+	client, scopes, err := h.oauthService.ValidateAuthorize(req)
+	switch {
+	case errors.Is(err, services.ErrOAuthInvalidClient), errors.Is(err, services.ErrOAuthInvalidRedirectURI):
+		http_utils.WriteError(w, http.StatusBadRequest, err.Error(), nil)
+		return core.OAuthClient{}, nil, false
+	case err != nil && client.ID == "":
+		http_utils.WriteError(w, http.StatusInternalServerError, "internal_error", nil)
+		return core.OAuthClient{}, nil, false
+	case err != nil:
+		redirectWithError(w, r, req, err)
+		return core.OAuthClient{}, nil, false
+	}
+	return client, scopes, true
+}
+
+// Authorize страница входа и согласия (authorization code flow)
+func (h *OAuthHandler) Authorize(w http.ResponseWriter, r *http.Request) {
+	req := authorizeRequest(r.URL.Query())
+	client, scopes, ok := h.validateAuthorize(w, r, req)
+	if !ok {
+		return
+	}
+	renderAuthorize(w, http.StatusOK, client, scopes, req, "", "")
+}
+
+// AuthorizeSubmit вход всегда обязателен; согласие спрашивается, только если пользователь
+// ещё не разрешал клиенту какой-то из запрошенных scopes
+func (h *OAuthHandler) AuthorizeSubmit(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http_utils.WriteError(w, http.StatusBadRequest, "invalid_request", nil)
+		return
+	}
+	req := authorizeRequest(r.PostForm)
+	client, scopes, ok := h.validateAuthorize(w, r, req)
+	if !ok {
+		return
+	}
+
+	switch r.PostForm.Get("action") {
+	case "login":
+		u, err := h.oauthService.AuthenticateUser(r.PostForm.Get("email"), r.PostForm.Get("password"))
+		if err != nil {
+			status, message := loginErrorPage(err)
+			renderAuthorize(w, status, client, scopes, req, "", message)
+			return
+		}
+		needsConsent, err := h.oauthService.NeedsConsent(u.ID, client.ID, scopes)
+		if err != nil {
+			redirectWithError(w, r, req, err)
+			return
+		}
+		if !needsConsent {
+			h.issueCode(w, r, u.ID, req)
+			return
+		}
+		ticket, err := h.oauthService.ConsentTicket(u.ID, client.ID)
+		if err != nil {
+			redirectWithError(w, r, req, err)
+			return
+		}
+		renderAuthorize(w, http.StatusOK, client, scopes, req, ticket, "")
+	case "approve":
+		userID, err := h.oauthService.RedeemConsentTicket(r.PostForm.Get("consent_ticket"), client.ID)
+		if errors.Is(err, services.ErrConsentExpired) {
+			renderAuthorize(w, http.StatusUnauthorized, client, scopes, req, "", "Время подтверждения истекло, войдите снова")
+			return
+		}
+		if err != nil {
+			redirectWithError(w, r, req, err)
+			return
+		}
+		h.issueCode(w, r, userID, req)
+	default:
+		redirectTo(w, r, req, url.Values{"error": {"access_denied"}})
+	}
+}
+
+func (h *OAuthHandler) issueCode(w http.ResponseWriter, r *http.Request, userID int64, req core.AuthorizeRequest) {
+	code, err := h.oauthService.Authorize(userID, req)
+	if err != nil {
+		redirectWithError(w, r, req, err)
+		return
+	}
+	redirectTo(w, r, req, url.Values{"code": {code}})
+}
+
+// clientCredentials HTTP Basic (предпочтительно) или client_id/client_secret в теле формы
+func clientCredentials(r *http.Request) (id, secret string) {
+	if id, secret, ok := r.BasicAuth(); ok {
+		id, _ = url.QueryUnescape(id)
+		secret, _ = url.QueryUnescape(secret)
+		return id, secret
+	}
+	return r.PostForm.Get("client_id"), r.PostForm.Get("client_secret")
+}
+
+// writeOAuthError ответ об ошибке в формате RFC 6749, раздел 5.2
+func writeOAuthError(w http.ResponseWriter, err error) {
+	code, status := err.Error(), http.StatusBadRequest
+	switch {
+	case errors.Is(err, services.ErrOAuthInvalidClient):
+		w.Header().Set("WWW-Authenticate", `Basic realm="oauth"`)
+		status = http.StatusUnauthorized
+	case !isOAuthError(err):
+		log.Printf("[ERROR] oauth: %v", err)
+		code, status = "server_error", http.StatusInternalServerError
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	http_utils.WriteJSONStatus(w, status, map[string]string{"error": code})
+}
+
+func (h *OAuthHandler) Token(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		writeOAuthError(w, services.ErrOAuthInvalidRequest)
+		return
+	}
+	id, secret := clientCredentials(r)
+	resp, err := h.oauthService.Token(core.TokenRequest{
+		GrantType:    r.PostForm.Get("grant_type"),
+		ClientID:     id,
+		ClientSecret: secret,
+		Code:         r.PostForm.Get("code"),
+		RedirectURI:  r.PostForm.Get("redirect_uri"),
+		CodeVerifier: r.PostForm.Get("code_verifier"),
+		RefreshToken: r.PostForm.Get("refresh_token"),
+		Scope:        r.PostForm.Get("scope"),
+		Client:       clientInfo(r),
+	})
+	if err != nil {
+		writeOAuthError(w, err)
+		return
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Pragma", "no-cache")
+	http_utils.WriteJSON(w, resp)
+}
+
+// Introspect RFC 7662
+func (h *OAuthHandler) Introspect(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		writeOAuthError(w, services.ErrOAuthInvalidRequest)
+		return
+	}
+	id, secret := clientCredentials(r)
+	out, err := h.oauthService.Introspect(id, secret, r.PostForm.Get("token"))
+	if err != nil {
+		writeOAuthError(w, err)
+		return
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	http_utils.WriteJSON(w, out)
+}
+
+// Revoke RFC 7009
+func (h *OAuthHandler) Revoke(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		writeOAuthError(w, services.ErrOAuthInvalidRequest)
+		return
+	}
+	id, secret := clientCredentials(r)
+	if err := h.oauthService.Revoke(id, secret, r.PostForm.Get("token")); err != nil {
+		writeOAuthError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+type oauthClientResp struct {
+	ID           string    `json:"clientId"`
+	Secret       string    `json:"clientSecret,omitempty"`
+	Name         string    `json:"name"`
+	Public       bool      `json:"public"`
+	RedirectURIs []string  `json:"redirectUris"`
+	GrantTypes   []string  `json:"grantTypes"`
+	Scopes       []string  `json:"scopes"`
+	CreatedAt    time.Time `json:"createdAt"`
+}
+
+func clientResp(c core.OAuthClient, secret string) oauthClientResp {
+	return oauthClientResp{
+		ID: c.ID, Secret: secret, Name: c.Name, Public: c.Public,
+		RedirectURIs: c.RedirectURIs, GrantTypes: c.GrantTypes, Scopes: c.Scopes, CreatedAt: c.CreatedAt,
+	}
+}
+
+// CreateClient регистрация клиента; секрет показывается один раз
+func (h *OAuthHandler) CreateClient(w http.ResponseWriter, r *http.Request) {
+	var in struct {
+		Name         string   `json:"name"`
+		Public       bool     `json:"public"`
+		RedirectURIs []string `json:"redirectUris"`
+		GrantTypes   []string `json:"grantTypes"`
+		Scopes       []string `json:"scopes"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		http_utils.WriteError(w, http.StatusBadRequest, "invalid_json", nil)
+		return
+	}
+	c, secret, err := h.oauthService.RegisterClient(core.OAuthClient{
+		Name: in.Name, Public: in.Public, RedirectURIs: in.RedirectURIs, GrantTypes: in.GrantTypes, Scopes: in.Scopes,
+	})
+	if isOAuthError(err) {
+		http_utils.WriteError(w, http.StatusBadRequest, err.Error(), nil)
+		return
+	}
+	if err != nil {
+		http_utils.WriteError(w, http.StatusInternalServerError, "internal_error", nil)
+		return
+	}
+	http_utils.WriteJSONStatus(w, http.StatusCreated, clientResp(c, secret))
+}
+
+func (h *OAuthHandler) ListClients(w http.ResponseWriter, r *http.Request) {
+	clients, err := h.oauthService.ListClients()
+	if err != nil {
+		http_utils.WriteError(w, http.StatusInternalServerError, "internal_error", nil)
+		return
+	}
+	out := make([]oauthClientResp, 0, len(clients))
+	for _, c := range clients {
+		out = append(out, clientResp(c, ""))
+	}
+	http_utils.WriteJSON(w, out)
+}
+
+// loginErrorPage статус и текст ошибки входа для страницы авторизации
+func loginErrorPage(err error) (int, string) {
+	switch {
+	case errors.Is(err, services.ErrTooManyLoginAttempts):
+		return http.StatusTooManyRequests, "Слишком много попыток входа, попробуйте позже"
+	case errors.Is(err, services.ErrUserDisabled):
+		return http.StatusForbidden, "Учётная запись заблокирована"
+	case errors.Is(err, services.ErrUnauthorized):
+		return http.StatusUnauthorized, "Неверный email или пароль"
+	default:
+		return http.StatusInternalServerError, "Внутренняя ошибка, попробуйте позже"
+	}
+}
@@ -0,0 +1,118 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/icestormerrr/pz10-auth/internal/core"
+	"github.com/icestormerrr/pz10-auth/internal/services"
+	http_utils "github.com/icestormerrr/pz10-auth/internal/utils/http"
+)
+
+type sessionResp struct {
+	ID         string    `json:"id"`
+	UserAgent  string    `json:"userAgent"`
+	IP         string    `json:"ip"`
+	CreatedAt  time.Time `json:"createdAt"`
+	LastUsedAt time.Time `json:"lastUsedAt"`
+	Current    bool      `json:"current"`
+}
+
+// currentSessionID сессия, в которой выпущен access токен
+func currentSessionID(r *http.Request) string {
+	claims, _ := core.ClaimsFromContext(r.Context())
+	return claims.SessionID
+}
+
+func (h *AuthHandler) ListSessions(w http.ResponseWriter, r *http.Request) {
+	userID, ok := currentUserID(r)
+	if !ok {
+		http_utils.WriteError(w, http.StatusUnauthorized, "invalid_claims", nil)
+		return
+	}
+	sessions, err := h.authService.ListSessions(userID)
+	if err != nil {
+		http_utils.WriteError(w, http.StatusInternalServerError, "internal_error", nil)
+		return
+	}
+
+	current := currentSessionID(r)
+	out := make([]sessionResp, 0, len(sessions))
+	for _, s := range sessions {
+		out = append(out, sessionResp{
+			ID:         s.ID,
+			UserAgent:  s.UserAgent,
+			IP:         s.IP,
+			CreatedAt:  s.CreatedAt,
+			LastUsedAt: s.LastUsedAt,
+			Current:    s.ID == current,
+		})
+	}
+	http_utils.WriteJSON(w, out)
+}
+
+func (h *AuthHandler) RevokeSession(w http.ResponseWriter, r *http.Request) {
+	userID, ok := currentUserID(r)
+	if !ok {
+		http_utils.WriteError(w, http.StatusUnauthorized, "invalid_claims", nil)
+		return
+	}
+	err := h.authService.RevokeSession(userID, chi.URLParam(r, "id"))
+	if errors.Is(err, services.ErrSessionNotFound) {
+		http_utils.WriteError(w, http.StatusNotFound, err.Error(), nil)
+		return
+	}
+	if err != nil {
+		http_utils.WriteError(w, http.StatusInternalServerError, "internal_error", nil)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// RevokeOtherSessions завершает все сессии пользователя, кроме текущей
+func (h *AuthHandler) RevokeOtherSessions(w http.ResponseWriter, r *http.Request) {
+	userID, ok := currentUserID(r)
+	if !ok {
+		http_utils.WriteError(w, http.StatusUnauthorized, "invalid_claims", nil)
+		return
+	}
+	n, err := h.authService.RevokeOtherSessions(userID, currentSessionID(r))
+	if err != nil {
+		http_utils.WriteError(w, http.StatusInternalServerError, "internal_error", nil)
+		return
+	}
+	http_utils.WriteJSON(w, map[string]any{"revoked": n})
+}
+
+// Logout завершает текущую сессию: refresh токен удаляется, access токен отзывается сразу
+func (h *AuthHandler) Logout(w http.ResponseWriter, r *http.Request) {
+	claims, ok := core.ClaimsFromContext(r.Context())
+	if !ok {
+		http_utils.WriteError(w, http.StatusUnauthorized, "invalid_claims", nil)
+		return
+	}
+	if err := h.authService.Logout(claims.UserID, claims.SessionID, claims.ID, claims.ExpiresAt); err != nil {
+		http_utils.WriteError(w, http.StatusInternalServerError, "internal_error", nil)
+		return
+	}
+	clearRefreshCookie(w)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// LogoutAll выход на всех устройствах, включая текущее
+func (h *AuthHandler) LogoutAll(w http.ResponseWriter, r *http.Request) {
+	userID, ok := currentUserID(r)
+	if !ok {
+		http_utils.WriteError(w, http.StatusUnauthorized, "invalid_claims", nil)
+		return
+	}
+	n, err := h.authService.LogoutAll(userID)
+	if err != nil {
+		http_utils.WriteError(w, http.StatusInternalServerError, "internal_error", nil)
+		return
+	}
+	clearRefreshCookie(w)
+	http_utils.WriteJSON(w, map[string]any{"revoked": n})
+}
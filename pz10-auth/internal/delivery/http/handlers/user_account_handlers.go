@@ -0,0 +1,112 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/icestormerrr/pz10-auth/internal/core"
+	"github.com/icestormerrr/pz10-auth/internal/services"
+	http_utils "github.com/icestormerrr/pz10-auth/internal/utils/http"
+)
+
+// writeUserErr переводит ошибки UserService в HTTP-статус
+func writeUserErr(w http.ResponseWriter, err error) {
+	code := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, services.ErrUserNotFound):
+		code = http.StatusNotFound
+	case errors.Is(err, services.ErrEmailTaken), errors.Is(err, services.ErrLastAdmin):
+		code = http.StatusConflict
+	case errors.Is(err, services.ErrWrongPassword):
+		code = http.StatusForbidden
+	case errors.Is(err, services.ErrInvalidEmail), errors.Is(err, services.ErrWeakPassword),
+		errors.Is(err, services.ErrInvalidName), errors.Is(err, services.ErrInvalidRole),
+		errors.Is(err, services.ErrInvalidFilter):
+		code = http.StatusBadRequest
+	}
+	http_utils.WriteError(w, code, err.Error(), nil)
+}
+
+// currentUserID id пользователя из клеймов токена
+func currentUserID(r *http.Request) (int64, bool) {
+	claims, ok := core.ClaimsFromContext(r.Context())
+	return claims.UserID, ok
+}
+
+func (h *UserHandler) Register(w http.ResponseWriter, r *http.Request) {
+	var in struct{ Email, Password, Name string }
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		http_utils.WriteError(w, http.StatusBadRequest, "invalid_json", nil)
+		return
+	}
+
+	u, err := h.userService.Register(in.Email, in.Password, in.Name)
+	if err != nil {
+		writeUserErr(w, err)
+		return
+	}
+	http_utils.WriteJSONStatus(w, http.StatusCreated, u)
+}
+
+func (h *UserHandler) UpdateMe(w http.ResponseWriter, r *http.Request) {
+	userID, ok := currentUserID(r)
+	if !ok {
+		http_utils.WriteError(w, http.StatusUnauthorized, "invalid_claims", nil)
+		return
+	}
+	var in struct{ Name string }
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		http_utils.WriteError(w, http.StatusBadRequest, "invalid_json", nil)
+		return
+	}
+
+	u, err := h.userService.UpdateProfile(userID, in.Name)
+	if err != nil {
+		writeUserErr(w, err)
+		return
+	}
+	http_utils.WriteJSON(w, u)
+}
+
+func (h *UserHandler) ChangePassword(w http.ResponseWriter, r *http.Request) {
+	userID, ok := currentUserID(r)
+	if !ok {
+		http_utils.WriteError(w, http.StatusUnauthorized, "invalid_claims", nil)
+		return
+	}
+	var in struct {
+		OldPassword string `json:"oldPassword"`
+		NewPassword string `json:"newPassword"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		http_utils.WriteError(w, http.StatusBadRequest, "invalid_json", nil)
+		return
+	}
+
+	if err := h.userService.ChangePassword(userID, currentSessionID(r), in.OldPassword, in.NewPassword); err != nil {
+		writeUserErr(w, err)
+		return
+	}
+	http_utils.WriteJSON(w, map[string]any{"status": "ok"})
+}
+
+// SetRole назначение роли (только для администратора); свою роль администратор не меняет
+func (h *UserHandler) SetRole(w http.ResponseWriter, r *http.Request) {
+	userID, ok := targetUserID(w, r)
+	if !ok {
+		return
+	}
+	var in struct{ Role string }
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		http_utils.WriteError(w, http.StatusBadRequest, "invalid_json", nil)
+		return
+	}
+
+	u, err := h.userService.SetRole(userID, in.Role)
+	if err != nil {
+		writeUserErr(w, err)
+		return
+	}
+	http_utils.WriteJSON(w, u)
+}
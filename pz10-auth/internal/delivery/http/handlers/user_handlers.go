@@ -18,13 +18,13 @@ func NewUserHandler(userSvc core.UserService) *UserHandler {
 }
 
 func (h *UserHandler) Me(w http.ResponseWriter, r *http.Request) {
-	claims, ok := r.Context().Value(core.CtxClaimsKey).(map[string]any)
+	claims, ok := core.ClaimsFromContext(r.Context())
 	if !ok {
 		http_utils.WriteError(w, http.StatusUnauthorized, "invalid_claims", nil)
 		return
 	}
 	http_utils.WriteJSON(w, map[string]any{
-		"id": claims["sub"], "email": claims["email"], "role": claims["role"],
+		"id": claims.UserID, "email": claims.Email, "role": claims.Role,
 	})
 }
 
@@ -36,17 +36,7 @@ func (h *UserHandler) GetByID(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	claims, ok := r.Context().Value(core.CtxClaimsKey).(map[string]any)
-	if !ok {
-		http_utils.WriteError(w, http.StatusUnauthorized, "invalid_claims", nil)
-		return
-	}
-
-	if userID != int64(claims["sub"].(float64)) && claims["role"] != "admin" {
-		http_utils.WriteError(w, http.StatusForbidden, "forbidden", nil)
-		return
-	}
-
+	// доступ проверяет middleware.RequireOwnerOrPermission
 	user, err := h.userService.GetById(userID)
 	if err != nil {
 		http_utils.WriteError(w, http.StatusNotFound, err.Error(), nil)
@@ -57,5 +47,10 @@ func (h *UserHandler) GetByID(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *UserHandler) GetAdminStats(w http.ResponseWriter, r *http.Request) {
-	http_utils.WriteJSON(w, map[string]any{"users": 2, "version": "1.0"})
+	stats, err := h.userService.GetStats()
+	if err != nil {
+		writeUserErr(w, err)
+		return
+	}
+	http_utils.WriteJSON(w, stats)
 }
@@ -0,0 +1,56 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/icestormerrr/pz10-auth/internal/core"
+
+	http_utils "github.com/icestormerrr/pz10-auth/internal/utils/http"
+	"github.com/icestormerrr/pz10-auth/internal/utils/jwt"
+)
+
+type WellKnownHandler struct {
+	keys *jwt.KeyRingTokenManager
+}
+
+func NewWellKnownHandler(keys *jwt.KeyRingTokenManager) *WellKnownHandler {
+	return &WellKnownHandler{keys: keys}
+}
+
+// JWKS открытые ключи для проверки токенов сторонними сервисами
+func (h *WellKnownHandler) JWKS(w http.ResponseWriter, r *http.Request) {
+	// клиенты кэшируют набор ключей; новый ключ появляется в нём сразу после ротации
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	http_utils.WriteJSON(w, h.keys.JWKS())
+}
+
+// Discovery метаданные OpenID Connect Discovery 1.0
+func (h *WellKnownHandler) Discovery(w http.ResponseWriter, r *http.Request) {
+	issuer := h.keys.Issuer()
+	http_utils.WriteJSON(w, map[string]any{
+		"issuer":                                issuer,
+		"jwks_uri":                              issuer + "/.well-known/jwks.json",
+		"authorization_endpoint":                issuer + "/oauth/authorize",
+		"token_endpoint":                        issuer + "/oauth/token",
+		"introspection_endpoint":                issuer + "/oauth/introspect",
+		"revocation_endpoint":                   issuer + "/oauth/revoke",
+		"response_types_supported":              []string{"code"},
+		"grant_types_supported":                 core.GrantTypes,
+		"code_challenge_methods_supported":      []string{"S256"},
+		"token_endpoint_auth_methods_supported": []string{"client_secret_basic", "client_secret_post", "none"},
+		"subject_types_supported":               []string{"public"},
+		"claims_supported":                      []string{"sub", "email", "role", "iss", "aud", "exp", "iat", "jti", "sid", "client_id", "scope"},
+	})
+}
+
+// RotateKeys внеплановая ротация ключа подписи. ?revoke=true (утечка ключа) сразу отзывает прежний ключ,
+// выданные им токены перестают приниматься.
+func (h *WellKnownHandler) RotateKeys(w http.ResponseWriter, r *http.Request) {
+	revoke := r.URL.Query().Get("revoke") == "true"
+	kid, err := h.keys.Rotate(revoke)
+	if err != nil {
+		http_utils.WriteError(w, http.StatusInternalServerError, "key_rotation_failed", nil)
+		return
+	}
+	http_utils.WriteJSON(w, map[string]any{"kid": kid})
+}
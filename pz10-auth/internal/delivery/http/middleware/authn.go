@@ -1,7 +1,6 @@
 package middleware
 
 import (
-	"context"
 	"net/http"
 	"strings"
 
@@ -9,9 +8,7 @@ import (
 	http_utils "github.com/icestormerrr/pz10-auth/internal/utils/http"
 )
 
-const ctxClaimsKey string = "claims"
-
-func AuthN(v core.TokenManager) func(http.Handler) http.Handler {
+func AuthN(v core.TokenManager, revocations core.TokenRevocationChecker) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			h := r.Header.Get("Authorization")
@@ -20,13 +17,32 @@ func AuthN(v core.TokenManager) func(http.Handler) http.Handler {
 				return
 			}
 			raw := strings.TrimPrefix(h, "Bearer ")
-			claims, err := v.Parse(raw)
+			parsed, err := v.Parse(raw)
+			if err != nil {
+				http_utils.WriteError(w, http.StatusUnauthorized, "unauthorized", nil)
+				return
+			}
+			claims, err := core.NewClaims(parsed)
 			if err != nil {
+				http_utils.WriteError(w, http.StatusUnauthorized, "invalid_claims", nil)
+				return
+			}
+			// refresh токен подписан тем же ключом, но доступа к API не даёт
+			if claims.IsRefresh() {
 				http_utils.WriteError(w, http.StatusUnauthorized, "unauthorized", nil)
 				return
 			}
-			ctx := context.WithValue(r.Context(), ctxClaimsKey, claims)
-			next.ServeHTTP(w, r.WithContext(ctx))
+			// подпись и срок действия верны, но токен мог быть отозван при выходе из системы
+			revoked, err := revocations.IsTokenRevoked(claims.ID, claims.UserID, claims.IssuedAt)
+			if err != nil {
+				http_utils.WriteError(w, http.StatusInternalServerError, "internal_error", nil)
+				return
+			}
+			if revoked {
+				http_utils.WriteError(w, http.StatusUnauthorized, "token_revoked", nil)
+				return
+			}
+			next.ServeHTTP(w, r.WithContext(core.ContextWithClaims(r.Context(), claims)))
 		})
 	}
 }
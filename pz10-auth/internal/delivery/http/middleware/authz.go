@@ -2,21 +2,39 @@ package middleware
 
 import (
 	"net/http"
+	"strconv"
 
+	"github.com/go-chi/chi/v5"
+	"github.com/icestormerrr/pz10-auth/internal/core"
 	http_utils "github.com/icestormerrr/pz10-auth/internal/utils/http"
 )
 
-func AuthZRoles(allowed ...string) func(http.Handler) http.Handler {
-	set := map[string]struct{}{}
-	for _, a := range allowed {
-		set[a] = struct{}{}
+// RequirePermission пропускает запрос, если у роли пользователя есть разрешение perm
+func RequirePermission(perm string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			claims, ok := core.ClaimsFromContext(r.Context())
+			if !ok || !claims.Can(perm) {
+				http_utils.WriteError(w, http.StatusForbidden, "forbidden", nil)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
 	}
+}
 
+// RequireOwnerOrPermission пропускает владельца ресурса (id пользователя в параметре пути ownerParam)
+// с разрешением ownPerm или пользователя с разрешением anyPerm на чужие ресурсы
+func RequireOwnerOrPermission(ownPerm, anyPerm, ownerParam string) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			claims, _ := r.Context().Value(ctxClaimsKey).(map[string]any)
-			role, _ := claims["role"].(string)
-			if _, ok := set[role]; !ok {
+			ownerID, err := strconv.ParseInt(chi.URLParam(r, ownerParam), 10, 64)
+			if err != nil {
+				http_utils.WriteError(w, http.StatusBadRequest, "invalid_"+ownerParam, nil)
+				return
+			}
+			claims, ok := core.ClaimsFromContext(r.Context())
+			if !ok || !claims.CanAccess(ownPerm, anyPerm, ownerID) {
 				http_utils.WriteError(w, http.StatusForbidden, "forbidden", nil)
 				return
 			}
@@ -9,24 +9,57 @@ import (
 	"github.com/icestormerrr/pz10-auth/internal/delivery/http/middleware"
 )
 
-func Build(authHandler *handlers.AuthHandler, userHandler *handlers.UserHandler, tokenManager core.TokenManager) http.Handler {
+func Build(authHandler *handlers.AuthHandler, userHandler *handlers.UserHandler, oauthHandler *handlers.OAuthHandler, wellKnownHandler *handlers.WellKnownHandler, tokenManager core.TokenManager, revocations core.TokenRevocationChecker) http.Handler {
 	r := chi.NewRouter()
 
 	r.Use(middleware.Logger)
+	r.Get("/.well-known/jwks.json", wellKnownHandler.JWKS)
+	r.Get("/.well-known/openid-configuration", wellKnownHandler.Discovery)
+	r.Post("/api/v1/register", userHandler.Register)
 	r.Post("/api/v1/login", authHandler.Login)
 	r.Post("/api/v1/refresh", authHandler.Refresh)
 
+	r.Get("/oauth/authorize", oauthHandler.Authorize)
+	r.Post("/oauth/authorize", oauthHandler.AuthorizeSubmit)
+	r.Post("/oauth/token", oauthHandler.Token)
+	r.Post("/oauth/introspect", oauthHandler.Introspect)
+	r.Post("/oauth/revoke", oauthHandler.Revoke)
+
 	r.Group(func(priv chi.Router) {
-		priv.Use(middleware.AuthN(tokenManager))
-		priv.Use(middleware.AuthZRoles("admin", "user"))
-		priv.Get("/api/v1/me", userHandler.Me)
-		priv.Get("/api/v1/user/{id}", userHandler.GetByID)
-	})
+		priv.Use(middleware.AuthN(tokenManager, revocations))
+		// выход доступен любому действительному токену
+		priv.Post("/api/v1/logout", authHandler.Logout)
+		priv.Post("/api/v1/logout-all", authHandler.LogoutAll)
+
+		priv.With(middleware.RequirePermission(core.PermProfileRead)).Get("/api/v1/me", userHandler.Me)
+		priv.With(middleware.RequirePermission(core.PermProfileWrite)).Patch("/api/v1/me", userHandler.UpdateMe)
+		priv.With(middleware.RequirePermission(core.PermProfileWrite)).Post("/api/v1/me/password", userHandler.ChangePassword)
+
+		priv.Group(func(sessions chi.Router) {
+			sessions.Use(middleware.RequirePermission(core.PermSessions))
+			sessions.Get("/api/v1/sessions", authHandler.ListSessions)
+			sessions.Delete("/api/v1/sessions", authHandler.RevokeOtherSessions)
+			sessions.Delete("/api/v1/sessions/{id}", authHandler.RevokeSession)
+		})
+
+		priv.With(middleware.RequireOwnerOrPermission(core.PermProfileRead, core.PermUsersRead, "id")).Get("/api/v1/user/{id}", userHandler.GetByID)
+
+		priv.With(middleware.RequirePermission(core.PermStatsRead)).Get("/api/v1/admin/stats", userHandler.GetAdminStats)
+		priv.With(middleware.RequirePermission(core.PermUsersRead)).Get("/api/v1/admin/users", userHandler.ListUsers)
+		priv.With(middleware.RequirePermission(core.PermUsersRole)).Put("/api/v1/admin/users/{id}/role", userHandler.SetRole)
+		priv.Group(func(users chi.Router) {
+			users.Use(middleware.RequirePermission(core.PermUsersWrite))
+			users.Post("/api/v1/admin/users/{id}/disable", userHandler.DisableUser)
+			users.Post("/api/v1/admin/users/{id}/enable", userHandler.EnableUser)
+			users.Post("/api/v1/admin/users/{id}/logout", userHandler.ForceLogout)
+		})
+		priv.With(middleware.RequirePermission(core.PermKeysRotate)).Post("/api/v1/admin/keys/rotate", wellKnownHandler.RotateKeys)
 
-	r.Group(func(admin chi.Router) {
-		admin.Use(middleware.AuthN(tokenManager))
-		admin.Use(middleware.AuthZRoles("admin"))
-		admin.Get("/api/v1/admin/stats", userHandler.GetAdminStats)
+		priv.Group(func(clients chi.Router) {
+			clients.Use(middleware.RequirePermission(core.PermOAuthClients))
+			clients.Get("/api/v1/admin/oauth/clients", oauthHandler.ListClients)
+			clients.Post("/api/v1/admin/oauth/clients", oauthHandler.CreateClient)
+		})
 	})
 
 	return r
@@ -0,0 +1,80 @@
+package repos
+
+import (
+	"sync"
+
+	"github.com/icestormerrr/pz10-auth/internal/core"
+)
+
+// OAuthInMemoryRepo хранилище OAuth клиентов и кодов в памяти (для тестов)
+type OAuthInMemoryRepo struct {
+	mu       sync.Mutex
+	clients  []core.OAuthClient
+	codes    map[string]core.AuthorizationCode
+	consents map[consentKey][]string
+}
+
+func NewOAuthInMemoryRepo() *OAuthInMemoryRepo {
+	return &OAuthInMemoryRepo{codes: map[string]core.AuthorizationCode{}, consents: map[consentKey][]string{}}
+}
+
+func (repo *OAuthInMemoryRepo) CreateClient(c core.OAuthClient) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	repo.clients = append(repo.clients, c)
+	return nil
+}
+
+func (repo *OAuthInMemoryRepo) GetClient(id string) (core.OAuthClient, error) {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	for _, c := range repo.clients {
+		if c.ID == id {
+			return c, nil
+		}
+	}
+	return core.OAuthClient{}, ErrClientNotFound
+}
+
+func (repo *OAuthInMemoryRepo) ListClients() ([]core.OAuthClient, error) {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	return append([]core.OAuthClient(nil), repo.clients...), nil
+}
+
+func (repo *OAuthInMemoryRepo) SaveCode(c core.AuthorizationCode) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	repo.codes[c.CodeHash] = c
+	return nil
+}
+
+func (repo *OAuthInMemoryRepo) TakeCode(codeHash string) (core.AuthorizationCode, error) {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	c, ok := repo.codes[codeHash]
+	if !ok {
+		return core.AuthorizationCode{}, ErrCodeNotFound
+	}
+	delete(repo.codes, codeHash)
+	return c, nil
+}
+
+type consentKey struct {
+	userID   int64
+	clientID string
+}
+
+func (repo *OAuthInMemoryRepo) GrantConsent(userID int64, clientID string, scopes []string) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	key := consentKey{userID, clientID}
+	repo.consents[key] = mergeScopes(repo.consents[key], scopes)
+	return nil
+}
+
+func (repo *OAuthInMemoryRepo) ConsentedScopes(userID int64, clientID string) ([]string, error) {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	return repo.consents[consentKey{userID, clientID}], nil
+}
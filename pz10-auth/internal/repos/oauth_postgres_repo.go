@@ -0,0 +1,169 @@
+package repos
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+
+	"github.com/icestormerrr/pz10-auth/internal/core"
+)
+
+var ErrClientNotFound = errors.New("oauth client not found")
+var ErrCodeNotFound = errors.New("authorization code not found")
+
+// OAuthPostgresRepo клиенты, коды авторизации и согласия пользователей.
+// Списки (redirect URI, grant types, scopes) хранятся строкой через пробел, как scope в OAuth.
+type OAuthPostgresRepo struct {
+	db *sql.DB
+}
+
+// NewOAuthPostgresRepo использует пул соединений репозитория пользователей
+func NewOAuthPostgresRepo(db *sql.DB) (*OAuthPostgresRepo, error) {
+	repo := &OAuthPostgresRepo{db: db}
+	if err := repo.migrate(); err != nil {
+		return nil, err
+	}
+	return repo, nil
+}
+
+func (repo *OAuthPostgresRepo) migrate() error {
+	_, err := repo.db.Exec(`
+		CREATE TABLE IF NOT EXISTS oauth_clients (
+			id            TEXT        PRIMARY KEY,
+			name          TEXT        NOT NULL,
+			secret_hash   TEXT        NOT NULL DEFAULT '',
+			public        BOOLEAN     NOT NULL DEFAULT FALSE,
+			redirect_uris TEXT        NOT NULL DEFAULT '',
+			grant_types   TEXT        NOT NULL DEFAULT '',
+			scopes        TEXT        NOT NULL DEFAULT '',
+			created_at    TIMESTAMPTZ NOT NULL DEFAULT NOW()
+		);
+		CREATE TABLE IF NOT EXISTS oauth_codes (
+			code_hash      TEXT        PRIMARY KEY,
+			client_id      TEXT        NOT NULL REFERENCES oauth_clients(id) ON DELETE CASCADE,
+			user_id        BIGINT      NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			redirect_uri   TEXT        NOT NULL,
+			scope          TEXT        NOT NULL DEFAULT '',
+			code_challenge TEXT        NOT NULL DEFAULT '',
+			expires_at     TIMESTAMPTZ NOT NULL
+		);
+		CREATE TABLE IF NOT EXISTS oauth_consents (
+			user_id    BIGINT      NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			client_id  TEXT        NOT NULL REFERENCES oauth_clients(id) ON DELETE CASCADE,
+			scope      TEXT        NOT NULL DEFAULT '',
+			granted_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+			PRIMARY KEY (user_id, client_id)
+		);`)
+	return err
+}
+
+func joinList(list []string) string { return strings.Join(list, " ") }
+
+func splitList(s string) []string { return strings.Fields(s) }
+
+func (repo *OAuthPostgresRepo) CreateClient(c core.OAuthClient) error {
+	_, err := repo.db.ExecContext(context.Background(),
+		`INSERT INTO oauth_clients (id, name, secret_hash, public, redirect_uris, grant_types, scopes, created_at)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`,
+		c.ID, c.Name, c.SecretHash, c.Public, joinList(c.RedirectURIs), joinList(c.GrantTypes), joinList(c.Scopes), c.CreatedAt)
+	return err
+}
+
+const clientColumns = "id, name, secret_hash, public, redirect_uris, grant_types, scopes, created_at"
+
+func scanClient(row interface{ Scan(...any) error }) (core.OAuthClient, error) {
+	var c core.OAuthClient
+	var redirects, grants, scopes string
+	err := row.Scan(&c.ID, &c.Name, &c.SecretHash, &c.Public, &redirects, &grants, &scopes, &c.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return core.OAuthClient{}, ErrClientNotFound
+	}
+	c.RedirectURIs, c.GrantTypes, c.Scopes = splitList(redirects), splitList(grants), splitList(scopes)
+	return c, err
+}
+
+func (repo *OAuthPostgresRepo) GetClient(id string) (core.OAuthClient, error) {
+	row := repo.db.QueryRowContext(context.Background(),
+		`SELECT `+clientColumns+` FROM oauth_clients WHERE id = $1`, id)
+	return scanClient(row)
+}
+
+func (repo *OAuthPostgresRepo) ListClients() ([]core.OAuthClient, error) {
+	rows, err := repo.db.QueryContext(context.Background(),
+		`SELECT `+clientColumns+` FROM oauth_clients ORDER BY created_at`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []core.OAuthClient
+	for rows.Next() {
+		c, err := scanClient(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, c)
+	}
+	return out, rows.Err()
+}
+
+func (repo *OAuthPostgresRepo) SaveCode(c core.AuthorizationCode) error {
+	ctx := context.Background()
+	// заодно убираем коды, которые так и не обменяли
+	if _, err := repo.db.ExecContext(ctx, `DELETE FROM oauth_codes WHERE expires_at < NOW()`); err != nil {
+		return err
+	}
+	_, err := repo.db.ExecContext(ctx,
+		`INSERT INTO oauth_codes (code_hash, client_id, user_id, redirect_uri, scope, code_challenge, expires_at)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+		c.CodeHash, c.ClientID, c.UserID, c.RedirectURI, c.Scope, c.CodeChallenge, c.ExpiresAt)
+	return err
+}
+
+func (repo *OAuthPostgresRepo) TakeCode(codeHash string) (core.AuthorizationCode, error) {
+	var c core.AuthorizationCode
+	err := repo.db.QueryRowContext(context.Background(),
+		`DELETE FROM oauth_codes WHERE code_hash = $1
+		 RETURNING code_hash, client_id, user_id, redirect_uri, scope, code_challenge, expires_at`, codeHash).
+		Scan(&c.CodeHash, &c.ClientID, &c.UserID, &c.RedirectURI, &c.Scope, &c.CodeChallenge, &c.ExpiresAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return core.AuthorizationCode{}, ErrCodeNotFound
+	}
+	return c, err
+}
+
+func (repo *OAuthPostgresRepo) GrantConsent(userID int64, clientID string, scopes []string) error {
+	granted, err := repo.ConsentedScopes(userID, clientID)
+	if err != nil {
+		return err
+	}
+	_, err = repo.db.ExecContext(context.Background(),
+		`INSERT INTO oauth_consents (user_id, client_id, scope) VALUES ($1, $2, $3)
+		 ON CONFLICT (user_id, client_id) DO UPDATE SET scope = EXCLUDED.scope, granted_at = NOW()`,
+		userID, clientID, joinList(mergeScopes(granted, scopes)))
+	return err
+}
+
+func (repo *OAuthPostgresRepo) ConsentedScopes(userID int64, clientID string) ([]string, error) {
+	var scope string
+	err := repo.db.QueryRowContext(context.Background(),
+		`SELECT scope FROM oauth_consents WHERE user_id = $1 AND client_id = $2`, userID, clientID).Scan(&scope)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	return splitList(scope), err
+}
+
+// mergeScopes объединение списков без повторов
+func mergeScopes(a, b []string) []string {
+	seen := map[string]bool{}
+	var out []string
+	for _, s := range append(append([]string{}, a...), b...) {
+		if !seen[s] {
+			seen[s] = true
+			out = append(out, s)
+		}
+	}
+	return out
+}
@@ -2,9 +2,13 @@ package repos
 
 import (
 	"context"
+	"errors"
+	"sort"
 	"strconv"
+	"strings"
 	"time"
 
+	"github.com/icestormerrr/pz10-auth/internal/core"
 	"github.com/redis/go-redis/v9"
 )
 
@@ -16,10 +20,20 @@ type SessionRedisRepoConfig struct {
 	RedisDB       int
 }
 
+var ErrSessionNotFound = errors.New("session not found")
+var ErrTokenReused = errors.New("refresh token reused")
+var ErrConsentTicketNotFound = errors.New("consent ticket not found")
+
+// session/{sessionID} — hash с данными семейства и jti текущей пары токенов,
+// user-sessions/{userID} — множество ID сессий пользователя,
+// revoked-token/{jti} — отозванный access токен (живёт до истечения токена),
+// tokens-revoked-before/{userID} — токены пользователя, выпущенные не позже этого времени, отозваны,
+// consent-ticket/{hash} — пользователь и клиент между входом и подтверждением доступа на странице авторизации,
+// login-failures — sorted set неудачных входов (score — время в наносекундах) за последний час
 type SessionRedisRepo struct {
 	db     *redis.Client
 	config SessionRedisRepoConfig
-} // key = userID, value = refreshToken
+}
 
 func NewSessionRedisRepo(config SessionRedisRepoConfig) *SessionRedisRepo {
 	db := redis.NewClient(&redis.Options{
@@ -27,15 +41,269 @@ func NewSessionRedisRepo(config SessionRedisRepoConfig) *SessionRedisRepo {
 		Password: config.RedisPassword,
 		DB:       config.RedisDB,
 	})
-	return &SessionRedisRepo{db: db}
+	return &SessionRedisRepo{db: db, config: config}
 }
 
-func (repo *SessionRedisRepo) SetRefreshToken(userID int64, refreshTokenToSet string) error {
-	return repo.db.Set(context.Background(), "auth/"+strconv.FormatInt(userID, 10), refreshTokenToSet, repo.config.RefreshTTL).Err()
+func sessionKey(sessionID string) string { return "session/" + sessionID }
+
+func userSessionsKey(userID int64) string { return "user-sessions/" + strconv.FormatInt(userID, 10) }
+
+func revokedTokenKey(jti string) string { return "revoked-token/" + jti }
+
+func consentTicketKey(ticketHash string) string { return "consent-ticket/" + ticketHash }
+
+// secondsCutoffLimit отметки отзыва меньше этого значения записаны в секундах, а не в миллисекундах
+const secondsCutoffLimit = 1e11
+
+func revokedBeforeKey(userID int64) string {
+	return "tokens-revoked-before/" + strconv.FormatInt(userID, 10)
 }
 
-func (repo *SessionRedisRepo) GetRefreshToken(userID int64) (string, error) {
-	return repo.db.Get(context.Background(), "auth/"+strconv.FormatInt(userID, 10)).Result()
+func (repo *SessionRedisRepo) CreateSession(s core.Session, tokens core.IssuedTokens) error {
+	ctx := context.Background()
+	_, err := repo.db.TxPipelined(ctx, func(p redis.Pipeliner) error {
+		p.HSet(ctx, sessionKey(s.ID), map[string]any{
+			"user_id":      s.UserID,
+			"jti":          tokens.RefreshID,
+			"access_jti":   tokens.AccessID,
+			"access_exp":   tokens.AccessExpiresAt.Unix(),
+			"user_agent":   s.UserAgent,
+			"ip":           s.IP,
+			"created_at":   s.CreatedAt.Unix(),
+			"last_used_at": s.LastUsedAt.Unix(),
+		})
+		p.Expire(ctx, sessionKey(s.ID), repo.config.RefreshTTL)
+		p.SAdd(ctx, userSessionsKey(s.UserID), s.ID)
+		p.Expire(ctx, userSessionsKey(s.UserID), repo.config.RefreshTTL)
+		return nil
+	})
+	return err
+}
+
+// KEYS[1] — сессия; ARGV: старый jti, новый jti, время, TTL (мс), ключ множества сессий пользователя, ID сессии, IP,
+// jti и срок действия нового access токена.
+// Возвращает {статус, jti прежнего access токена, его срок действия}:
+// 1 — заменён, 0 — сессии нет, -1 — предъявлен устаревший токен, семейство удалено.
+var rotateScript = redis.NewScript(`
+local cur = redis.call('HGET', KEYS[1], 'jti')
+if not cur then
+	return {0, '', '0'}
+end
+local access = redis.call('HMGET', KEYS[1], 'access_jti', 'access_exp')
+local accessJTI = access[1] or ''
+local accessExp = access[2] or '0'
+if cur ~= ARGV[1] then
+	redis.call('DEL', KEYS[1])
+	redis.call('SREM', ARGV[5], ARGV[6])
+	return {-1, accessJTI, accessExp}
+end
+redis.call('HSET', KEYS[1], 'jti', ARGV[2], 'last_used_at', ARGV[3], 'ip', ARGV[7], 'access_jti', ARGV[8], 'access_exp', ARGV[9])
+redis.call('PEXPIRE', KEYS[1], ARGV[4])
+redis.call('PEXPIRE', ARGV[5], ARGV[4])
+return {1, accessJTI, accessExp}
+`)
+
+func (repo *SessionRedisRepo) RotateRefreshToken(sessionID, oldRefreshJTI string, next core.IssuedTokens, ip string) (core.Session, error) {
+	ctx := context.Background()
+	s, err := repo.getSession(ctx, sessionID)
+	if err != nil {
+		return core.Session{}, err
+	}
+
+	res, err := rotateScript.Run(ctx, repo.db, []string{sessionKey(sessionID)},
+		oldRefreshJTI, next.RefreshID, time.Now().Unix(), repo.config.RefreshTTL.Milliseconds(),
+		userSessionsKey(s.UserID), sessionID, ip, next.AccessID, next.AccessExpiresAt.Unix(),
+	).Slice()
+	if err != nil {
+		return core.Session{}, err
+	}
+	status, _ := res[0].(int64)
+	if status == 0 {
+		return core.Session{}, ErrSessionNotFound
+	}
+
+	// прежний access токен этой сессии больше не нужен (или украден вместе с refresh токеном)
+	prevJTI, _ := res[1].(string)
+	prevExp, _ := res[2].(string)
+	exp, _ := strconv.ParseInt(prevExp, 10, 64)
+	if err := repo.RevokeToken(prevJTI, time.Unix(exp, 0)); err != nil {
+		return core.Session{}, err
+	}
+
+	if status == -1 {
+		return core.Session{}, ErrTokenReused
+	}
+	s.LastUsedAt = time.Now()
+	s.IP = ip
+	return s.Session, nil
+}
+
+// storedSession сессия вместе с текущим access токеном
+type storedSession struct {
+	core.Session
+	accessJTI string
+	accessExp time.Time
+}
+
+func (repo *SessionRedisRepo) getSession(ctx context.Context, sessionID string) (storedSession, error) {
+	m, err := repo.db.HGetAll(ctx, sessionKey(sessionID)).Result()
+	if err != nil {
+		return storedSession{}, err
+	}
+	if len(m) == 0 {
+		return storedSession{}, ErrSessionNotFound
+	}
+	userID, _ := strconv.ParseInt(m["user_id"], 10, 64)
+	created, _ := strconv.ParseInt(m["created_at"], 10, 64)
+	lastUsed, _ := strconv.ParseInt(m["last_used_at"], 10, 64)
+	accessExp, _ := strconv.ParseInt(m["access_exp"], 10, 64)
+	return storedSession{
+		Session: core.Session{
+			ID:         sessionID,
+			UserID:     userID,
+			UserAgent:  m["user_agent"],
+			IP:         m["ip"],
+			CreatedAt:  time.Unix(created, 0),
+			LastUsedAt: time.Unix(lastUsed, 0),
+		},
+		accessJTI: m["access_jti"],
+		accessExp: time.Unix(accessExp, 0),
+	}, nil
+}
+
+// ListSessions активные сессии пользователя, недавно использованные — первыми
+func (repo *SessionRedisRepo) ListSessions(userID int64) ([]core.Session, error) {
+	ctx := context.Background()
+	ids, err := repo.db.SMembers(ctx, userSessionsKey(userID)).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]core.Session, 0, len(ids))
+	for _, id := range ids {
+		s, err := repo.getSession(ctx, id)
+		if errors.Is(err, ErrSessionNotFound) {
+			// сессия истекла по TTL, убираем её из множества
+			repo.db.SRem(ctx, userSessionsKey(userID), id)
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, s.Session)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].LastUsedAt.After(out[j].LastUsedAt) })
+	return out, nil
+}
+
+func (repo *SessionRedisRepo) DeleteSession(userID int64, sessionID string) error {
+	ctx := context.Background()
+	s, err := repo.getSession(ctx, sessionID)
+	// чужая сессия для пользователя неотличима от несуществующей
+	if errors.Is(err, ErrSessionNotFound) || (err == nil && s.UserID != userID) {
+		return ErrSessionNotFound
+	}
+	if err != nil {
+		return err
+	}
+	_, err = repo.db.TxPipelined(ctx, func(p redis.Pipeliner) error {
+		p.Del(ctx, sessionKey(sessionID))
+		p.SRem(ctx, userSessionsKey(userID), sessionID)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return repo.RevokeToken(s.accessJTI, s.accessExp)
+}
+
+func (repo *SessionRedisRepo) DeleteUserSessions(userID int64, exceptID string) (int, error) {
+	ctx := context.Background()
+	ids, err := repo.db.SMembers(ctx, userSessionsKey(userID)).Result()
+	if err != nil {
+		return 0, err
+	}
+
+	n := 0
+	for _, id := range ids {
+		if id == exceptID {
+			continue
+		}
+		s, err := repo.getSession(ctx, id)
+		if errors.Is(err, ErrSessionNotFound) {
+			repo.db.SRem(ctx, userSessionsKey(userID), id)
+			continue
+		}
+		if err != nil {
+			return n, err
+		}
+		deleted, err := repo.db.Del(ctx, sessionKey(id)).Result()
+		if err != nil {
+			return n, err
+		}
+		repo.db.SRem(ctx, userSessionsKey(userID), id)
+		if err := repo.RevokeToken(s.accessJTI, s.accessExp); err != nil {
+			return n, err
+		}
+		n += int(deleted)
+	}
+	return n, nil
+}
+
+func (repo *SessionRedisRepo) RefreshTokenActive(sessionID, jti string) (bool, error) {
+	cur, err := repo.db.HGet(context.Background(), sessionKey(sessionID), "jti").Result()
+	if errors.Is(err, redis.Nil) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return jti != "" && cur == jti, nil
+}
+
+func (repo *SessionRedisRepo) RevokeToken(jti string, expiresAt time.Time) error {
+	ttl := time.Until(expiresAt)
+	// истёкший токен и так не пройдёт проверку, хранить его незачем
+	if jti == "" || ttl <= 0 {
+		return nil
+	}
+	return repo.db.Set(context.Background(), revokedTokenKey(jti), 1, ttl).Err()
+}
+
+func (repo *SessionRedisRepo) RevokeUserTokens(userID int64, before time.Time) error {
+	// дольше refresh токена не живёт ни один токен, после этого отметка не нужна
+	return repo.db.Set(context.Background(), revokedBeforeKey(userID), before.UnixMilli(), repo.config.RefreshTTL).Err()
+}
+
+func (repo *SessionRedisRepo) TokenRevoked(jti string, userID int64, issuedAt time.Time) (bool, error) {
+	ctx := context.Background()
+	var revoked *redis.IntCmd
+	var before *redis.StringCmd
+	_, err := repo.db.Pipelined(ctx, func(p redis.Pipeliner) error {
+		if jti != "" {
+			revoked = p.Exists(ctx, revokedTokenKey(jti))
+		}
+		before = p.Get(ctx, revokedBeforeKey(userID))
+		return nil
+	})
+	if err != nil && !errors.Is(err, redis.Nil) {
+		return false, err
+	}
+	if revoked != nil && revoked.Val() > 0 {
+		return true, nil
+	}
+	cutoff, err := before.Int64()
+	if errors.Is(err, redis.Nil) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	// отметки, записанные прежними версиями, хранились в секундах
+	if cutoff < secondsCutoffLimit {
+		cutoff *= 1000
+	}
+	return issuedAt.UnixMilli() < cutoff, nil
 }
 
 func (repo *SessionRedisRepo) IncLoginAttempts(email string) (int64, error) {
@@ -55,3 +323,56 @@ func (repo *SessionRedisRepo) ResetLoginAttempts(email string) error {
 	key := "login-attempts/" + email
 	return repo.db.Del(context.Background(), key).Err()
 }
+
+func (repo *SessionRedisRepo) SaveConsentTicket(ticketHash string, userID int64, clientID string, ttl time.Duration) error {
+	value := strconv.FormatInt(userID, 10) + " " + clientID
+	return repo.db.Set(context.Background(), consentTicketKey(ticketHash), value, ttl).Err()
+}
+
+func (repo *SessionRedisRepo) TakeConsentTicket(ticketHash string) (int64, string, error) {
+	value, err := repo.db.GetDel(context.Background(), consentTicketKey(ticketHash)).Result()
+	if errors.Is(err, redis.Nil) {
+		return 0, "", ErrConsentTicketNotFound
+	}
+	if err != nil {
+		return 0, "", err
+	}
+	id, clientID, _ := strings.Cut(value, " ")
+	userID, err := strconv.ParseInt(id, 10, 64)
+	return userID, clientID, err
+}
+
+const loginFailuresKey = "login-failures"
+
+// loginFailuresWindow дольше статистика неудачных входов не хранится
+const loginFailuresWindow = time.Hour
+
+func (repo *SessionRedisRepo) RecordLoginFailure(email string) error {
+	ctx := context.Background()
+	now := time.Now()
+	_, err := repo.db.TxPipelined(ctx, func(p redis.Pipeliner) error {
+		p.ZAdd(ctx, loginFailuresKey, redis.Z{
+			Score:  float64(now.UnixNano()),
+			Member: strconv.FormatInt(now.UnixNano(), 10) + "/" + email,
+		})
+		p.ZRemRangeByScore(ctx, loginFailuresKey, "-inf", strconv.FormatInt(now.Add(-loginFailuresWindow).UnixNano(), 10))
+		p.Expire(ctx, loginFailuresKey, loginFailuresWindow)
+		return nil
+	})
+	return err
+}
+
+func (repo *SessionRedisRepo) CountLoginFailures(since time.Time) (int64, error) {
+	return repo.db.ZCount(context.Background(), loginFailuresKey, strconv.FormatInt(since.UnixNano(), 10), "+inf").Result()
+}
+
+// CountActiveSessions число сессий (семейств refresh токенов), не истёкших по TTL
+func (repo *SessionRedisRepo) CountActiveSessions() (int64, error) {
+	ctx := context.Background()
+	var n int64
+	iter := repo.db.Scan(ctx, 0, sessionKey("*"), 1000).Iterator()
+	for iter.Next(ctx) {
+		n++
+	}
+	return n, iter.Err()
+}
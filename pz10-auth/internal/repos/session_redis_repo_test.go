@@ -0,0 +1,183 @@
+package repos
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/icestormerrr/pz10-auth/internal/core"
+	"github.com/icestormerrr/pz10-auth/internal/utils/jwt"
+)
+
+func newTestSessionRepo(t *testing.T) *SessionRedisRepo {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	return NewSessionRedisRepo(SessionRedisRepoConfig{
+		RefreshTTL: time.Hour,
+		RedisHost:  mr.Host(),
+		RedisPort:  mr.Port(),
+	})
+}
+
+// pair пара jti: refresh и access с тем же суффиксом
+func pair(id string) core.IssuedTokens {
+	return core.IssuedTokens{RefreshID: "r-" + id, AccessID: "a-" + id, AccessExpiresAt: time.Now().Add(time.Minute)}
+}
+
+func revoked(t *testing.T, repo *SessionRedisRepo, jti string) bool {
+	t.Helper()
+	ok, err := repo.TokenRevoked(jti, 1, time.Now().Add(-time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ok
+}
+
+func TestRotateDetectsReuse(t *testing.T) {
+	repo := newTestSessionRepo(t)
+	now := time.Now()
+	if err := repo.CreateSession(core.Session{ID: "s1", UserID: 1, CreatedAt: now, LastUsedAt: now}, pair("1")); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := repo.RotateRefreshToken("s1", "r-1", pair("2"), "127.0.0.1"); err != nil {
+		t.Fatal(err)
+	}
+	if !revoked(t, repo, "a-1") || revoked(t, repo, "a-2") {
+		t.Fatal("only the previous access token must be revoked after rotation")
+	}
+	// повторное предъявление уже обменянного токена отзывает всё семейство
+	if _, err := repo.RotateRefreshToken("s1", "r-1", pair("3"), "10.0.0.1"); !errors.Is(err, ErrTokenReused) {
+		t.Fatalf("want ErrTokenReused got %v", err)
+	}
+	if !revoked(t, repo, "a-2") {
+		t.Fatal("access token of a stolen family must be revoked")
+	}
+	if _, err := repo.RotateRefreshToken("s1", "r-2", pair("4"), "127.0.0.1"); !errors.Is(err, ErrSessionNotFound) {
+		t.Fatalf("family must be revoked, got %v", err)
+	}
+	if list, _ := repo.ListSessions(1); len(list) != 0 {
+		t.Fatalf("want no sessions got %d", len(list))
+	}
+}
+
+func TestSessionsPerDevice(t *testing.T) {
+	repo := newTestSessionRepo(t)
+	now := time.Now()
+	for _, id := range []string{"phone", "laptop", "tablet"} {
+		if err := repo.CreateSession(core.Session{ID: id, UserID: 7, CreatedAt: now, LastUsedAt: now}, pair(id)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	list, err := repo.ListSessions(7)
+	if err != nil || len(list) != 3 {
+		t.Fatalf("want 3 sessions got %d (%v)", len(list), err)
+	}
+
+	if err := repo.DeleteSession(8, "phone"); !errors.Is(err, ErrSessionNotFound) {
+		t.Fatalf("foreign session must not be deleted, got %v", err)
+	}
+	if err := repo.DeleteSession(7, "phone"); err != nil {
+		t.Fatal(err)
+	}
+	if !revoked(t, repo, "a-phone") {
+		t.Fatal("access token of a deleted session must be revoked")
+	}
+	n, err := repo.DeleteUserSessions(7, "laptop")
+	if err != nil || n != 1 {
+		t.Fatalf("want 1 deleted got %d (%v)", n, err)
+	}
+	list, _ = repo.ListSessions(7)
+	if len(list) != 1 || list[0].ID != "laptop" {
+		t.Fatalf("unexpected sessions %+v", list)
+	}
+}
+
+func TestRevokeUserTokens(t *testing.T) {
+	repo := newTestSessionRepo(t)
+	now := time.Now()
+	if err := repo.RevokeUserTokens(1, now); err != nil {
+		t.Fatal(err)
+	}
+	if ok, _ := repo.TokenRevoked("", 1, now.Add(-time.Minute)); !ok {
+		t.Fatal("token issued before logout-all must be revoked")
+	}
+	if ok, _ := repo.TokenRevoked("", 1, now.Add(time.Millisecond)); ok {
+		t.Fatal("token issued after logout-all must stay valid")
+	}
+	if ok, _ := repo.TokenRevoked("", 2, now.Add(-time.Minute)); ok {
+		t.Fatal("other users are not affected")
+	}
+
+	// истёкший токен в список не попадает
+	if err := repo.RevokeToken("old", now.Add(-time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	if ok, _ := repo.TokenRevoked("old", 2, now); ok {
+		t.Fatal("expired token must not be stored")
+	}
+}
+
+// токен, выпущенный в ту же секунду сразу после отзыва, остаётся действительным
+func TestRevokeUserTokensSameSecond(t *testing.T) {
+	repo := newTestSessionRepo(t)
+	tokens, err := jwt.NewKeyRingTokenManager(jwt.KeyRingConfig{Alg: jwt.AlgES256, Retention: time.Hour, Issuer: "test", Audience: "clients"}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sign := func() core.Claims {
+		t.Helper()
+		raw, err := tokens.Sign(core.TokenParams{UserID: 1, Role: core.RoleUser, Type: core.TokenAccess}, time.Minute)
+		if err != nil {
+			t.Fatal(err)
+		}
+		parsed, err := tokens.Parse(raw)
+		if err != nil {
+			t.Fatal(err)
+		}
+		claims, err := core.NewClaims(parsed)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return claims
+	}
+
+	// весь сценарий должен уложиться в одну секунду
+	if ms := time.Now().Nanosecond() / int(time.Millisecond); ms > 900 {
+		time.Sleep(time.Duration(1000-ms) * time.Millisecond)
+	}
+	before := sign()
+	time.Sleep(2 * time.Millisecond)
+	if err := repo.RevokeUserTokens(1, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(time.Millisecond)
+	after := sign()
+	if after.IssuedAt.Unix() != before.IssuedAt.Unix() {
+		t.Fatalf("tokens must be issued in the same second: %v %v", before.IssuedAt, after.IssuedAt)
+	}
+
+	if ok, _ := repo.TokenRevoked("", 1, before.IssuedAt); !ok {
+		t.Fatal("token issued before revocation must be revoked")
+	}
+	if ok, _ := repo.TokenRevoked("", 1, after.IssuedAt); ok {
+		t.Fatal("token issued after revocation in the same second must stay valid")
+	}
+}
+
+// отметка прежнего формата в секундах продолжает работать
+func TestRevokeUserTokensLegacyCutoff(t *testing.T) {
+	repo := newTestSessionRepo(t)
+	now := time.Now()
+	if err := repo.db.Set(context.Background(), revokedBeforeKey(1), now.Unix(), time.Hour).Err(); err != nil {
+		t.Fatal(err)
+	}
+	if ok, _ := repo.TokenRevoked("", 1, now.Add(-time.Minute)); !ok {
+		t.Fatal("legacy cutoff must revoke older tokens")
+	}
+	if ok, _ := repo.TokenRevoked("", 1, now.Add(time.Second)); ok {
+		t.Fatal("legacy cutoff must keep newer tokens")
+	}
+}
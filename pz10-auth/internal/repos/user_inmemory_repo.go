@@ -2,37 +2,55 @@ package repos
 
 import (
 	"errors"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/icestormerrr/pz10-auth/internal/core"
 	"golang.org/x/crypto/bcrypt"
 )
 
 type UserRecord struct {
-	ID    int64
-	Email string
-	Role  string
-	Hash  []byte
+	ID        int64
+	Email     string
+	Name      string
+	Role      string
+	Disabled  bool
+	Hash      []byte
+	CreatedAt time.Time
 }
 
-type UserInMemoryRepo struct{ users []UserRecord }
+func (u UserRecord) user() core.User {
+	return core.User{ID: u.ID, Email: u.Email, Name: u.Name, Role: u.Role, Disabled: u.Disabled, CreatedAt: u.CreatedAt}
+}
+
+// UserInMemoryRepo хранилище пользователей в памяти (для тестов)
+type UserInMemoryRepo struct {
+	mu     sync.RWMutex
+	users  []UserRecord
+	nextID int64
+	cost   int
+}
 
 func NewUserInMemoryRepo() *UserInMemoryRepo {
-	hash := func(s string) []byte { h, _ := bcrypt.GenerateFromPassword([]byte(s), bcrypt.DefaultCost); return h }
-	return &UserInMemoryRepo{
-		users: []UserRecord{
-			{ID: 1, Email: "admin@example.com", Role: "admin", Hash: hash("secret123")},
-			{ID: 2, Email: "user@example.com", Role: "user", Hash: hash("secret123")},
-		},
-	}
+	// в тестах минимальная стоимость bcrypt, чтобы не тратить время на хэширование
+	return &UserInMemoryRepo{nextID: 1, cost: bcrypt.MinCost}
 }
 
 var ErrNotFound = errors.New("user not found")
 var ErrBadCreds = errors.New("bad credentials")
+var ErrEmailTaken = errors.New("email already in use")
+
+// ErrLastAdmin изменение оставило бы систему без активного администратора
+var ErrLastAdmin = errors.New("last active admin")
 
 func (r *UserInMemoryRepo) GetById(id int64) (core.User, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
 	for _, u := range r.users {
 		if u.ID == id {
-			return core.User{ID: u.ID, Email: u.Email, Role: u.Role}, nil
+			return u.user(), nil
 		}
 	}
 
@@ -51,12 +69,141 @@ func (r *UserInMemoryRepo) getByEmail(email string) (UserRecord, error) {
 }
 
 func (r *UserInMemoryRepo) CheckPassword(email, pass string) (core.User, error) {
+	r.mu.RLock()
 	u, err := r.getByEmail(email)
+	r.mu.RUnlock()
 	if err != nil {
 		return core.User{}, ErrNotFound
 	}
 	if bcrypt.CompareHashAndPassword(u.Hash, []byte(pass)) != nil {
 		return core.User{}, ErrBadCreds
 	}
-	return core.User{ID: u.ID, Email: u.Email, Role: u.Role}, nil
+	return u.user(), nil
+}
+
+func (r *UserInMemoryRepo) Create(u core.User, pass string) (core.User, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(pass), r.cost)
+	if err != nil {
+		return core.User{}, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, err := r.getByEmail(u.Email); err == nil {
+		return core.User{}, ErrEmailTaken
+	}
+	rec := UserRecord{ID: r.nextID, Email: u.Email, Name: u.Name, Role: u.Role, Hash: hash, CreatedAt: time.Now()}
+	r.nextID++
+	r.users = append(r.users, rec)
+	return rec.user(), nil
+}
+
+// update применяет fn к записи пользователя id под блокировкой
+func (r *UserInMemoryRepo) update(id int64, fn func(*UserRecord)) (core.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i := range r.users {
+		if r.users[i].ID == id {
+			fn(&r.users[i])
+			return r.users[i].user(), nil
+		}
+	}
+	return core.User{}, ErrNotFound
+}
+
+// updateGuarded как update, но отказывает с ErrLastAdmin, если после изменения не останется активного администратора
+func (r *UserInMemoryRepo) updateGuarded(id int64, fn func(*UserRecord)) (core.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i := range r.users {
+		if r.users[i].ID != id {
+			continue
+		}
+		rec := r.users[i]
+		fn(&rec)
+		if isActiveAdmin(r.users[i]) && !isActiveAdmin(rec) && r.activeAdmins() <= 1 {
+			return core.User{}, ErrLastAdmin
+		}
+		r.users[i] = rec
+		return rec.user(), nil
+	}
+	return core.User{}, ErrNotFound
+}
+
+func isActiveAdmin(u UserRecord) bool {
+	return u.Role == core.RoleAdmin && !u.Disabled
+}
+
+func (r *UserInMemoryRepo) activeAdmins() int {
+	n := 0
+	for _, u := range r.users {
+		if isActiveAdmin(u) {
+			n++
+		}
+	}
+	return n
+}
+
+func (r *UserInMemoryRepo) UpdateProfile(id int64, name string) (core.User, error) {
+	return r.update(id, func(u *UserRecord) { u.Name = name })
+}
+
+func (r *UserInMemoryRepo) SetRole(id int64, role string) (core.User, error) {
+	return r.updateGuarded(id, func(u *UserRecord) { u.Role = role })
+}
+
+func (r *UserInMemoryRepo) SetPassword(id int64, pass string) error {
+	hash, err := bcrypt.GenerateFromPassword([]byte(pass), r.cost)
+	if err != nil {
+		return err
+	}
+	_, err = r.update(id, func(u *UserRecord) { u.Hash = hash })
+	return err
+}
+
+func (r *UserInMemoryRepo) SetDisabled(id int64, disabled bool) (core.User, error) {
+	return r.updateGuarded(id, func(u *UserRecord) { u.Disabled = disabled })
+}
+
+func (r *UserInMemoryRepo) List(f core.UserFilter) ([]core.User, int, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	q := strings.ToLower(f.Query)
+	matched := []core.User{}
+	for _, u := range r.users {
+		if f.Role != "" && u.Role != f.Role {
+			continue
+		}
+		if q != "" && !strings.Contains(strings.ToLower(u.Email), q) && !strings.Contains(strings.ToLower(u.Name), q) {
+			continue
+		}
+		if f.Disabled != nil && u.Disabled != *f.Disabled {
+			continue
+		}
+		matched = append(matched, u.user())
+	}
+
+	total := len(matched)
+	start := min(f.Offset, total)
+	end := min(start+f.Limit, total)
+	return matched[start:end], total, nil
+}
+
+func (r *UserInMemoryRepo) CountByRole() (map[string]int, int, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	byRole := map[string]int{}
+	disabled := 0
+	for _, u := range r.users {
+		byRole[u.Role]++
+		if u.Disabled {
+			disabled++
+		}
+	}
+	return byRole, disabled, nil
 }
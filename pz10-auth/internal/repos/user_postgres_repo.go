@@ -0,0 +1,260 @@
+package repos
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/icestormerrr/pz10-auth/internal/core"
+	"github.com/jackc/pgx/v5/pgconn"
+	_ "github.com/jackc/pgx/v5/stdlib"
+	"golang.org/x/crypto/bcrypt"
+)
+
+type UserPostgresRepoConfig struct {
+	DatabaseURL string
+	BcryptCost  int
+}
+
+type UserPostgresRepo struct {
+	db     *sql.DB
+	config UserPostgresRepoConfig
+}
+
+func NewUserPostgresRepo(config UserPostgresRepoConfig) (*UserPostgresRepo, error) {
+	db, err := sql.Open("pgx", config.DatabaseURL)
+	if err != nil {
+		return nil, err
+	}
+	db.SetMaxOpenConns(10)
+	db.SetMaxIdleConns(5)
+	db.SetConnMaxLifetime(30 * time.Minute)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	if err := db.PingContext(ctx); err != nil {
+		return nil, err
+	}
+
+	repo := &UserPostgresRepo{db: db, config: config}
+	if err := repo.migrate(); err != nil {
+		return nil, err
+	}
+	return repo, nil
+}
+
+func (repo *UserPostgresRepo) migrate() error {
+	_, err := repo.db.Exec(`
+		CREATE TABLE IF NOT EXISTS users (
+			id            BIGSERIAL PRIMARY KEY,
+			email         TEXT        NOT NULL UNIQUE,
+			name          TEXT        NOT NULL DEFAULT '',
+			role          TEXT        NOT NULL DEFAULT 'user',
+			password_hash TEXT        NOT NULL,
+			created_at    TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+			updated_at    TIMESTAMPTZ NOT NULL DEFAULT NOW()
+		);
+		ALTER TABLE users ADD COLUMN IF NOT EXISTS disabled BOOLEAN NOT NULL DEFAULT FALSE;`)
+	return err
+}
+
+// DB пул соединений для репозиториев, которые хранят данные в той же базе
+func (repo *UserPostgresRepo) DB() *sql.DB {
+	return repo.db
+}
+
+func (repo *UserPostgresRepo) Close() error {
+	return repo.db.Close()
+}
+
+const userColumns = "id, email, name, role, disabled, created_at"
+
+func scanUser(row interface{ Scan(...any) error }) (core.User, error) {
+	var u core.User
+	err := row.Scan(&u.ID, &u.Email, &u.Name, &u.Role, &u.Disabled, &u.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return core.User{}, ErrNotFound
+	}
+	return u, err
+}
+
+func (repo *UserPostgresRepo) GetById(id int64) (core.User, error) {
+	row := repo.db.QueryRowContext(context.Background(),
+		`SELECT `+userColumns+` FROM users WHERE id = $1`, id)
+	return scanUser(row)
+}
+
+func (repo *UserPostgresRepo) CheckPassword(email, pass string) (core.User, error) {
+	var u core.User
+	var hash string
+	err := repo.db.QueryRowContext(context.Background(),
+		`SELECT `+userColumns+`, password_hash FROM users WHERE email = $1`, email).
+		Scan(&u.ID, &u.Email, &u.Name, &u.Role, &u.Disabled, &u.CreatedAt, &hash)
+	if errors.Is(err, sql.ErrNoRows) {
+		return core.User{}, ErrNotFound
+	}
+	if err != nil {
+		return core.User{}, err
+	}
+	if bcrypt.CompareHashAndPassword([]byte(hash), []byte(pass)) != nil {
+		return core.User{}, ErrBadCreds
+	}
+	return u, nil
+}
+
+func (repo *UserPostgresRepo) Create(u core.User, pass string) (core.User, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(pass), repo.config.BcryptCost)
+	if err != nil {
+		return core.User{}, err
+	}
+	row := repo.db.QueryRowContext(context.Background(),
+		`INSERT INTO users (email, name, role, password_hash) VALUES ($1, $2, $3, $4)
+		 RETURNING `+userColumns, u.Email, u.Name, u.Role, string(hash))
+	created, err := scanUser(row)
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+		return core.User{}, ErrEmailTaken
+	}
+	return created, err
+}
+
+func (repo *UserPostgresRepo) UpdateProfile(id int64, name string) (core.User, error) {
+	row := repo.db.QueryRowContext(context.Background(),
+		`UPDATE users SET name = $2, updated_at = NOW() WHERE id = $1 RETURNING `+userColumns, id, name)
+	return scanUser(row)
+}
+
+func (repo *UserPostgresRepo) SetRole(id int64, role string) (core.User, error) {
+	return repo.updateGuarded(`UPDATE users SET role = $2, updated_at = NOW() WHERE id = $1 RETURNING `+userColumns, id, role)
+}
+
+func (repo *UserPostgresRepo) SetPassword(id int64, pass string) error {
+	hash, err := bcrypt.GenerateFromPassword([]byte(pass), repo.config.BcryptCost)
+	if err != nil {
+		return err
+	}
+	res, err := repo.db.ExecContext(context.Background(),
+		`UPDATE users SET password_hash = $2, updated_at = NOW() WHERE id = $1`, id, string(hash))
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (repo *UserPostgresRepo) SetDisabled(id int64, disabled bool) (core.User, error) {
+	return repo.updateGuarded(`UPDATE users SET disabled = $2, updated_at = NOW() WHERE id = $1 RETURNING `+userColumns, id, disabled)
+}
+
+// updateGuarded выполняет update в транзакции и откатывает его с ErrLastAdmin, если не останется активного
+// администратора. Строки активных администраторов блокируются (FOR UPDATE), поэтому параллельные
+// понижения и блокировки администраторов выполняются по очереди и видят результат друг друга.
+func (repo *UserPostgresRepo) updateGuarded(update string, args ...any) (core.User, error) {
+	ctx := context.Background()
+	tx, err := repo.db.BeginTx(ctx, nil)
+	if err != nil {
+		return core.User{}, err
+	}
+	defer tx.Rollback()
+
+	var before int
+	rows, err := tx.QueryContext(ctx, `SELECT id FROM users WHERE role = $1 AND NOT disabled FOR UPDATE`, core.RoleAdmin)
+	if err != nil {
+		return core.User{}, err
+	}
+	for rows.Next() {
+		before++
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return core.User{}, err
+	}
+
+	u, err := scanUser(tx.QueryRowContext(ctx, update, args...))
+	if err != nil {
+		return core.User{}, err
+	}
+
+	var after int
+	if err := tx.QueryRowContext(ctx,
+		`SELECT COUNT(*) FROM users WHERE role = $1 AND NOT disabled`, core.RoleAdmin).Scan(&after); err != nil {
+		return core.User{}, err
+	}
+	if before > 0 && after == 0 {
+		return core.User{}, ErrLastAdmin
+	}
+	return u, tx.Commit()
+}
+
+func (repo *UserPostgresRepo) List(f core.UserFilter) ([]core.User, int, error) {
+	var where []string
+	var args []any
+	arg := func(v any) string {
+		args = append(args, v)
+		return "$" + strconv.Itoa(len(args))
+	}
+	if f.Role != "" {
+		where = append(where, "role = "+arg(f.Role))
+	}
+	if f.Query != "" {
+		p := arg("%" + f.Query + "%")
+		where = append(where, "(email ILIKE "+p+" OR name ILIKE "+p+")")
+	}
+	if f.Disabled != nil {
+		where = append(where, "disabled = "+arg(*f.Disabled))
+	}
+	cond := ""
+	if len(where) > 0 {
+		cond = " WHERE " + strings.Join(where, " AND ")
+	}
+
+	ctx := context.Background()
+	var total int
+	if err := repo.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM users`+cond, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	query := `SELECT ` + userColumns + ` FROM users` + cond + ` ORDER BY id LIMIT ` + arg(f.Limit) + ` OFFSET ` + arg(f.Offset)
+	rows, err := repo.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	out := []core.User{}
+	for rows.Next() {
+		u, err := scanUser(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		out = append(out, u)
+	}
+	return out, total, rows.Err()
+}
+
+func (repo *UserPostgresRepo) CountByRole() (map[string]int, int, error) {
+	rows, err := repo.db.QueryContext(context.Background(),
+		`SELECT role, COUNT(*), COUNT(*) FILTER (WHERE disabled) FROM users GROUP BY role`)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	byRole := map[string]int{}
+	disabled := 0
+	for rows.Next() {
+		var role string
+		var n, d int
+		if err := rows.Scan(&role, &n, &d); err != nil {
+			return nil, 0, err
+		}
+		byRole[role] = n
+		disabled += d
+	}
+	return byRole, disabled, rows.Err()
+}
@@ -1,10 +1,22 @@
 package services
 
 import (
+	"crypto/rand"
+	"encoding/hex"
 	"errors"
+	"log"
+	"strings"
 	"time"
 
 	"github.com/icestormerrr/pz10-auth/internal/core"
+	"github.com/icestormerrr/pz10-auth/internal/repos"
+)
+
+var (
+	ErrSessionNotFound      = errors.New("session_not_found")
+	ErrTooManyLoginAttempts = errors.New("too_many_login_attempts")
+	ErrUnauthorized         = errors.New("unauthorized")
+	ErrUserDisabled         = errors.New("user_disabled")
 )
 
 type AuthServiceConfig struct {
@@ -28,75 +40,175 @@ func NewAuthService(config AuthServiceConfig, u core.UserRepo, s core.SessionRep
 	}
 }
 
-func (s *AuthService) Login(email, password string) (accessToken, refreshToken string, userID int64, err error) {
+// newID случайный идентификатор сессии или токена
+func newID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+func (s *AuthService) Login(email, password string, client core.ClientInfo) (accessToken, refreshToken string, userID int64, err error) {
+	// email хранится в нижнем регистре (UserService.Register), счётчик попыток тоже ведётся по нему
+	email = strings.ToLower(strings.TrimSpace(email))
 	loginAttemptsCount, err := s.sessionRepo.IncLoginAttempts(email)
 	if err != nil {
 		return "", "", 0, errors.New("internal_error")
 	}
 
 	if loginAttemptsCount > s.config.MaxLoginAttempts {
-		return "", "", 0, errors.New("too_many_login_attempts")
+		return "", "", 0, ErrTooManyLoginAttempts
 	}
 
 	u, err := s.userRepo.CheckPassword(email, password)
 	if err != nil {
-		return "", "", 0, errors.New("unauthorized")
+		s.sessionRepo.RecordLoginFailure(email)
+		return "", "", 0, ErrUnauthorized
+	}
+	if u.Disabled {
+		return "", "", 0, ErrUserDisabled
 	}
 
 	s.sessionRepo.ResetLoginAttempts(email)
 
-	accessToken, err = s.tokenManager.Sign(u.ID, u.Email, u.Role, s.config.AccessTTL)
+	// каждый вход — отдельная сессия (устройство) со своим семейством refresh токенов
+	sessionID, err := newID()
 	if err != nil {
 		return "", "", 0, err
 	}
-
-	refreshToken, err = s.tokenManager.Sign(u.ID, u.Email, u.Role, s.config.RefreshTTL)
+	tokens, err := s.newTokenIDs()
 	if err != nil {
 		return "", "", 0, err
 	}
-
-	if err := s.sessionRepo.SetRefreshToken(u.ID, refreshToken); err != nil {
+	now := time.Now()
+	session := core.Session{
+		ID:         sessionID,
+		UserID:     u.ID,
+		UserAgent:  client.UserAgent,
+		IP:         client.IP,
+		CreatedAt:  now,
+		LastUsedAt: now,
+	}
+	if err := s.sessionRepo.CreateSession(session, tokens); err != nil {
 		return "", "", 0, err
 	}
 
+	accessToken, refreshToken, err = s.signPair(u, sessionID, tokens)
+	if err != nil {
+		return "", "", 0, err
+	}
 	return accessToken, refreshToken, u.ID, nil
 }
 
-func (s *AuthService) RefreshTokens(oldRefreshToken string) (newAccessToken, newRefreshToken string, err error) {
-	claims, err := s.tokenManager.Parse(oldRefreshToken)
+// newTokenIDs jti для новой пары токенов
+func (s *AuthService) newTokenIDs() (core.IssuedTokens, error) {
+	refreshID, err := newID()
 	if err != nil {
-		return "", "", errors.New("invalid_refresh_token")
+		return core.IssuedTokens{}, err
+	}
+	accessID, err := newID()
+	if err != nil {
+		return core.IssuedTokens{}, err
 	}
+	return core.IssuedTokens{
+		RefreshID:       refreshID,
+		AccessID:        accessID,
+		AccessExpiresAt: time.Now().Add(s.config.AccessTTL),
+	}, nil
+}
 
-	userID := int64(claims["sub"].(float64))
+func (s *AuthService) signPair(u core.User, sessionID string, tokens core.IssuedTokens) (accessToken, refreshToken string, err error) {
+	accessToken, err = s.tokenManager.Sign(core.TokenParams{
+		UserID: u.ID, Email: u.Email, Role: u.Role, Type: core.TokenAccess, SessionID: sessionID, ID: tokens.AccessID,
+	}, s.config.AccessTTL)
+	if err != nil {
+		return "", "", err
+	}
 
-	storedToken, err := s.sessionRepo.GetRefreshToken(userID)
-	if err != nil || storedToken == "" {
-		return "", "", errors.New("refresh_token_not_found")
+	refreshToken, err = s.tokenManager.Sign(core.TokenParams{
+		UserID: u.ID, Email: u.Email, Role: u.Role, Type: core.TokenRefresh, SessionID: sessionID, ID: tokens.RefreshID,
+	}, s.config.RefreshTTL)
+	if err != nil {
+		return "", "", err
 	}
+	return accessToken, refreshToken, nil
+}
 
-	if storedToken != oldRefreshToken {
+func (s *AuthService) RefreshTokens(oldRefreshToken string, client core.ClientInfo) (newAccessToken, newRefreshToken string, err error) {
+	parsed, err := s.tokenManager.Parse(oldRefreshToken)
+	if err != nil {
 		return "", "", errors.New("invalid_refresh_token")
 	}
+	claims, err := core.NewClaims(parsed)
+	// refresh токены OAuth клиентов обновляются только через /oauth/token
+	if err != nil || !claims.IsRefresh() || claims.SessionID == "" || claims.ID == "" || claims.ClientID != "" {
+		return "", "", errors.New("invalid_refresh_token")
+	}
+	userID, sessionID, oldJTI := claims.UserID, claims.SessionID, claims.ID
+
+	tokens, err := s.newTokenIDs()
+	if err != nil {
+		return "", "", err
+	}
+	_, err = s.sessionRepo.RotateRefreshToken(sessionID, oldJTI, tokens, client.IP)
+	switch {
+	case errors.Is(err, repos.ErrTokenReused):
+		// токен уже обменивали: им воспользовался кто-то ещё, всё семейство отозвано
+		log.Printf("[SECURITY] refresh token reuse: user=%d session=%s ip=%s", userID, sessionID, client.IP)
+		return "", "", errors.New("refresh_token_reused")
+	case errors.Is(err, repos.ErrSessionNotFound):
+		return "", "", errors.New("refresh_token_not_found")
+	case err != nil:
+		return "", "", err
+	}
 
 	u, err := s.userRepo.GetById(userID)
 	if err != nil {
 		return "", "", errors.New("user_not_found")
 	}
+	if u.Disabled {
+		return "", "", ErrUserDisabled
+	}
 
-	newAccessToken, err = s.tokenManager.Sign(u.ID, u.Email, u.Role, s.config.AccessTTL)
-	if err != nil {
-		return "", "", err
+	return s.signPair(u, sessionID, tokens)
+}
+
+func (s *AuthService) ListSessions(userID int64) ([]core.Session, error) {
+	return s.sessionRepo.ListSessions(userID)
+}
+
+func (s *AuthService) RevokeSession(userID int64, sessionID string) error {
+	err := s.sessionRepo.DeleteSession(userID, sessionID)
+	if errors.Is(err, repos.ErrSessionNotFound) {
+		return ErrSessionNotFound
 	}
+	return err
+}
 
-	newRefreshToken, err = s.tokenManager.Sign(u.ID, u.Email, u.Role, s.config.RefreshTTL)
-	if err != nil {
-		return "", "", err
+func (s *AuthService) RevokeOtherSessions(userID int64, currentSessionID string) (int, error) {
+	return s.sessionRepo.DeleteUserSessions(userID, currentSessionID)
+}
+
+func (s *AuthService) Logout(userID int64, sessionID, accessJTI string, accessExpiresAt time.Time) error {
+	if sessionID != "" {
+		err := s.sessionRepo.DeleteSession(userID, sessionID)
+		// сессия могла быть уже завершена с другого устройства, токен всё равно отзываем
+		if err != nil && !errors.Is(err, repos.ErrSessionNotFound) {
+			return err
+		}
 	}
+	return s.sessionRepo.RevokeToken(accessJTI, accessExpiresAt)
+}
 
-	if err := s.sessionRepo.SetRefreshToken(userID, newRefreshToken); err != nil {
-		return "", "", err
+func (s *AuthService) LogoutAll(userID int64) (int, error) {
+	// отметка времени отзывает и токены, которые не привязаны к сохранённой сессии
+	if err := s.sessionRepo.RevokeUserTokens(userID, time.Now()); err != nil {
+		return 0, err
 	}
+	return s.sessionRepo.DeleteUserSessions(userID, "")
+}
 
-	return newAccessToken, newRefreshToken, nil
+func (s *AuthService) IsTokenRevoked(jti string, userID int64, issuedAt time.Time) (bool, error) {
+	return s.sessionRepo.TokenRevoked(jti, userID, issuedAt)
 }
@@ -0,0 +1,30 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/icestormerrr/pz10-auth/internal/core"
+	"github.com/icestormerrr/pz10-auth/internal/repos"
+	"github.com/icestormerrr/pz10-auth/internal/utils/jwt"
+)
+
+func TestLoginNormalizesEmail(t *testing.T) {
+	tokens, err := jwt.NewKeyRingTokenManager(jwt.KeyRingConfig{
+		Alg: jwt.AlgEdDSA, Retention: time.Hour, Issuer: "test", Audience: "clients",
+	}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	users := repos.NewUserInMemoryRepo()
+	if _, err := NewUserService(users, newTestSessionRepo(t)).Register("alice@example.com", "password1", ""); err != nil {
+		t.Fatal(err)
+	}
+	svc := NewAuthService(AuthServiceConfig{
+		AccessTTL: time.Minute, RefreshTTL: time.Hour, MaxLoginAttempts: 5,
+	}, users, newTestSessionRepo(t), tokens)
+
+	if _, _, _, err := svc.Login(" Alice@Example.COM ", "password1", core.ClientInfo{}); err != nil {
+		t.Fatalf("login with mixed-case email: %v", err)
+	}
+}
@@ -0,0 +1,588 @@
+package services
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/icestormerrr/pz10-auth/internal/core"
+	"github.com/icestormerrr/pz10-auth/internal/repos"
+)
+
+// коды ошибок RFC 6749, раздел 5.2 (и 4.1.2.1 для /oauth/authorize)
+var (
+	ErrOAuthInvalidRequest        = errors.New("invalid_request")
+	ErrOAuthInvalidClient         = errors.New("invalid_client")
+	ErrOAuthInvalidGrant          = errors.New("invalid_grant")
+	ErrOAuthUnauthorizedClient    = errors.New("unauthorized_client")
+	ErrOAuthUnsupportedGrant      = errors.New("unsupported_grant_type")
+	ErrOAuthUnsupportedResponse   = errors.New("unsupported_response_type")
+	ErrOAuthInvalidScope          = errors.New("invalid_scope")
+	ErrOAuthInvalidRedirectURI    = errors.New("invalid_redirect_uri")
+	ErrOAuthInvalidClientMetadata = errors.New("invalid_client_metadata")
+)
+
+// ErrConsentExpired билет подтверждения доступа истёк или уже использован — нужно войти заново
+var ErrConsentExpired = errors.New("consent_expired")
+
+type OAuthServiceConfig struct {
+	AccessTTL        time.Duration
+	RefreshTTL       time.Duration
+	CodeTTL          time.Duration
+	MaxLoginAttempts int64
+}
+
+type OAuthService struct {
+	config       OAuthServiceConfig
+	oauthRepo    core.OAuthRepo
+	userRepo     core.UserRepo
+	sessionRepo  core.SessionRepo
+	tokenManager core.TokenManager
+}
+
+func NewOAuthService(config OAuthServiceConfig, o core.OAuthRepo, u core.UserRepo, s core.SessionRepo, t core.TokenManager) *OAuthService {
+	return &OAuthService{
+		config:       config,
+		oauthRepo:    o,
+		userRepo:     u,
+		sessionRepo:  s,
+		tokenManager: t,
+	}
+}
+
+func hashSecret(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
+
+// RegisterClient проверяет метаданные клиента; секрет конфиденциального клиента возвращается только здесь
+func (s *OAuthService) RegisterClient(c core.OAuthClient) (core.OAuthClient, string, error) {
+	c.Name = strings.TrimSpace(c.Name)
+	if c.Name == "" || len(c.GrantTypes) == 0 {
+		return core.OAuthClient{}, "", ErrOAuthInvalidClientMetadata
+	}
+	for _, g := range c.GrantTypes {
+		if !core.AllowedGrant(g) {
+			return core.OAuthClient{}, "", ErrOAuthInvalidClientMetadata
+		}
+	}
+	// у публичного клиента нет секрета, выдавать ему токены от своего имени нельзя
+	if c.Public && c.AllowsGrant(core.GrantClientCredentials) {
+		return core.OAuthClient{}, "", ErrOAuthInvalidClientMetadata
+	}
+	if c.AllowsGrant(core.GrantAuthorizationCode) && len(c.RedirectURIs) == 0 {
+		return core.OAuthClient{}, "", ErrOAuthInvalidRedirectURI
+	}
+	for _, uri := range c.RedirectURIs {
+		u, err := url.Parse(uri)
+		if err != nil || !u.IsAbs() || u.Fragment != "" || strings.ContainsAny(uri, " ") {
+			return core.OAuthClient{}, "", ErrOAuthInvalidRedirectURI
+		}
+	}
+	for _, scope := range c.Scopes {
+		if strings.ContainsAny(scope, " \"\\") {
+			return core.OAuthClient{}, "", ErrOAuthInvalidScope
+		}
+	}
+
+	id, err := newID()
+	if err != nil {
+		return core.OAuthClient{}, "", err
+	}
+	c.ID = id
+	c.CreatedAt = time.Now()
+
+	var secret string
+	if !c.Public {
+		if secret, err = newSecret(); err != nil {
+			return core.OAuthClient{}, "", err
+		}
+		c.SecretHash = hashSecret(secret)
+	}
+	if err := s.oauthRepo.CreateClient(c); err != nil {
+		return core.OAuthClient{}, "", err
+	}
+	return c, secret, nil
+}
+
+func newSecret() (string, error) {
+	a, err := newID()
+	if err != nil {
+		return "", err
+	}
+	b, err := newID()
+	if err != nil {
+		return "", err
+	}
+	return a + b, nil
+}
+
+func (s *OAuthService) ListClients() ([]core.OAuthClient, error) {
+	return s.oauthRepo.ListClients()
+}
+
+// authenticateClient публичный клиент предъявляет только client_id, конфиденциальный — ещё и секрет
+func (s *OAuthService) authenticateClient(clientID, secret string) (core.OAuthClient, error) {
+	c, err := s.oauthRepo.GetClient(clientID)
+	if errors.Is(err, repos.ErrClientNotFound) {
+		return core.OAuthClient{}, ErrOAuthInvalidClient
+	}
+	if err != nil {
+		return core.OAuthClient{}, err
+	}
+	if c.Public {
+		if secret != "" {
+			return core.OAuthClient{}, ErrOAuthInvalidClient
+		}
+		return c, nil
+	}
+	if subtle.ConstantTimeCompare([]byte(hashSecret(secret)), []byte(c.SecretHash)) != 1 {
+		return core.OAuthClient{}, ErrOAuthInvalidClient
+	}
+	return c, nil
+}
+
+// resolveScopes запрошенные scopes должны входить в разрешённые клиенту; пустой запрос — все разрешённые
+func resolveScopes(requested string, allowed []string) ([]string, error) {
+	scopes := strings.Fields(requested)
+	if len(scopes) == 0 {
+		return allowed, nil
+	}
+	for _, sc := range scopes {
+		if !contains(allowed, sc) {
+			return nil, ErrOAuthInvalidScope
+		}
+	}
+	return scopes, nil
+}
+
+func contains(list []string, v string) bool {
+	for _, s := range list {
+		if s == v {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidateAuthorize ошибки ErrOAuthInvalidClient и ErrOAuthInvalidRedirectURI показываются пользователю,
+// остальные передаются клиенту через redirect_uri
+func (s *OAuthService) ValidateAuthorize(req core.AuthorizeRequest) (core.OAuthClient, []string, error) {
+	c, err := s.oauthRepo.GetClient(req.ClientID)
+	if errors.Is(err, repos.ErrClientNotFound) {
+		return core.OAuthClient{}, nil, ErrOAuthInvalidClient
+	}
+	if err != nil {
+		return core.OAuthClient{}, nil, err
+	}
+	if !c.AllowsRedirect(req.RedirectURI) {
+		return core.OAuthClient{}, nil, ErrOAuthInvalidRedirectURI
+	}
+
+	if req.ResponseType != "code" {
+		return c, nil, ErrOAuthUnsupportedResponse
+	}
+	if !c.AllowsGrant(core.GrantAuthorizationCode) {
+		return c, nil, ErrOAuthUnauthorizedClient
+	}
+	// PKCE обязателен для публичных клиентов; метод plain не поддерживается
+	if req.CodeChallenge == "" && c.Public {
+		return c, nil, ErrOAuthInvalidRequest
+	}
+	if req.CodeChallenge != "" && (req.CodeChallengeMethod != "S256" || len(req.CodeChallenge) != 43) {
+		return c, nil, ErrOAuthInvalidRequest
+	}
+	scopes, err := resolveScopes(req.Scope, c.Scopes)
+	if err != nil {
+		return c, nil, err
+	}
+	return c, scopes, nil
+}
+
+// NeedsConsent нужно ли спрашивать согласие: пользователь ещё не разрешал клиенту хотя бы один из scopes
+func (s *OAuthService) NeedsConsent(userID int64, clientID string, scopes []string) (bool, error) {
+	granted, err := s.oauthRepo.ConsentedScopes(userID, clientID)
+	if err != nil {
+		return false, err
+	}
+	for _, sc := range scopes {
+		if !contains(granted, sc) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// ConsentTicket выдаётся после входа на странице авторизации, если нужно согласие; действует CodeTTL
+func (s *OAuthService) ConsentTicket(userID int64, clientID string) (string, error) {
+	ticket, err := newSecret()
+	if err != nil {
+		return "", err
+	}
+	if err := s.sessionRepo.SaveConsentTicket(hashSecret(ticket), userID, clientID, s.config.CodeTTL); err != nil {
+		return "", err
+	}
+	return ticket, nil
+}
+
+// RedeemConsentTicket одноразово возвращает пользователя, вошедшего для клиента clientID
+func (s *OAuthService) RedeemConsentTicket(ticket, clientID string) (int64, error) {
+	if ticket == "" {
+		return 0, ErrConsentExpired
+	}
+	userID, ticketClient, err := s.sessionRepo.TakeConsentTicket(hashSecret(ticket))
+	if errors.Is(err, repos.ErrConsentTicketNotFound) {
+		return 0, ErrConsentExpired
+	}
+	if err != nil {
+		return 0, err
+	}
+	if ticketClient != clientID {
+		return 0, ErrConsentExpired
+	}
+	return userID, nil
+}
+
+// AuthenticateUser вход на странице авторизации, с тем же ограничением попыток, что и /api/v1/login
+func (s *OAuthService) AuthenticateUser(email, password string) (core.User, error) {
+	email = strings.ToLower(strings.TrimSpace(email))
+	attempts, err := s.sessionRepo.IncLoginAttempts(email)
+	if err != nil {
+		return core.User{}, ErrInternal
+	}
+	if attempts > s.config.MaxLoginAttempts {
+		return core.User{}, ErrTooManyLoginAttempts
+	}
+	u, err := s.userRepo.CheckPassword(email, password)
+	if err != nil {
+		s.sessionRepo.RecordLoginFailure(email)
+		return core.User{}, ErrUnauthorized
+	}
+	if u.Disabled {
+		return core.User{}, ErrUserDisabled
+	}
+	s.sessionRepo.ResetLoginAttempts(email)
+	return u, nil
+}
+
+func (s *OAuthService) Authorize(userID int64, req core.AuthorizeRequest) (string, error) {
+	_, scopes, err := s.ValidateAuthorize(req)
+	if err != nil {
+		return "", err
+	}
+	if err := s.oauthRepo.GrantConsent(userID, req.ClientID, scopes); err != nil {
+		return "", err
+	}
+
+	code, err := newSecret()
+	if err != nil {
+		return "", err
+	}
+	err = s.oauthRepo.SaveCode(core.AuthorizationCode{
+		CodeHash:      hashSecret(code),
+		ClientID:      req.ClientID,
+		UserID:        userID,
+		RedirectURI:   req.RedirectURI,
+		Scope:         strings.Join(scopes, " "),
+		CodeChallenge: req.CodeChallenge,
+		ExpiresAt:     time.Now().Add(s.config.CodeTTL),
+	})
+	if err != nil {
+		return "", err
+	}
+	return code, nil
+}
+
+func (s *OAuthService) Token(req core.TokenRequest) (core.TokenResponse, error) {
+	c, err := s.authenticateClient(req.ClientID, req.ClientSecret)
+	if err != nil {
+		return core.TokenResponse{}, err
+	}
+	if !core.AllowedGrant(req.GrantType) {
+		return core.TokenResponse{}, ErrOAuthUnsupportedGrant
+	}
+	if !c.AllowsGrant(req.GrantType) {
+		return core.TokenResponse{}, ErrOAuthUnauthorizedClient
+	}
+
+	switch req.GrantType {
+	case core.GrantAuthorizationCode:
+		return s.exchangeCode(c, req)
+	case core.GrantRefreshToken:
+		return s.refresh(c, req)
+	default:
+		return s.clientCredentials(c, req)
+	}
+}
+
+// verifyPKCE BASE64URL(SHA256(code_verifier)) == code_challenge (RFC 7636, раздел 4.6)
+func verifyPKCE(verifier, challenge string) bool {
+	if len(verifier) < 43 || len(verifier) > 128 {
+		return false
+	}
+	sum := sha256.Sum256([]byte(verifier))
+	computed := base64.RawURLEncoding.EncodeToString(sum[:])
+	return subtle.ConstantTimeCompare([]byte(computed), []byte(challenge)) == 1
+}
+
+func (s *OAuthService) exchangeCode(c core.OAuthClient, req core.TokenRequest) (core.TokenResponse, error) {
+	if req.Code == "" {
+		return core.TokenResponse{}, ErrOAuthInvalidRequest
+	}
+	code, err := s.oauthRepo.TakeCode(hashSecret(req.Code))
+	if errors.Is(err, repos.ErrCodeNotFound) {
+		return core.TokenResponse{}, ErrOAuthInvalidGrant
+	}
+	if err != nil {
+		return core.TokenResponse{}, err
+	}
+	if code.ClientID != c.ID || code.RedirectURI != req.RedirectURI || time.Now().After(code.ExpiresAt) {
+		return core.TokenResponse{}, ErrOAuthInvalidGrant
+	}
+	if code.CodeChallenge != "" && !verifyPKCE(req.CodeVerifier, code.CodeChallenge) {
+		return core.TokenResponse{}, ErrOAuthInvalidGrant
+	}
+
+	u, err := s.userRepo.GetById(code.UserID)
+	if err != nil || u.Disabled {
+		return core.TokenResponse{}, ErrOAuthInvalidGrant
+	}
+	scopes := strings.Fields(code.Scope)
+
+	// без offline_access клиент получает только access токен
+	if !contains(scopes, core.ScopeOfflineAccess) || !c.AllowsGrant(core.GrantRefreshToken) {
+		return s.accessOnly(core.TokenParams{
+			UserID: u.ID, Email: u.Email, Role: u.Role, ClientID: c.ID, Scope: code.Scope,
+		})
+	}
+
+	// refresh токены OAuth клиента — такая же сессия, как вход с устройства: её видно и можно завершить в /api/v1/sessions
+	sessionID, err := newID()
+	if err != nil {
+		return core.TokenResponse{}, err
+	}
+	tokens, err := s.newTokenIDs()
+	if err != nil {
+		return core.TokenResponse{}, err
+	}
+	now := time.Now()
+	err = s.sessionRepo.CreateSession(core.Session{
+		ID:         sessionID,
+		UserID:     u.ID,
+		UserAgent:  c.Name + " (OAuth)",
+		IP:         req.Client.IP,
+		CreatedAt:  now,
+		LastUsedAt: now,
+	}, tokens)
+	if err != nil {
+		return core.TokenResponse{}, err
+	}
+	return s.signPair(u, c.ID, code.Scope, sessionID, tokens)
+}
+
+func (s *OAuthService) refresh(c core.OAuthClient, req core.TokenRequest) (core.TokenResponse, error) {
+	parsed, err := s.tokenManager.Parse(req.RefreshToken)
+	if err != nil {
+		return core.TokenResponse{}, ErrOAuthInvalidGrant
+	}
+	claims, err := core.NewClaims(parsed)
+	if err != nil || !claims.IsRefresh() || claims.ClientID != c.ID || claims.SessionID == "" || claims.ID == "" {
+		return core.TokenResponse{}, ErrOAuthInvalidGrant
+	}
+	// можно запросить подмножество исходных scopes, но не расширить их
+	scope := claims.Scope
+	if req.Scope != "" {
+		scopes, err := resolveScopes(req.Scope, strings.Fields(claims.Scope))
+		if err != nil {
+			return core.TokenResponse{}, err
+		}
+		scope = strings.Join(scopes, " ")
+	}
+
+	tokens, err := s.newTokenIDs()
+	if err != nil {
+		return core.TokenResponse{}, err
+	}
+	_, err = s.sessionRepo.RotateRefreshToken(claims.SessionID, claims.ID, tokens, req.Client.IP)
+	if errors.Is(err, repos.ErrTokenReused) || errors.Is(err, repos.ErrSessionNotFound) {
+		return core.TokenResponse{}, ErrOAuthInvalidGrant
+	}
+	if err != nil {
+		return core.TokenResponse{}, err
+	}
+
+	u, err := s.userRepo.GetById(claims.UserID)
+	if err != nil || u.Disabled {
+		return core.TokenResponse{}, ErrOAuthInvalidGrant
+	}
+	return s.signPair(u, c.ID, scope, claims.SessionID, tokens)
+}
+
+func (s *OAuthService) clientCredentials(c core.OAuthClient, req core.TokenRequest) (core.TokenResponse, error) {
+	scopes, err := resolveScopes(req.Scope, c.Scopes)
+	if err != nil {
+		return core.TokenResponse{}, err
+	}
+	return s.accessOnly(core.TokenParams{ClientID: c.ID, Scope: strings.Join(scopes, " ")})
+}
+
+func (s *OAuthService) newTokenIDs() (core.IssuedTokens, error) {
+	refreshID, err := newID()
+	if err != nil {
+		return core.IssuedTokens{}, err
+	}
+	accessID, err := newID()
+	if err != nil {
+		return core.IssuedTokens{}, err
+	}
+	return core.IssuedTokens{
+		RefreshID:       refreshID,
+		AccessID:        accessID,
+		AccessExpiresAt: time.Now().Add(s.config.AccessTTL),
+	}, nil
+}
+
+func (s *OAuthService) accessOnly(p core.TokenParams) (core.TokenResponse, error) {
+	jti, err := newID()
+	if err != nil {
+		return core.TokenResponse{}, err
+	}
+	p.Type, p.ID = core.TokenAccess, jti
+	access, err := s.tokenManager.Sign(p, s.config.AccessTTL)
+	if err != nil {
+		return core.TokenResponse{}, err
+	}
+	return core.TokenResponse{
+		AccessToken: access,
+		TokenType:   "Bearer",
+		ExpiresIn:   int64(s.config.AccessTTL.Seconds()),
+		Scope:       p.Scope,
+	}, nil
+}
+
+func (s *OAuthService) signPair(u core.User, clientID, scope, sessionID string, tokens core.IssuedTokens) (core.TokenResponse, error) {
+	p := core.TokenParams{
+		UserID: u.ID, Email: u.Email, Role: u.Role, SessionID: sessionID, ClientID: clientID, Scope: scope,
+	}
+	p.Type, p.ID = core.TokenAccess, tokens.AccessID
+	access, err := s.tokenManager.Sign(p, s.config.AccessTTL)
+	if err != nil {
+		return core.TokenResponse{}, err
+	}
+	p.Type, p.ID = core.TokenRefresh, tokens.RefreshID
+	refresh, err := s.tokenManager.Sign(p, s.config.RefreshTTL)
+	if err != nil {
+		return core.TokenResponse{}, err
+	}
+	return core.TokenResponse{
+		AccessToken:  access,
+		TokenType:    "Bearer",
+		ExpiresIn:    int64(s.config.AccessTTL.Seconds()),
+		RefreshToken: refresh,
+		Scope:        scope,
+	}, nil
+}
+
+// tokenActive подпись, срок действия и отзыв токена
+func (s *OAuthService) tokenActive(token string) (map[string]any, core.Claims, bool, error) {
+	parsed, err := s.tokenManager.Parse(token)
+	if err != nil {
+		return nil, core.Claims{}, false, nil
+	}
+	claims, err := core.NewClaims(parsed)
+	if err != nil {
+		// у токена клиента (client credentials) sub — строка, заполняем нужные поля сами
+		exp, _ := parsed["exp"].(float64)
+		claims = core.Claims{ExpiresAt: time.Unix(int64(exp), 0), IssuedAt: core.IssuedAt(parsed)}
+		claims.Type, _ = parsed["typ"].(string)
+		claims.ID, _ = parsed["jti"].(string)
+		claims.ClientID, _ = parsed["client_id"].(string)
+		if claims.ClientID == "" || claims.IsRefresh() {
+			return nil, core.Claims{}, false, nil
+		}
+	}
+
+	var active bool
+	if claims.IsRefresh() {
+		active, err = s.sessionRepo.RefreshTokenActive(claims.SessionID, claims.ID)
+	} else {
+		var revoked bool
+		revoked, err = s.sessionRepo.TokenRevoked(claims.ID, claims.UserID, claims.IssuedAt)
+		active = !revoked
+	}
+	if err != nil {
+		return nil, core.Claims{}, false, err
+	}
+	return parsed, claims, active, nil
+}
+
+// Introspect ответ RFC 7662; доступен только конфиденциальным клиентам (ресурсным серверам)
+func (s *OAuthService) Introspect(clientID, clientSecret, token string) (map[string]any, error) {
+	c, err := s.authenticateClient(clientID, clientSecret)
+	if err != nil {
+		return nil, err
+	}
+	if c.Public {
+		return nil, ErrOAuthInvalidClient
+	}
+
+	parsed, claims, active, err := s.tokenActive(token)
+	if err != nil {
+		return nil, err
+	}
+	if !active {
+		return map[string]any{"active": false}, nil
+	}
+
+	out := map[string]any{
+		"active":     true,
+		"token_type": "Bearer",
+		"exp":        parsed["exp"],
+		"iat":        parsed["iat"],
+		"iss":        parsed["iss"],
+		"aud":        parsed["aud"],
+		"jti":        claims.ID,
+	}
+	if claims.UserID != 0 {
+		out["sub"] = claims.UserID
+		out["username"] = claims.Email
+	} else {
+		out["sub"] = parsed["sub"]
+	}
+	if claims.ClientID != "" {
+		out["client_id"] = claims.ClientID
+	}
+	if scope, _ := parsed["scope"].(string); scope != "" {
+		out["scope"] = scope
+	}
+	return out, nil
+}
+
+// Revoke RFC 7009: клиент может отозвать только свой токен, на неизвестный токен ответ тот же, что и на успех
+func (s *OAuthService) Revoke(clientID, clientSecret, token string) error {
+	c, err := s.authenticateClient(clientID, clientSecret)
+	if err != nil {
+		return err
+	}
+	_, claims, active, err := s.tokenActive(token)
+	if err != nil {
+		return err
+	}
+	if !active || claims.ClientID != c.ID {
+		return nil
+	}
+	if claims.IsRefresh() {
+		// вместе с сессией отзывается и её access токен
+		err := s.sessionRepo.DeleteSession(claims.UserID, claims.SessionID)
+		if errors.Is(err, repos.ErrSessionNotFound) {
+			return nil
+		}
+		return err
+	}
+	return s.sessionRepo.RevokeToken(claims.ID, claims.ExpiresAt)
+}
@@ -0,0 +1,222 @@
+package services
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/icestormerrr/pz10-auth/internal/core"
+	"github.com/icestormerrr/pz10-auth/internal/repos"
+	"github.com/icestormerrr/pz10-auth/internal/utils/jwt"
+)
+
+func newTestOAuthService(t *testing.T) (*OAuthService, core.User) {
+	t.Helper()
+	sessions := newTestSessionRepo(t)
+	tokens, err := jwt.NewKeyRingTokenManager(jwt.KeyRingConfig{
+		Alg: jwt.AlgEdDSA, Retention: time.Hour, Issuer: "test", Audience: "clients",
+	}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	users := repos.NewUserInMemoryRepo()
+	u, err := users.Create(core.User{Email: "alice@example.com", Role: core.RoleUser}, "password1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	svc := NewOAuthService(OAuthServiceConfig{
+		AccessTTL: time.Minute, RefreshTTL: time.Hour, CodeTTL: time.Minute, MaxLoginAttempts: 5,
+	}, repos.NewOAuthInMemoryRepo(), users, sessions, tokens)
+	return svc, u
+}
+
+func pkce(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+func TestAuthorizationCodeWithPKCE(t *testing.T) {
+	svc, u := newTestOAuthService(t)
+	client, _, err := svc.RegisterClient(core.OAuthClient{
+		Name: "spa", Public: true,
+		RedirectURIs: []string{"http://localhost:3000/callback"},
+		GrantTypes:   []string{core.GrantAuthorizationCode, core.GrantRefreshToken},
+		Scopes:       []string{"profile", core.ScopeOfflineAccess},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	verifier := strings.Repeat("v", 43)
+	req := core.AuthorizeRequest{
+		ResponseType: "code", ClientID: client.ID, RedirectURI: "http://localhost:3000/callback",
+		Scope: "profile offline_access", CodeChallenge: pkce(verifier), CodeChallengeMethod: "S256",
+	}
+
+	// публичный клиент без PKCE не допускается
+	noPKCE := req
+	noPKCE.CodeChallenge = ""
+	if _, _, err := svc.ValidateAuthorize(noPKCE); !errors.Is(err, ErrOAuthInvalidRequest) {
+		t.Fatalf("want invalid_request got %v", err)
+	}
+	if need, _ := svc.NeedsConsent(u.ID, client.ID, []string{"profile"}); !need {
+		t.Fatal("consent must be required on first authorization")
+	}
+
+	code, err := svc.Authorize(u.ID, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if need, _ := svc.NeedsConsent(u.ID, client.ID, []string{"profile"}); need {
+		t.Fatal("consent must be remembered")
+	}
+
+	exchange := core.TokenRequest{
+		GrantType: core.GrantAuthorizationCode, ClientID: client.ID, Code: code,
+		RedirectURI: req.RedirectURI, CodeVerifier: strings.Repeat("x", 43),
+	}
+	if _, err := svc.Token(exchange); !errors.Is(err, ErrOAuthInvalidGrant) {
+		t.Fatalf("wrong verifier: want invalid_grant got %v", err)
+	}
+	// код одноразовый: после неудачной попытки он уже израсходован
+	code, _ = svc.Authorize(u.ID, req)
+	exchange.Code, exchange.CodeVerifier = code, verifier
+	resp, err := svc.Token(exchange)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.RefreshToken == "" || resp.Scope != "profile offline_access" {
+		t.Fatalf("unexpected response %+v", resp)
+	}
+	if _, err := svc.Token(exchange); !errors.Is(err, ErrOAuthInvalidGrant) {
+		t.Fatalf("code reuse: want invalid_grant got %v", err)
+	}
+
+	refreshed, err := svc.Token(core.TokenRequest{
+		GrantType: core.GrantRefreshToken, ClientID: client.ID, RefreshToken: resp.RefreshToken, Scope: "profile",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if refreshed.Scope != "profile" {
+		t.Fatalf("scope must be narrowed, got %q", refreshed.Scope)
+	}
+
+	// отзыв refresh токена завершает сессию клиента
+	if err := svc.Revoke(client.ID, "", refreshed.RefreshToken); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := svc.Token(core.TokenRequest{
+		GrantType: core.GrantRefreshToken, ClientID: client.ID, RefreshToken: refreshed.RefreshToken,
+	}); !errors.Is(err, ErrOAuthInvalidGrant) {
+		t.Fatalf("revoked refresh token: want invalid_grant got %v", err)
+	}
+}
+
+func TestClientCredentialsAndIntrospection(t *testing.T) {
+	svc, _ := newTestOAuthService(t)
+	client, secret, err := svc.RegisterClient(core.OAuthClient{
+		Name: "billing", GrantTypes: []string{core.GrantClientCredentials}, Scopes: []string{"users:read"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := svc.Token(core.TokenRequest{GrantType: core.GrantClientCredentials, ClientID: client.ID, ClientSecret: "wrong"}); !errors.Is(err, ErrOAuthInvalidClient) {
+		t.Fatalf("want invalid_client got %v", err)
+	}
+	if _, err := svc.Token(core.TokenRequest{GrantType: core.GrantAuthorizationCode, ClientID: client.ID, ClientSecret: secret}); !errors.Is(err, ErrOAuthUnauthorizedClient) {
+		t.Fatalf("want unauthorized_client got %v", err)
+	}
+
+	resp, err := svc.Token(core.TokenRequest{GrantType: core.GrantClientCredentials, ClientID: client.ID, ClientSecret: secret})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.RefreshToken != "" {
+		t.Fatal("client credentials grant must not issue refresh tokens")
+	}
+
+	info, err := svc.Introspect(client.ID, secret, resp.AccessToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info["active"] != true || info["client_id"] != client.ID || info["scope"] != "users:read" {
+		t.Fatalf("unexpected introspection %v", info)
+	}
+
+	if err := svc.Revoke(client.ID, secret, resp.AccessToken); err != nil {
+		t.Fatal(err)
+	}
+	info, _ = svc.Introspect(client.ID, secret, resp.AccessToken)
+	if info["active"] != false {
+		t.Fatalf("revoked token must be inactive, got %v", info)
+	}
+	if info, _ := svc.Introspect(client.ID, secret, "garbage"); info["active"] != false {
+		t.Fatal("garbage token must be inactive")
+	}
+}
+
+func TestConsentRememberedPerScope(t *testing.T) {
+	svc, u := newTestOAuthService(t)
+	client, _, err := svc.RegisterClient(core.OAuthClient{
+		Name: "spa", Public: true,
+		RedirectURIs: []string{"http://localhost:3000/callback"},
+		GrantTypes:   []string{core.GrantAuthorizationCode},
+		Scopes:       []string{core.PermProfileRead, core.PermProfileWrite},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	read := []string{core.PermProfileRead}
+	if needs, err := svc.NeedsConsent(u.ID, client.ID, read); err != nil || !needs {
+		t.Fatalf("first authorization must ask consent: %v %v", needs, err)
+	}
+
+	ticket, err := svc.ConsentTicket(u.ID, client.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := svc.RedeemConsentTicket(ticket, "other-client"); !errors.Is(err, ErrConsentExpired) {
+		t.Fatalf("ticket of another client: want ErrConsentExpired, got %v", err)
+	}
+	ticket, _ = svc.ConsentTicket(u.ID, client.ID)
+	userID, err := svc.RedeemConsentTicket(ticket, client.ID)
+	if err != nil || userID != u.ID {
+		t.Fatalf("redeem: %d %v", userID, err)
+	}
+	if _, err := svc.RedeemConsentTicket(ticket, client.ID); !errors.Is(err, ErrConsentExpired) {
+		t.Fatalf("ticket reuse: want ErrConsentExpired, got %v", err)
+	}
+
+	_, err = svc.Authorize(u.ID, core.AuthorizeRequest{
+		ResponseType: "code", ClientID: client.ID, RedirectURI: "http://localhost:3000/callback",
+		Scope: core.PermProfileRead, CodeChallenge: pkce(strings.Repeat("v", 43)), CodeChallengeMethod: "S256",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if needs, _ := svc.NeedsConsent(u.ID, client.ID, read); needs {
+		t.Fatal("granted scopes must not ask consent again")
+	}
+	if needs, _ := svc.NeedsConsent(u.ID, client.ID, []string{core.PermProfileRead, core.PermProfileWrite}); !needs {
+		t.Fatal("new scope must ask consent")
+	}
+}
+
+func TestAuthenticateUserErrors(t *testing.T) {
+	svc, u := newTestOAuthService(t)
+	if _, err := svc.AuthenticateUser(u.Email, "wrong-password"); !errors.Is(err, ErrUnauthorized) {
+		t.Fatalf("want ErrUnauthorized, got %v", err)
+	}
+	// MaxLoginAttempts = 5: шестая попытка блокируется даже с верным паролем
+	for i := 0; i < 4; i++ {
+		svc.AuthenticateUser(u.Email, "wrong-password")
+	}
+	if _, err := svc.AuthenticateUser(u.Email, "password1"); !errors.Is(err, ErrTooManyLoginAttempts) {
+		t.Fatalf("want ErrTooManyLoginAttempts, got %v", err)
+	}
+}
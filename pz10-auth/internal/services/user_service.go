@@ -2,26 +2,228 @@ package services
 
 import (
 	"errors"
+	"log"
+	"net/mail"
+	"strings"
+	"time"
+	"unicode/utf8"
 
 	"github.com/icestormerrr/pz10-auth/internal/core"
+	"github.com/icestormerrr/pz10-auth/internal/repos"
+)
+
+// текст ошибки уходит клиенту как код ошибки
+var (
+	ErrUserNotFound  = errors.New("user_not_found")
+	ErrEmailTaken    = errors.New("email_taken")
+	ErrInvalidEmail  = errors.New("invalid_email")
+	ErrWeakPassword  = errors.New("weak_password")
+	ErrInvalidName   = errors.New("invalid_name")
+	ErrInvalidRole   = errors.New("invalid_role")
+	ErrWrongPassword = errors.New("wrong_password")
+	ErrInvalidFilter = errors.New("invalid_filter")
+	ErrLastAdmin     = errors.New("last_admin")
+	ErrInternal      = errors.New("internal_error")
+)
+
+const (
+	minPasswordLen = 8
+	// bcrypt учитывает только первые 72 байта
+	maxPasswordLen = 72
+	maxNameLen     = 100
+
+	defaultPageSize = 20
+	maxPageSize     = 100
 )
 
 type UserService struct {
-	userRepo core.UserRepo
+	userRepo    core.UserRepo
+	sessionRepo core.SessionRepo
 }
 
-func NewUserService(u core.UserRepo) *UserService {
-	return &UserService{userRepo: u}
+func NewUserService(u core.UserRepo, s core.SessionRepo) *UserService {
+	return &UserService{userRepo: u, sessionRepo: s}
 }
 
 func (s *UserService) GetById(userID int64) (core.User, error) {
 	u, err := s.userRepo.GetById(userID)
 	if err != nil {
-		return core.User{}, errors.New("user_not_found")
+		return core.User{}, ErrUserNotFound
 	}
 	return u, nil
 }
 
-func (s *UserService) GetStats() (map[string]any, error) {
-	return map[string]any{"users": 2, "version": "1.0"}, nil
+func (s *UserService) GetStats() (core.AdminStats, error) {
+	byRole, disabled, err := s.userRepo.CountByRole()
+	if err != nil {
+		return core.AdminStats{}, s.mapRepoErr(err)
+	}
+	stats := core.AdminStats{UsersByRole: map[string]int{}, DisabledUsers: disabled}
+	// роли без пользователей тоже попадают в сводку
+	for _, role := range core.Roles {
+		stats.UsersByRole[role] = 0
+	}
+	for role, n := range byRole {
+		stats.UsersByRole[role] = n
+		stats.UsersTotal += n
+	}
+
+	if stats.ActiveSessions, err = s.sessionRepo.CountActiveSessions(); err != nil {
+		return core.AdminStats{}, s.mapRepoErr(err)
+	}
+	if stats.LoginFailuresLastHour, err = s.sessionRepo.CountLoginFailures(time.Now().Add(-time.Hour)); err != nil {
+		return core.AdminStats{}, s.mapRepoErr(err)
+	}
+	return stats, nil
+}
+
+func (s *UserService) ListUsers(f core.UserFilter) ([]core.User, int, error) {
+	if f.Role != "" && !core.ValidRole(f.Role) {
+		return nil, 0, ErrInvalidRole
+	}
+	if f.Limit < 0 || f.Limit > maxPageSize || f.Offset < 0 {
+		return nil, 0, ErrInvalidFilter
+	}
+	if f.Limit == 0 {
+		f.Limit = defaultPageSize
+	}
+	f.Query = strings.TrimSpace(f.Query)
+	users, total, err := s.userRepo.List(f)
+	return users, total, s.mapRepoErr(err)
+}
+
+// SetDisabled блокирует пользователя; последнего активного администратора заблокировать нельзя
+func (s *UserService) SetDisabled(userID int64, disabled bool) (core.User, error) {
+	u, err := s.userRepo.SetDisabled(userID, disabled)
+	if err != nil {
+		return core.User{}, s.mapRepoErr(err)
+	}
+	if disabled {
+		if _, err := s.revokeAll(userID); err != nil {
+			return core.User{}, err
+		}
+	}
+	return u, nil
+}
+
+func (s *UserService) ForceLogout(userID int64) (int, error) {
+	if _, err := s.userRepo.GetById(userID); err != nil {
+		return 0, s.mapRepoErr(err)
+	}
+	return s.revokeAll(userID)
+}
+
+// revokeAll завершает сессии пользователя и отзывает уже выданные access токены
+func (s *UserService) revokeAll(userID int64) (int, error) {
+	if err := s.sessionRepo.RevokeUserTokens(userID, time.Now()); err != nil {
+		return 0, s.mapRepoErr(err)
+	}
+	n, err := s.sessionRepo.DeleteUserSessions(userID, "")
+	return n, s.mapRepoErr(err)
+}
+
+// Register создаёт пользователя с ролью user; роль меняет только администратор
+func (s *UserService) Register(email, password, name string) (core.User, error) {
+	email = strings.ToLower(strings.TrimSpace(email))
+	if addr, err := mail.ParseAddress(email); err != nil || addr.Address != email {
+		return core.User{}, ErrInvalidEmail
+	}
+	if err := checkPassword(password); err != nil {
+		return core.User{}, err
+	}
+	name, err := normalizeName(name)
+	if err != nil {
+		return core.User{}, err
+	}
+
+	u, err := s.userRepo.Create(core.User{Email: email, Name: name, Role: core.RoleUser}, password)
+	if errors.Is(err, repos.ErrEmailTaken) {
+		return core.User{}, ErrEmailTaken
+	}
+	if err != nil {
+		log.Printf("[ERROR] create user: %v", err)
+		return core.User{}, ErrInternal
+	}
+	return u, nil
+}
+
+func (s *UserService) UpdateProfile(userID int64, name string) (core.User, error) {
+	name, err := normalizeName(name)
+	if err != nil {
+		return core.User{}, err
+	}
+	u, err := s.userRepo.UpdateProfile(userID, name)
+	return u, s.mapRepoErr(err)
+}
+
+// ChangePassword требует текущий пароль, даже если пользователь уже аутентифицирован.
+// Остальные сессии завершаются, выданные access токены отзываются; текущая сессия (sessionID)
+// сохраняется и получает новый access токен через refresh.
+func (s *UserService) ChangePassword(userID int64, sessionID, oldPassword, newPassword string) error {
+	u, err := s.userRepo.GetById(userID)
+	if err != nil {
+		return s.mapRepoErr(err)
+	}
+	if _, err := s.userRepo.CheckPassword(u.Email, oldPassword); err != nil {
+		return ErrWrongPassword
+	}
+	if err := checkPassword(newPassword); err != nil {
+		return err
+	}
+	if err := s.userRepo.SetPassword(userID, newPassword); err != nil {
+		return s.mapRepoErr(err)
+	}
+	// украденный refresh или access токен не должен пережить смену пароля
+	if err := s.sessionRepo.RevokeUserTokens(userID, time.Now()); err != nil {
+		return s.mapRepoErr(err)
+	}
+	_, err = s.sessionRepo.DeleteUserSessions(userID, sessionID)
+	return s.mapRepoErr(err)
+}
+
+// SetRole назначает роль; последнего активного администратора понизить нельзя,
+// иначе администрирование станет недоступно
+func (s *UserService) SetRole(userID int64, role string) (core.User, error) {
+	if !core.ValidRole(role) {
+		return core.User{}, ErrInvalidRole
+	}
+	u, err := s.userRepo.SetRole(userID, role)
+	if err != nil {
+		return core.User{}, s.mapRepoErr(err)
+	}
+	// токены со старой ролью перестают приниматься, сессии сохраняются:
+	// при обновлении пары токенов в них попадёт новая роль
+	if err := s.sessionRepo.RevokeUserTokens(userID, time.Now()); err != nil {
+		return core.User{}, s.mapRepoErr(err)
+	}
+	return u, nil
+}
+
+func (s *UserService) mapRepoErr(err error) error {
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, repos.ErrNotFound):
+		return ErrUserNotFound
+	case errors.Is(err, repos.ErrLastAdmin):
+		return ErrLastAdmin
+	default:
+		log.Printf("[ERROR] user repo: %v", err)
+		return ErrInternal
+	}
+}
+
+func checkPassword(p string) error {
+	if utf8.RuneCountInString(p) < minPasswordLen || len(p) > maxPasswordLen {
+		return ErrWeakPassword
+	}
+	return nil
+}
+
+func normalizeName(name string) (string, error) {
+	name = strings.TrimSpace(name)
+	if utf8.RuneCountInString(name) > maxNameLen {
+		return "", ErrInvalidName
+	}
+	return name, nil
 }
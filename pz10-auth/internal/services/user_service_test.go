@@ -0,0 +1,169 @@
+package services
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/icestormerrr/pz10-auth/internal/core"
+	"github.com/icestormerrr/pz10-auth/internal/repos"
+)
+
+func newTestSessionRepo(t *testing.T) *repos.SessionRedisRepo {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	return repos.NewSessionRedisRepo(repos.SessionRedisRepoConfig{
+		RefreshTTL: time.Hour, RedisHost: mr.Host(), RedisPort: mr.Port(),
+	})
+}
+
+func TestRegisterAndChangePassword(t *testing.T) {
+	sessions := newTestSessionRepo(t)
+	svc := NewUserService(repos.NewUserInMemoryRepo(), sessions)
+
+	u, err := svc.Register(" Alice@Example.com ", "password1", "Alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u.Email != "alice@example.com" || u.Role != core.RoleUser {
+		t.Fatalf("unexpected user %+v", u)
+	}
+	if _, err := svc.Register("alice@example.com", "password2", ""); !errors.Is(err, ErrEmailTaken) {
+		t.Fatalf("want ErrEmailTaken got %v", err)
+	}
+	if _, err := svc.Register("bob", "password1", ""); !errors.Is(err, ErrInvalidEmail) {
+		t.Fatalf("want ErrInvalidEmail got %v", err)
+	}
+	if _, err := svc.Register("bob@example.com", "short", ""); !errors.Is(err, ErrWeakPassword) {
+		t.Fatalf("want ErrWeakPassword got %v", err)
+	}
+
+	if err := svc.ChangePassword(u.ID, "", "wrong-password", "password2"); !errors.Is(err, ErrWrongPassword) {
+		t.Fatalf("want ErrWrongPassword got %v", err)
+	}
+
+	now := time.Now()
+	for _, id := range []string{"current", "stolen"} {
+		if err := sessions.CreateSession(core.Session{ID: id, UserID: u.ID, CreatedAt: now, LastUsedAt: now}, core.IssuedTokens{RefreshID: "r-" + id}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := svc.ChangePassword(u.ID, "current", "password1", "password2"); err != nil {
+		t.Fatal(err)
+	}
+	if list, _ := sessions.ListSessions(u.ID); len(list) != 1 || list[0].ID != "current" {
+		t.Fatalf("only the current session must survive a password change, got %+v", list)
+	}
+	if revoked, _ := sessions.TokenRevoked("", u.ID, now.Add(-time.Second)); !revoked {
+		t.Fatal("access tokens issued before a password change must be revoked")
+	}
+}
+
+func TestSetRole(t *testing.T) {
+	svc := NewUserService(repos.NewUserInMemoryRepo(), newTestSessionRepo(t))
+	u, err := svc.Register("carol@example.com", "password1", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := svc.SetRole(u.ID, "root"); !errors.Is(err, ErrInvalidRole) {
+		t.Fatalf("want ErrInvalidRole got %v", err)
+	}
+	got, err := svc.SetRole(u.ID, core.RoleAdmin)
+	if err != nil || got.Role != core.RoleAdmin {
+		t.Fatalf("set role: %+v %v", got, err)
+	}
+	if _, err := svc.SetRole(999, core.RoleAdmin); !errors.Is(err, ErrUserNotFound) {
+		t.Fatalf("want ErrUserNotFound got %v", err)
+	}
+
+	// carol — единственный администратор
+	if _, err := svc.SetRole(u.ID, core.RoleUser); !errors.Is(err, ErrLastAdmin) {
+		t.Fatalf("want ErrLastAdmin got %v", err)
+	}
+	other, _ := svc.Register("erin@example.com", "password1", "")
+	if _, err := svc.SetRole(other.ID, core.RoleAdmin); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := svc.SetRole(u.ID, core.RoleUser); err != nil {
+		t.Fatalf("demote one of two admins: %v", err)
+	}
+	if _, err := svc.SetDisabled(other.ID, true); !errors.Is(err, ErrLastAdmin) {
+		t.Fatalf("disable last admin: want ErrLastAdmin got %v", err)
+	}
+}
+
+// два администратора одновременно понижают и блокируют друг друга: хотя бы один должен остаться
+func TestLastAdminConcurrent(t *testing.T) {
+	svc := NewUserService(repos.NewUserInMemoryRepo(), newTestSessionRepo(t))
+	a, _ := svc.Register("a@example.com", "password1", "")
+	b, _ := svc.Register("b@example.com", "password1", "")
+	svc.SetRole(a.ID, core.RoleAdmin)
+	svc.SetRole(b.ID, core.RoleAdmin)
+
+	var wg sync.WaitGroup
+	errs := make([]error, 2)
+	wg.Add(2)
+	go func() { defer wg.Done(); _, errs[0] = svc.SetRole(a.ID, core.RoleUser) }()
+	go func() { defer wg.Done(); _, errs[1] = svc.SetDisabled(b.ID, true) }()
+	wg.Wait()
+
+	failed := 0
+	for _, err := range errs {
+		if errors.Is(err, ErrLastAdmin) {
+			failed++
+		} else if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if failed != 1 {
+		t.Fatalf("exactly one change must be refused, got %v", errs)
+	}
+}
+
+func TestDisableAndStats(t *testing.T) {
+	sessions := newTestSessionRepo(t)
+	svc := NewUserService(repos.NewUserInMemoryRepo(), sessions)
+	admin, _ := svc.Register("admin@example.com", "password1", "")
+	svc.SetRole(admin.ID, core.RoleAdmin)
+	u, _ := svc.Register("dave@example.com", "password1", "Dave")
+
+	now := time.Now()
+	if err := sessions.CreateSession(core.Session{ID: "s1", UserID: u.ID, CreatedAt: now, LastUsedAt: now}, core.IssuedTokens{RefreshID: "r"}); err != nil {
+		t.Fatal(err)
+	}
+	sessions.RecordLoginFailure("dave@example.com")
+
+	stats, err := svc.GetStats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.UsersTotal != 2 || stats.UsersByRole[core.RoleAdmin] != 1 || stats.ActiveSessions != 1 || stats.LoginFailuresLastHour != 1 {
+		t.Fatalf("unexpected stats %+v", stats)
+	}
+
+	got, err := svc.SetDisabled(u.ID, true)
+	if err != nil || !got.Disabled {
+		t.Fatalf("disable: %+v %v", got, err)
+	}
+	if list, _ := sessions.ListSessions(u.ID); len(list) != 0 {
+		t.Fatal("sessions of a disabled user must be revoked")
+	}
+	if revoked, _ := sessions.TokenRevoked("", u.ID, now.Add(-time.Second)); !revoked {
+		t.Fatal("access tokens of a disabled user must be revoked")
+	}
+
+	disabled := true
+	users, total, err := svc.ListUsers(core.UserFilter{Disabled: &disabled})
+	if err != nil || total != 1 || users[0].ID != u.ID {
+		t.Fatalf("list disabled: %+v %d %v", users, total, err)
+	}
+	if _, _, err := svc.ListUsers(core.UserFilter{Limit: 1000}); !errors.Is(err, ErrInvalidFilter) {
+		t.Fatalf("want ErrInvalidFilter got %v", err)
+	}
+	if _, err := svc.ForceLogout(999); !errors.Is(err, ErrUserNotFound) {
+		t.Fatalf("want ErrUserNotFound got %v", err)
+	}
+}
@@ -11,14 +11,24 @@ import (
 
 type Config struct {
 	Port          string
-	PublicRsaKey  string
 	PrivateRsaKey string
-	AccessTTL     time.Duration
-	RefreshTTL    time.Duration
-	RedisHost     string
-	RedisPort     string
-	RedisPassword string
-	RedisDB       int
+	Issuer        string
+	// ключи подписи JWT
+	JWTAlg              string
+	JWTKeysFile         string
+	KeyRotationInterval time.Duration
+	KeyRetention        time.Duration
+	AccessTTL           time.Duration
+	RefreshTTL          time.Duration
+	RedisHost           string
+	RedisPort           string
+	RedisPassword       string
+	RedisDB             int
+	DatabaseURL         string
+	BcryptCost          int
+	// первый администратор создаётся при старте, если указан
+	AdminEmail    string
+	AdminPassword string
 }
 
 func Load() Config {
@@ -32,14 +42,22 @@ func Load() Config {
 		port = "8080"
 	}
 
-	PublicRsaKey := os.Getenv("PUBLIC_RSA_KEY")
-	if PublicRsaKey == "" {
-		log.Fatal("PUBLIC_RSA_KEY is required")
+	// ключ из прошлых версий: добавляется в кольцо, чтобы выданные им токены оставались действительными
+	PrivateRsaKey := os.Getenv("PRIVATE_RSA_KEY")
+
+	issuer := os.Getenv("ISSUER")
+	if issuer == "" {
+		issuer = "http://localhost:" + port
 	}
 
-	PrivateRsaKey := os.Getenv("PRIVATE_RSA_KEY")
-	if PrivateRsaKey == "" {
-		log.Fatal("PRIVATE_RSA_KEY is required")
+	jwtAlg := os.Getenv("JWT_ALG")
+	if jwtAlg == "" {
+		jwtAlg = "RS256"
+	}
+
+	jwtKeysFile := os.Getenv("JWT_KEYS_FILE")
+	if jwtKeysFile == "" {
+		jwtKeysFile = "keys.json"
 	}
 
 	accessTTL := os.Getenv("ACCESS_TTL")
@@ -60,6 +78,24 @@ func Load() Config {
 		log.Fatal("bad REFRESH_TTL")
 	}
 
+	keyRotation := os.Getenv("KEY_ROTATION_INTERVAL")
+	if keyRotation == "" {
+		keyRotation = "720h"
+	}
+	keyRotationDur, err := time.ParseDuration(keyRotation)
+	if err != nil {
+		log.Fatal("bad KEY_ROTATION_INTERVAL")
+	}
+
+	// выведенный ключ должен жить не меньше самого долгоживущего токена, подписанного им
+	keyRetentionDur := refreshTTLDur + accessTTLDur
+	if v := os.Getenv("KEY_RETENTION"); v != "" {
+		keyRetentionDur, err = time.ParseDuration(v)
+		if err != nil {
+			log.Fatal("bad KEY_RETENTION")
+		}
+	}
+
 	redisHost := os.Getenv("REDIS_HOST")
 	if redisHost == "" {
 		redisHost = "localhost"
@@ -82,15 +118,39 @@ func Load() Config {
 		redisDB = 0
 	}
 
+	databaseURL := os.Getenv("DATABASE_URL")
+	if databaseURL == "" {
+		log.Fatal("DATABASE_URL is required")
+	}
+
+	bcryptCost := 12
+	if v := os.Getenv("BCRYPT_COST"); v != "" {
+		cost, err := strconv.Atoi(v)
+		if err != nil {
+			log.Fatal("bad BCRYPT_COST")
+		}
+		bcryptCost = cost
+	}
+
 	return Config{
 		Port:          ":" + port,
-		PublicRsaKey:  PublicRsaKey,
 		PrivateRsaKey: PrivateRsaKey,
+		Issuer:        issuer,
+
+		JWTAlg:              jwtAlg,
+		JWTKeysFile:         jwtKeysFile,
+		KeyRotationInterval: keyRotationDur,
+		KeyRetention:        keyRetentionDur,
+
 		AccessTTL:     accessTTLDur,
 		RefreshTTL:    refreshTTLDur,
 		RedisHost:     redisHost,
 		RedisPort:     redisPort,
 		RedisPassword: redisPassword,
 		RedisDB:       redisDB,
+		DatabaseURL:   databaseURL,
+		BcryptCost:    bcryptCost,
+		AdminEmail:    os.Getenv("ADMIN_EMAIL"),
+		AdminPassword: os.Getenv("ADMIN_PASSWORD"),
 	}
 }
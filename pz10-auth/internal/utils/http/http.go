@@ -7,15 +7,20 @@ import (
 )
 
 func WriteJSON(w http.ResponseWriter, v any) {
+	WriteJSONStatus(w, http.StatusOK, v)
+}
+
+func WriteJSONStatus(w http.ResponseWriter, code int, v any) {
 	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
+	w.WriteHeader(code)
 
 	if err := json.NewEncoder(w).Encode(v); err != nil {
 		log.Printf("[ERROR] Failed to encode JSON response: %v", err)
 		return
 	}
 
-	log.Printf("[RESPONSE] %d %T %+v", http.StatusOK, v, v)
+	// тело не логируется: в ответах бывают токены и секреты клиентов
+	log.Printf("[RESPONSE] %d %T", code, v)
 }
 
 func WriteError(w http.ResponseWriter, code int, msg string, details any) {
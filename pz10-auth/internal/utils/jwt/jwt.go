@@ -1,62 +1,158 @@
 package jwt
 
 import (
-	"crypto/rsa"
-	"crypto/x509"
-	"encoding/pem"
+	"context"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"log"
+	"os"
+	"slices"
+	"sync"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/icestormerrr/pz10-auth/internal/core"
 )
 
-type RS256TokenManager struct {
-	privateKey *rsa.PrivateKey
-	publicKey  *rsa.PublicKey
+// LegacyIssuer iss токенов, выпущенных до появления кольца ключей (ключом PRIVATE_RSA_KEY)
+const LegacyIssuer = "pz10-auth"
+
+type KeyRingConfig struct {
+	// алгоритм новых ключей: RS256 | ES256 | EdDSA
+	Alg string
+	// файл с ключами; пустая строка — ключи живут только в памяти процесса
+	File string
+	// как часто выпускать новый ключ подписи (0 — без плановой ротации)
+	RotateEvery time.Duration
+	// сколько выведенный ключ ещё принимается; должно быть не меньше срока жизни самого долгого токена
+	Retention time.Duration
+	Issuer    string
+	Audience  string
 }
 
-func NewRS256TokenManager(privateKeyPEM, publicKeyPEM string) (*RS256TokenManager, error) {
-	priv, err := parseRSAPrivateKeyFromPEM([]byte(privateKeyPEM))
-	if err != nil {
+// KeyRingTokenManager подписывает токены текущим ключом и проверяет их любым ключом кольца,
+// выбирая его по заголовку kid. Благодаря этому ключ можно сменить, не разлогинивая пользователей.
+type KeyRingTokenManager struct {
+	mu     sync.RWMutex
+	config KeyRingConfig
+	keys   []*Key
+	// kid ключа из PRIVATE_RSA_KEY: им подписаны старые токены без kid
+	legacyKID string
+	// kid отозванных ключей: не возвращаются в кольцо даже из PRIVATE_RSA_KEY
+	revoked []string
+}
+
+// NewKeyRingTokenManager загружает кольцо из файла. legacyPrivatePEM (может быть пустым) —
+// ключ, которым сервис подписывал токены раньше; он добавляется в кольцо и, если других ключей нет,
+// остаётся ключом подписи.
+func NewKeyRingTokenManager(config KeyRingConfig, legacyPrivatePEM string) (*KeyRingTokenManager, error) {
+	m := &KeyRingTokenManager{config: config}
+	if err := m.load(); err != nil {
 		return nil, err
 	}
-	pub, err := parseRSAPublicKeyFromPEM([]byte(publicKeyPEM))
-	if err != nil {
-		return nil, err
+
+	if legacyPrivatePEM != "" {
+		priv, err := parsePrivateKey([]byte(legacyPrivatePEM))
+		if err != nil {
+			return nil, err
+		}
+		alg, err := algFor(priv)
+		if err != nil {
+			return nil, err
+		}
+		k, err := newKey(alg, priv, time.Now())
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case slices.Contains(m.revoked, k.ID):
+			log.Printf("[WARN] PRIVATE_RSA_KEY (kid=%s) is revoked and ignored", k.ID)
+		case m.byID(k.ID) == nil:
+			m.legacyKID = k.ID
+			// при наличии активного ключа из файла старый ключ нужен только для проверки
+			if m.signing() != nil {
+				now := time.Now()
+				k.RetiredAt = &now
+			}
+			m.keys = append(m.keys, k)
+		default:
+			m.legacyKID = k.ID
+		}
 	}
 
-	return &RS256TokenManager{
-		privateKey: priv,
-		publicKey:  pub,
-	}, nil
+	if m.signing() == nil {
+		if _, err := m.rotateLocked(); err != nil {
+			return nil, err
+		}
+	}
+	return m, m.save()
+}
+
+// signing текущий ключ подписи (последний не выведенный из оборота)
+func (m *KeyRingTokenManager) signing() *Key {
+	for i := len(m.keys) - 1; i >= 0; i-- {
+		if m.keys[i].RetiredAt == nil {
+			return m.keys[i]
+		}
+	}
+	return nil
+}
+
+func (m *KeyRingTokenManager) byID(kid string) *Key {
+	for _, k := range m.keys {
+		if k.ID == kid {
+			return k
+		}
+	}
+	return nil
 }
 
-func (r *RS256TokenManager) Sign(userID int64, email, role string, ttl time.Duration) (string, error) {
+func (m *KeyRingTokenManager) Sign(p core.TokenParams, ttl time.Duration) (string, error) {
+	m.mu.RLock()
+	key := m.signing()
+	m.mu.RUnlock()
+
 	now := time.Now()
 	claims := jwt.MapClaims{
-		"sub":   userID,
-		"email": email,
-		"role":  role,
-		"iat":   now.Unix(),
-		"exp":   now.Add(ttl).Unix(),
-		"iss":   "pz10-auth",
-		"aud":   "pz10-clients",
+		"typ": p.Type,
+		"iat": now.Unix(),
+		// iat в секундах слишком груб для отзыва по времени: токен, выданный сразу после отзыва, не должен под него попасть
+		"iat_ms": now.UnixMilli(),
+		"exp":    now.Add(ttl).Unix(),
+		"iss":    m.config.Issuer,
+		"aud":    m.config.Audience,
+	}
+	if p.UserID != 0 {
+		claims["sub"] = p.UserID
+		claims["email"] = p.Email
+		claims["role"] = p.Role
+	} else {
+		// токен выдан самому клиенту (client credentials)
+		claims["sub"] = p.ClientID
+	}
+	if p.ClientID != "" {
+		claims["client_id"] = p.ClientID
+	}
+	if p.Scope != "" {
+		claims["scope"] = p.Scope
+	}
+	if p.SessionID != "" {
+		claims["sid"] = p.SessionID
+	}
+	if p.ID != "" {
+		claims["jti"] = p.ID
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
-	return token.SignedString(r.privateKey)
+	token := jwt.NewWithClaims(key.method(), claims)
+	token.Header["kid"] = key.ID
+	return token.SignedString(key.Private)
 }
 
-func (r *RS256TokenManager) Parse(tokenStr string) (map[string]any, error) {
-	t, err := jwt.Parse(tokenStr, func(t *jwt.Token) (any, error) {
-		if _, ok := t.Method.(*jwt.SigningMethodRSA); !ok {
-			return nil, errors.New("unexpected signing method")
-		}
-		return r.publicKey, nil
-	},
-		jwt.WithValidMethods([]string{"RS256"}),
-		jwt.WithAudience("pz10-clients"),
-		jwt.WithIssuer("pz10-auth"),
+func (m *KeyRingTokenManager) Parse(tokenStr string) (map[string]any, error) {
+	t, err := jwt.Parse(tokenStr, m.keyFunc,
+		jwt.WithValidMethods(SupportedAlgs),
+		jwt.WithAudience(m.config.Audience),
 		jwt.WithExpirationRequired(),
 	)
 	if err != nil || !t.Valid {
@@ -67,34 +163,207 @@ func (r *RS256TokenManager) Parse(tokenStr string) (map[string]any, error) {
 	if !ok {
 		return nil, errors.New("invalid claims type")
 	}
+	// токены, подписанные ключом прежних версий, выпускались с фиксированным iss
+	iss, _ := claims["iss"].(string)
+	if iss != m.config.Issuer && !(iss == LegacyIssuer && m.isLegacy(t)) {
+		return nil, jwt.ErrTokenInvalidIssuer
+	}
 
 	return map[string]any(claims), nil
 }
 
-func parseRSAPrivateKeyFromPEM(pemBytes []byte) (*rsa.PrivateKey, error) {
-	block, _ := pem.Decode(pemBytes)
-	if block == nil || block.Type != "RSA PRIVATE KEY" {
-		return nil, errors.New("invalid RSA private key PEM")
+func (m *KeyRingTokenManager) keyFunc(t *jwt.Token) (any, error) {
+	kid, _ := t.Header["kid"].(string)
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if kid == "" {
+		kid = m.legacyKID
+	}
+	k := m.byID(kid)
+	if k == nil {
+		return nil, errors.New("unknown kid")
+	}
+	// алгоритм задаётся ключом, а не заголовком токена
+	if t.Method.Alg() != k.Alg {
+		return nil, errors.New("unexpected signing method")
+	}
+	return k.Public(), nil
+}
+
+// isLegacy подписан ли токен ключом из PRIVATE_RSA_KEY
+func (m *KeyRingTokenManager) isLegacy(t *jwt.Token) bool {
+	kid, _ := t.Header["kid"].(string)
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.legacyKID != "" && (kid == "" || kid == m.legacyKID)
+}
+
+// JWKS открытые ключи, которыми могут быть подписаны действующие токены
+func (m *KeyRingTokenManager) JWKS() JWKSet {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	set := JWKSet{Keys: make([]JWK, 0, len(m.keys))}
+	for _, k := range m.keys {
+		set.Keys = append(set.Keys, k.JWK())
 	}
-	key, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+	return set
+}
+
+func (m *KeyRingTokenManager) Issuer() string { return m.config.Issuer }
+
+// Rotate выпускает новый ключ подписи; прежний остаётся для проверки на время Retention.
+// revoke (утечка ключа) сразу удаляет прежний ключ из кольца: подписанные им токены перестают приниматься.
+func (m *KeyRingTokenManager) Rotate(revoke bool) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	prev := m.signing()
+	k, err := m.rotateLocked()
+	if err != nil {
+		return "", err
+	}
+	if revoke && prev != nil {
+		m.keys = slices.DeleteFunc(m.keys, func(k *Key) bool { return k == prev })
+		m.revoked = append(m.revoked, prev.ID)
+		if prev.ID == m.legacyKID {
+			m.legacyKID = ""
+		}
+	}
+	return k.ID, m.save()
+}
+
+func (m *KeyRingTokenManager) rotateLocked() (*Key, error) {
+	k, err := GenerateKey(m.config.Alg)
 	if err != nil {
 		return nil, err
 	}
-	return key, nil
+	if cur := m.signing(); cur != nil {
+		cur.RetiredAt = &k.CreatedAt
+	}
+	m.keys = append(m.keys, k)
+	return k, nil
+}
+
+// prune удаляет ключи, выведенные из оборота дольше Retention назад
+func (m *KeyRingTokenManager) prune(now time.Time) bool {
+	kept := m.keys[:0]
+	for _, k := range m.keys {
+		if k.RetiredAt != nil && now.Sub(*k.RetiredAt) > m.config.Retention {
+			continue
+		}
+		kept = append(kept, k)
+	}
+	changed := len(kept) != len(m.keys)
+	m.keys = kept
+	return changed
+}
+
+// Run плановая ротация и удаление устаревших ключей до отмены ctx
+func (m *KeyRingTokenManager) Run(ctx context.Context, checkEvery time.Duration) {
+	t := time.NewTicker(checkEvery)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-t.C:
+			if err := m.tick(now); err != nil {
+				log.Printf("[ERROR] key rotation: %v", err)
+			}
+		}
+	}
+}
+
+func (m *KeyRingTokenManager) tick(now time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	changed := m.prune(now)
+	if m.config.RotateEvery > 0 && now.Sub(m.signing().CreatedAt) >= m.config.RotateEvery {
+		k, err := m.rotateLocked()
+		if err != nil {
+			return err
+		}
+		log.Printf("signing key rotated, kid=%s", k.ID)
+		changed = true
+	}
+	if !changed {
+		return nil
+	}
+	return m.save()
 }
 
-func parseRSAPublicKeyFromPEM(pemBytes []byte) (*rsa.PublicKey, error) {
-	block, _ := pem.Decode(pemBytes)
-	if block == nil || block.Type != "PUBLIC KEY" {
-		return nil, errors.New("invalid RSA public key PEM")
+type storedKey struct {
+	ID         string     `json:"kid"`
+	Alg        string     `json:"alg"`
+	PrivateKey string     `json:"privateKey"`
+	CreatedAt  time.Time  `json:"createdAt"`
+	RetiredAt  *time.Time `json:"retiredAt,omitempty"`
+}
+
+func (m *KeyRingTokenManager) load() error {
+	if m.config.File == "" {
+		return nil
+	}
+	b, err := os.ReadFile(m.config.File)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
 	}
-	pubInterface, err := x509.ParsePKIXPublicKey(block.Bytes)
 	if err != nil {
-		return nil, err
+		return err
 	}
-	pub, ok := pubInterface.(*rsa.PublicKey)
-	if !ok {
-		return nil, errors.New("not RSA public key")
+
+	var stored struct {
+		Keys    []storedKey `json:"keys"`
+		Revoked []string    `json:"revoked"`
+	}
+	if err := json.Unmarshal(b, &stored); err != nil {
+		return fmt.Errorf("parse %s: %w", m.config.File, err)
+	}
+	m.revoked = stored.Revoked
+	for _, s := range stored.Keys {
+		priv, err := parsePrivateKey([]byte(s.PrivateKey))
+		if err != nil {
+			return fmt.Errorf("key %s: %w", s.ID, err)
+		}
+		k, err := newKey(s.Alg, priv, s.CreatedAt)
+		if err != nil {
+			return err
+		}
+		k.RetiredAt = s.RetiredAt
+		m.keys = append(m.keys, k)
+	}
+	m.prune(time.Now())
+	return nil
+}
+
+// save атомарно перезаписывает файл ключей (вызывается под блокировкой или до начала работы)
+func (m *KeyRingTokenManager) save() error {
+	if m.config.File == "" {
+		return nil
+	}
+	stored := struct {
+		Keys    []storedKey `json:"keys"`
+		Revoked []string    `json:"revoked,omitempty"`
+	}{Revoked: m.revoked}
+	for _, k := range m.keys {
+		p, err := encodePrivateKey(k.Private)
+		if err != nil {
+			return err
+		}
+		stored.Keys = append(stored.Keys, storedKey{
+			ID: k.ID, Alg: k.Alg, PrivateKey: p, CreatedAt: k.CreatedAt, RetiredAt: k.RetiredAt,
+		})
+	}
+	b, err := json.MarshalIndent(stored, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := m.config.File + ".tmp"
+	if err := os.WriteFile(tmp, b, 0o600); err != nil {
+		return err
 	}
-	return pub, nil
+	return os.Rename(tmp, m.config.File)
 }
@@ -0,0 +1,147 @@
+package jwt
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"path/filepath"
+	"testing"
+	"time"
+
+	gojwt "github.com/golang-jwt/jwt/v5"
+	"github.com/icestormerrr/pz10-auth/internal/core"
+)
+
+func newManager(t *testing.T, alg, file string) *KeyRingTokenManager {
+	t.Helper()
+	m, err := NewKeyRingTokenManager(KeyRingConfig{
+		Alg: alg, File: file, Retention: time.Hour, Issuer: "test", Audience: "clients",
+	}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+func TestSignParse(t *testing.T) {
+	for _, alg := range SupportedAlgs {
+		t.Run(alg, func(t *testing.T) {
+			m := newManager(t, alg, "")
+			tok, err := m.Sign(core.TokenParams{UserID: 1, Email: "a@b.c", Role: "user", Type: core.TokenAccess}, time.Minute)
+			if err != nil {
+				t.Fatal(err)
+			}
+			claims, err := m.Parse(tok)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if claims["email"] != "a@b.c" {
+				t.Fatalf("claims = %v", claims)
+			}
+			if jwks := m.JWKS(); len(jwks.Keys) != 1 || jwks.Keys[0].Alg != alg {
+				t.Fatalf("jwks = %+v", jwks)
+			}
+		})
+	}
+}
+
+func TestRotationKeepsOldTokens(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "keys.json")
+	m := newManager(t, AlgES256, file)
+	old, _ := m.Sign(core.TokenParams{UserID: 1, Type: core.TokenAccess}, time.Minute)
+
+	if _, err := m.Rotate(false); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Parse(old); err != nil {
+		t.Fatalf("token signed before rotation rejected: %v", err)
+	}
+
+	// после перезапуска кольцо восстанавливается из файла
+	reloaded := newManager(t, AlgES256, file)
+	if len(reloaded.JWKS().Keys) != 2 {
+		t.Fatalf("want 2 keys after reload, got %d", len(reloaded.JWKS().Keys))
+	}
+	if _, err := reloaded.Parse(old); err != nil {
+		t.Fatal(err)
+	}
+
+	// по истечении Retention выведенный ключ удаляется
+	if err := reloaded.tick(time.Now().Add(2 * time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := reloaded.Parse(old); err == nil {
+		t.Fatal("token signed by pruned key accepted")
+	}
+}
+
+func TestUnknownKid(t *testing.T) {
+	m := newManager(t, AlgEdDSA, "")
+	other := newManager(t, AlgEdDSA, "")
+	tok, _ := other.Sign(core.TokenParams{UserID: 1}, time.Minute)
+	if _, err := m.Parse(tok); err == nil {
+		t.Fatal("token signed by foreign key accepted")
+	}
+}
+
+func TestLegacyKey(t *testing.T) {
+	priv, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	legacyPEM := string(pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(priv)}))
+
+	// токен старого формата: без kid в заголовке и с прежним iss
+	old, err := gojwt.NewWithClaims(gojwt.SigningMethodRS256, gojwt.MapClaims{
+		"sub": 1, "iss": LegacyIssuer, "aud": "clients", "exp": time.Now().Add(time.Minute).Unix(),
+	}).SignedString(priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m, err := NewKeyRingTokenManager(KeyRingConfig{Alg: AlgRS256, Issuer: "test", Audience: "clients"}, legacyPEM)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Parse(old); err != nil {
+		t.Fatalf("legacy token rejected: %v", err)
+	}
+
+	// прежний iss допустим только для ключа прежних версий
+	if _, err := m.Rotate(false); err != nil {
+		t.Fatal(err)
+	}
+	key := m.signing()
+	tok := gojwt.NewWithClaims(key.method(), gojwt.MapClaims{
+		"sub": 1, "iss": LegacyIssuer, "aud": "clients", "exp": time.Now().Add(time.Minute).Unix(),
+	})
+	tok.Header["kid"] = key.ID
+	signed, err := tok.SignedString(key.Private)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Parse(signed); err == nil {
+		t.Fatal("legacy issuer accepted for a current key")
+	}
+}
+
+func TestRotateRevoke(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "keys.json")
+	m := newManager(t, AlgEdDSA, file)
+	leaked, _ := m.Sign(core.TokenParams{UserID: 1, Type: core.TokenAccess}, time.Minute)
+
+	if _, err := m.Rotate(true); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Parse(leaked); err == nil {
+		t.Fatal("token signed by revoked key accepted")
+	}
+	if n := len(m.JWKS().Keys); n != 1 {
+		t.Fatalf("revoked key must leave JWKS, got %d keys", n)
+	}
+	fresh, _ := m.Sign(core.TokenParams{UserID: 1, Type: core.TokenAccess}, time.Minute)
+	if _, err := newManager(t, AlgEdDSA, file).Parse(fresh); err != nil {
+		t.Fatal(err)
+	}
+}
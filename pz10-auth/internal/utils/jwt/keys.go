@@ -0,0 +1,203 @@
+package jwt
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	AlgRS256 = "RS256"
+	AlgES256 = "ES256"
+	AlgEdDSA = "EdDSA"
+)
+
+// SupportedAlgs алгоритмы, которыми могут быть подписаны принимаемые токены
+var SupportedAlgs = []string{AlgRS256, AlgES256, AlgEdDSA}
+
+// Key ключ подписи. Пока ключ не выведен из оборота (RetiredAt == nil), им подписываются
+// новые токены; выведенный ключ ещё какое-то время принимается для проверки.
+type Key struct {
+	ID        string
+	Alg       string
+	Private   crypto.Signer
+	CreatedAt time.Time
+	RetiredAt *time.Time
+}
+
+func (k *Key) Public() crypto.PublicKey { return k.Private.Public() }
+
+func (k *Key) method() jwt.SigningMethod {
+	switch k.Alg {
+	case AlgES256:
+		return jwt.SigningMethodES256
+	case AlgEdDSA:
+		return jwt.SigningMethodEdDSA
+	default:
+		return jwt.SigningMethodRS256
+	}
+}
+
+// GenerateKey создаёт новый ключ для алгоритма alg
+func GenerateKey(alg string) (*Key, error) {
+	var priv crypto.Signer
+	var err error
+	switch alg {
+	case AlgRS256:
+		priv, err = rsa.GenerateKey(rand.Reader, 2048)
+	case AlgES256:
+		priv, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	case AlgEdDSA:
+		_, priv, err = ed25519.GenerateKey(rand.Reader)
+	default:
+		return nil, fmt.Errorf("unsupported alg %q", alg)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return newKey(alg, priv, time.Now())
+}
+
+func newKey(alg string, priv crypto.Signer, createdAt time.Time) (*Key, error) {
+	k := &Key{Alg: alg, Private: priv, CreatedAt: createdAt}
+	kid, err := thumbprint(k.JWK())
+	if err != nil {
+		return nil, err
+	}
+	k.ID = kid
+	return k, nil
+}
+
+// algFor алгоритм, соответствующий типу закрытого ключа
+func algFor(priv crypto.Signer) (string, error) {
+	switch p := priv.(type) {
+	case *rsa.PrivateKey:
+		return AlgRS256, nil
+	case *ecdsa.PrivateKey:
+		if p.Curve != elliptic.P256() {
+			return "", errors.New("only P-256 EC keys are supported")
+		}
+		return AlgES256, nil
+	case ed25519.PrivateKey:
+		return AlgEdDSA, nil
+	default:
+		return "", fmt.Errorf("unsupported key type %T", priv)
+	}
+}
+
+// JWK открытая часть ключа (RFC 7517)
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid,omitempty"`
+	Use string `json:"use,omitempty"`
+	Alg string `json:"alg,omitempty"`
+	// RSA
+	N string `json:"n,omitempty"`
+	E string `json:"e,omitempty"`
+	// EC / OKP
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+}
+
+type JWKSet struct {
+	Keys []JWK `json:"keys"`
+}
+
+func b64(b []byte) string { return base64.RawURLEncoding.EncodeToString(b) }
+
+func (k *Key) JWK() JWK {
+	j := JWK{Kid: k.ID, Use: "sig", Alg: k.Alg}
+	switch pub := k.Public().(type) {
+	case *rsa.PublicKey:
+		j.Kty = "RSA"
+		j.N = b64(pub.N.Bytes())
+		j.E = b64(big.NewInt(int64(pub.E)).Bytes())
+	case *ecdsa.PublicKey:
+		j.Kty = "EC"
+		j.Crv = "P-256"
+		j.X = b64(pub.X.FillBytes(make([]byte, 32)))
+		j.Y = b64(pub.Y.FillBytes(make([]byte, 32)))
+	case ed25519.PublicKey:
+		j.Kty = "OKP"
+		j.Crv = "Ed25519"
+		j.X = b64(pub)
+	}
+	return j
+}
+
+// thumbprint JWK thumbprint (RFC 7638) — детерминированный kid
+func thumbprint(j JWK) (string, error) {
+	var members any
+	switch j.Kty {
+	case "RSA":
+		members = struct {
+			E   string `json:"e"`
+			Kty string `json:"kty"`
+			N   string `json:"n"`
+		}{j.E, j.Kty, j.N}
+	case "EC":
+		members = struct {
+			Crv string `json:"crv"`
+			Kty string `json:"kty"`
+			X   string `json:"x"`
+			Y   string `json:"y"`
+		}{j.Crv, j.Kty, j.X, j.Y}
+	case "OKP":
+		members = struct {
+			Crv string `json:"crv"`
+			Kty string `json:"kty"`
+			X   string `json:"x"`
+		}{j.Crv, j.Kty, j.X}
+	default:
+		return "", fmt.Errorf("unsupported kty %q", j.Kty)
+	}
+	b, err := json.Marshal(members)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(b)
+	return b64(sum[:]), nil
+}
+
+func encodePrivateKey(priv crypto.Signer) (string, error) {
+	der, err := x509.MarshalPKCS8PrivateKey(priv)
+	if err != nil {
+		return "", err
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})), nil
+}
+
+// parsePrivateKey принимает PKCS#8 и, для совместимости со старыми RSA ключами, PKCS#1
+func parsePrivateKey(pemBytes []byte) (crypto.Signer, error) {
+	block, _ := pem.Decode(pemBytes)
+	if block == nil {
+		return nil, errors.New("invalid private key PEM")
+	}
+	if block.Type == "RSA PRIVATE KEY" {
+		return x509.ParsePKCS1PrivateKey(block.Bytes)
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("unsupported key type %T", key)
+	}
+	return signer, nil
+}
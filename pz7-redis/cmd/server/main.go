@@ -7,6 +7,8 @@ import (
 	"time"
 
 	"github.com/icestormerrr/pz7-redis/internal/cache"
+	"github.com/icestormerrr/pz7-redis/internal/messaging"
+	"github.com/icestormerrr/pz7-redis/ratelimit"
 )
 
 func main() {
@@ -49,6 +51,13 @@ func main() {
 		fmt.Fprintf(w, "TTL for %s: %v", key, ttl)
 	})
 
+	messaging.NewHandler(messaging.NewBroker(c.Client())).Register(mux)
+
+	limiter, err := ratelimit.NewTokenBucket(c.Client(), 5, 20) // 5 запросов/сек, всплеск до 20
+	if err != nil {
+		log.Fatal(err)
+	}
+
 	log.Println("Listening on :8080")
-	log.Fatal(http.ListenAndServe(":8080", mux))
+	log.Fatal(http.ListenAndServe(":8080", ratelimit.Middleware(limiter, ratelimit.KeyByIP)(mux)))
 }
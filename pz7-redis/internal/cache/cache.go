@@ -31,3 +31,7 @@ func (c *Cache) Get(key string) (string, error) {
 func (c *Cache) TTL(key string) (time.Duration, error) {
 	return c.rdb.TTL(context.Background(), key).Result()
 }
+
+func (c *Cache) Client() *redis.Client {
+	return c.rdb
+}
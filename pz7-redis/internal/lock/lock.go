@@ -0,0 +1,212 @@
+package lock
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+var (
+	ErrNotAcquired = errors.New("lock: not acquired")
+	ErrNotHeld     = errors.New("lock: not held")
+)
+
+// SET NX PX и выдача fencing-токена выполняются атомарно одним скриптом.
+var acquireScript = redis.NewScript(`
+if redis.call("SET", KEYS[1], ARGV[1], "NX", "PX", ARGV[2]) then
+	return redis.call("INCR", KEYS[2])
+end
+return 0
+`)
+
+var releaseScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0
+`)
+
+var extendScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("PEXPIRE", KEYS[1], ARGV[2])
+end
+return 0
+`)
+
+type Options struct {
+	TTL        time.Duration
+	RetryDelay time.Duration
+	MaxJitter  time.Duration
+	AutoRenew  bool
+}
+
+func (o Options) withDefaults() Options {
+	if o.TTL <= 0 {
+		o.TTL = 10 * time.Second
+	}
+	if o.RetryDelay <= 0 {
+		o.RetryDelay = 100 * time.Millisecond
+	}
+	if o.MaxJitter < 0 {
+		o.MaxJitter = 0
+	}
+	return o
+}
+
+type Locker struct {
+	rdb  redis.Scripter
+	opts Options
+}
+
+func New(rdb redis.Scripter, opts Options) *Locker {
+	return &Locker{rdb: rdb, opts: opts.withDefaults()}
+}
+
+type Lock struct {
+	locker *Locker
+	key    string
+	value  string
+	token  int64
+
+	mu       sync.Mutex
+	released bool
+	stop     chan struct{}
+	done     chan struct{}
+}
+
+// TryLock делает одну попытку захвата и возвращает ErrNotAcquired, если блокировка занята.
+func (l *Locker) TryLock(ctx context.Context, name string) (*Lock, error) {
+	value, err := randomValue()
+	if err != nil {
+		return nil, err
+	}
+	key := "lock:" + name
+	token, err := acquireScript.Run(ctx, l.rdb,
+		[]string{key, key + ":fence"},
+		value, l.opts.TTL.Milliseconds(),
+	).Int64()
+	if err != nil {
+		return nil, err
+	}
+	if token == 0 {
+		return nil, ErrNotAcquired
+	}
+
+	lk := &Lock{locker: l, key: key, value: value, token: token}
+	if l.opts.AutoRenew {
+		lk.stop = make(chan struct{})
+		lk.done = make(chan struct{})
+		go lk.renew()
+	}
+	return lk, nil
+}
+
+// Lock повторяет попытки захвата с джиттером, пока не получит блокировку или не истечёт ctx.
+func (l *Locker) Lock(ctx context.Context, name string) (*Lock, error) {
+	for {
+		lk, err := l.TryLock(ctx, name)
+		if !errors.Is(err, ErrNotAcquired) {
+			return lk, err
+		}
+
+		timer := time.NewTimer(l.opts.RetryDelay + jitter(l.opts.MaxJitter))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+func (lk *Lock) Key() string {
+	return lk.key
+}
+
+// Token возвращает fencing-токен: он строго растёт с каждым успешным захватом
+// и должен передаваться в защищаемый ресурс вместе с операцией записи.
+func (lk *Lock) Token() int64 {
+	return lk.token
+}
+
+func (lk *Lock) Extend(ctx context.Context, ttl time.Duration) error {
+	res, err := extendScript.Run(ctx, lk.locker.rdb, []string{lk.key}, lk.value, ttl.Milliseconds()).Int64()
+	if err != nil {
+		return err
+	}
+	if res == 0 {
+		return ErrNotHeld
+	}
+	return nil
+}
+
+func (lk *Lock) Unlock(ctx context.Context) error {
+	lk.mu.Lock()
+	if lk.released {
+		lk.mu.Unlock()
+		return ErrNotHeld
+	}
+	lk.released = true
+	lk.mu.Unlock()
+
+	if lk.stop != nil {
+		close(lk.stop)
+		<-lk.done
+	}
+
+	res, err := releaseScript.Run(ctx, lk.locker.rdb, []string{lk.key}, lk.value).Int64()
+	if err != nil {
+		return err
+	}
+	if res == 0 {
+		return ErrNotHeld
+	}
+	return nil
+}
+
+func (lk *Lock) renew() {
+	defer close(lk.done)
+
+	ttl := lk.locker.opts.TTL
+	ticker := time.NewTicker(ttl / 3)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-lk.stop:
+			return
+		case <-ticker.C:
+			ctx, cancel := context.WithTimeout(context.Background(), ttl/3)
+			err := lk.Extend(ctx, ttl)
+			cancel()
+			if errors.Is(err, ErrNotHeld) {
+				return
+			}
+		}
+	}
+}
+
+func randomValue() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+func jitter(max time.Duration) time.Duration {
+	if max <= 0 {
+		return 0
+	}
+	n, err := rand.Int(rand.Reader, big.NewInt(int64(max)))
+	if err != nil {
+		return 0
+	}
+	return time.Duration(n.Int64())
+}
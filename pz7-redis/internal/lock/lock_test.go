@@ -0,0 +1,130 @@
+package lock_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+
+	"github.com/icestormerrr/pz7-redis/internal/lock"
+)
+
+func newLocker(t *testing.T, opts lock.Options) (*lock.Locker, *miniredis.Miniredis) {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { rdb.Close() })
+	return lock.New(rdb, opts), mr
+}
+
+func TestTryLock(t *testing.T) {
+	ctx := context.Background()
+	l, _ := newLocker(t, lock.Options{TTL: time.Second})
+
+	first, err := l.TryLock(ctx, "job")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := l.TryLock(ctx, "job"); !errors.Is(err, lock.ErrNotAcquired) {
+		t.Fatalf("want ErrNotAcquired got %v", err)
+	}
+	if err := first.Unlock(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	second, err := l.TryLock(ctx, "job")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if second.Token() <= first.Token() {
+		t.Fatalf("fencing token must grow: %d then %d", first.Token(), second.Token())
+	}
+}
+
+func TestUnlockAfterExpiry(t *testing.T) {
+	ctx := context.Background()
+	l, mr := newLocker(t, lock.Options{TTL: time.Second})
+
+	stale, err := l.TryLock(ctx, "job")
+	if err != nil {
+		t.Fatal(err)
+	}
+	mr.FastForward(2 * time.Second)
+
+	fresh, err := l.TryLock(ctx, "job")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := stale.Extend(ctx, time.Second); !errors.Is(err, lock.ErrNotHeld) {
+		t.Fatalf("want ErrNotHeld on extend got %v", err)
+	}
+	if err := stale.Unlock(ctx); !errors.Is(err, lock.ErrNotHeld) {
+		t.Fatalf("want ErrNotHeld on unlock got %v", err)
+	}
+	if !mr.Exists(fresh.Key()) {
+		t.Fatal("stale unlock removed someone else's lock")
+	}
+}
+
+func TestLockWaitsForRelease(t *testing.T) {
+	ctx := context.Background()
+	l, _ := newLocker(t, lock.Options{TTL: time.Second, RetryDelay: 10 * time.Millisecond, MaxJitter: 5 * time.Millisecond})
+
+	held, err := l.TryLock(ctx, "job")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		held.Unlock(ctx)
+	}()
+
+	waitCtx, cancel := context.WithTimeout(ctx, time.Second)
+	defer cancel()
+	got, err := l.Lock(waitCtx, "job")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got.Unlock(ctx)
+}
+
+func TestLockContextTimeout(t *testing.T) {
+	ctx := context.Background()
+	l, _ := newLocker(t, lock.Options{TTL: time.Second, RetryDelay: 10 * time.Millisecond})
+
+	if _, err := l.TryLock(ctx, "job"); err != nil {
+		t.Fatal(err)
+	}
+
+	waitCtx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	if _, err := l.Lock(waitCtx, "job"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("want DeadlineExceeded got %v", err)
+	}
+}
+
+func TestAutoRenew(t *testing.T) {
+	ctx := context.Background()
+	l, mr := newLocker(t, lock.Options{TTL: 150 * time.Millisecond, AutoRenew: true})
+
+	lk, err := l.TryLock(ctx, "job")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 5; i++ {
+		time.Sleep(100 * time.Millisecond)
+		mr.FastForward(100 * time.Millisecond)
+		if !mr.Exists(lk.Key()) {
+			t.Fatalf("lock expired while held (step %d)", i)
+		}
+	}
+	if err := lk.Unlock(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if mr.Exists(lk.Key()) {
+		t.Fatal("lock still present after unlock")
+	}
+}
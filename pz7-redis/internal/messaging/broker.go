@@ -0,0 +1,145 @@
+package messaging
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+var ErrGroupExists = errors.New("consumer group already exists")
+
+// Message запись из Redis Stream.
+type Message struct {
+	ID     string         `json:"id"`
+	Values map[string]any `json:"values"`
+}
+
+// Pending запись, выданная потребителю, но ещё не подтверждённая через XACK.
+type Pending struct {
+	ID         string `json:"id"`
+	Consumer   string `json:"consumer"`
+	IdleMs     int64  `json:"idleMs"`
+	Deliveries int64  `json:"deliveries"`
+}
+
+// Broker обёртка над Pub/Sub и Streams командами Redis.
+type Broker struct {
+	rdb *redis.Client
+}
+
+func NewBroker(rdb *redis.Client) *Broker {
+	return &Broker{rdb: rdb}
+}
+
+// Publish возвращает число подписчиков, получивших сообщение.
+func (b *Broker) Publish(ctx context.Context, channel, payload string) (int64, error) {
+	return b.rdb.Publish(ctx, channel, payload).Result()
+}
+
+// Subscribe подписывается на канал; подписку нужно закрыть после использования.
+func (b *Broker) Subscribe(ctx context.Context, channel string) (*redis.PubSub, error) {
+	sub := b.rdb.Subscribe(ctx, channel)
+	// дожидаемся подтверждения подписки, чтобы не потерять первые сообщения
+	if _, err := sub.Receive(ctx); err != nil {
+		sub.Close()
+		return nil, err
+	}
+	return sub, nil
+}
+
+func (b *Broker) Add(ctx context.Context, stream string, values map[string]any) (string, error) {
+	return b.rdb.XAdd(ctx, &redis.XAddArgs{Stream: stream, Values: values}).Result()
+}
+
+// CreateGroup создаёт группу потребителей (и сам стрим, если его ещё нет).
+func (b *Broker) CreateGroup(ctx context.Context, stream, group, start string) error {
+	if start == "" {
+		start = "$"
+	}
+	err := b.rdb.XGroupCreateMkStream(ctx, stream, group, start).Err()
+	if err != nil && strings.HasPrefix(err.Error(), "BUSYGROUP") {
+		return ErrGroupExists
+	}
+	return err
+}
+
+// ReadGroup читает новые сообщения для потребителя; block = 0 означает не ждать.
+func (b *Broker) ReadGroup(ctx context.Context, stream, group, consumer string, count int64, block time.Duration) ([]Message, error) {
+	if block <= 0 {
+		block = -1
+	}
+	res, err := b.rdb.XReadGroup(ctx, &redis.XReadGroupArgs{
+		Group:    group,
+		Consumer: consumer,
+		Streams:  []string{stream, ">"},
+		Count:    count,
+		Block:    block,
+	}).Result()
+	if errors.Is(err, redis.Nil) {
+		return []Message{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	out := []Message{}
+	for _, s := range res {
+		out = append(out, toMessages(s.Messages)...)
+	}
+	return out, nil
+}
+
+func (b *Broker) Ack(ctx context.Context, stream, group string, ids ...string) (int64, error) {
+	return b.rdb.XAck(ctx, stream, group, ids...).Result()
+}
+
+func (b *Broker) Pending(ctx context.Context, stream, group string, count int64) ([]Pending, error) {
+	res, err := b.rdb.XPendingExt(ctx, &redis.XPendingExtArgs{
+		Stream: stream,
+		Group:  group,
+		Start:  "-",
+		End:    "+",
+		Count:  count,
+	}).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]Pending, 0, len(res))
+	for _, p := range res {
+		out = append(out, Pending{
+			ID:         p.ID,
+			Consumer:   p.Consumer,
+			IdleMs:     p.Idle.Milliseconds(),
+			Deliveries: p.RetryCount,
+		})
+	}
+	return out, nil
+}
+
+// Claim забирает зависшие сообщения (простаивающие дольше minIdle) на указанного потребителя.
+func (b *Broker) Claim(ctx context.Context, stream, group, consumer string, minIdle time.Duration, count int64) ([]Message, error) {
+	msgs, _, err := b.rdb.XAutoClaim(ctx, &redis.XAutoClaimArgs{
+		Stream:   stream,
+		Group:    group,
+		Consumer: consumer,
+		MinIdle:  minIdle,
+		Start:    "0-0",
+		Count:    count,
+	}).Result()
+	if err != nil {
+		return nil, err
+	}
+	return toMessages(msgs), nil
+}
+
+func toMessages(in []redis.XMessage) []Message {
+	out := make([]Message, 0, len(in))
+	for _, m := range in {
+		out = append(out, Message{ID: m.ID, Values: m.Values})
+	}
+	return out
+}
@@ -0,0 +1,224 @@
+package messaging
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const maxBody = 1 << 20
+
+type Handler struct {
+	broker *Broker
+}
+
+func NewHandler(b *Broker) *Handler {
+	return &Handler{broker: b}
+}
+
+func (h *Handler) Register(mux *http.ServeMux) {
+	mux.HandleFunc("POST /publish/{channel}", h.publish)
+	mux.HandleFunc("GET /subscribe/{channel}", h.subscribe)
+
+	mux.HandleFunc("POST /streams/{name}", h.add)
+	mux.HandleFunc("POST /streams/{name}/groups/{group}", h.createGroup)
+	mux.HandleFunc("GET /streams/{name}/groups/{group}/messages", h.readGroup)
+	mux.HandleFunc("POST /streams/{name}/groups/{group}/ack", h.ack)
+	mux.HandleFunc("GET /streams/{name}/groups/{group}/pending", h.pending)
+	mux.HandleFunc("POST /streams/{name}/groups/{group}/claim", h.claim)
+}
+
+func (h *Handler) publish(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxBody))
+	if err != nil {
+		http.Error(w, "cannot read body", http.StatusBadRequest)
+		return
+	}
+	n, err := h.broker.Publish(r.Context(), r.PathValue("channel"), string(body))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]int64{"receivers": n})
+}
+
+// subscribe отдаёт сообщения канала как Server-Sent Events, пока клиент не отключится.
+func (h *Handler) subscribe(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	sub, err := h.broker.Subscribe(r.Context(), r.PathValue("channel"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer sub.Close()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	ch := sub.Channel()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case msg, ok := <-ch:
+			if !ok {
+				return
+			}
+			fmt.Fprint(w, "event: message\n")
+			for _, line := range strings.Split(msg.Payload, "\n") {
+				fmt.Fprintf(w, "data: %s\n", line)
+			}
+			fmt.Fprint(w, "\n")
+			flusher.Flush()
+		}
+	}
+}
+
+func (h *Handler) add(w http.ResponseWriter, r *http.Request) {
+	var values map[string]any
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBody)).Decode(&values); err != nil || len(values) == 0 {
+		http.Error(w, "body must be a non-empty JSON object", http.StatusBadRequest)
+		return
+	}
+	for k, v := range values {
+		if s, ok := v.(string); ok {
+			values[k] = s
+			continue
+		}
+		// вложенные значения храним как JSON-строку — Streams поддерживают только плоские поля
+		b, _ := json.Marshal(v)
+		values[k] = string(b)
+	}
+
+	id, err := h.broker.Add(r.Context(), r.PathValue("name"), values)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusCreated, map[string]string{"id": id})
+}
+
+func (h *Handler) createGroup(w http.ResponseWriter, r *http.Request) {
+	err := h.broker.CreateGroup(r.Context(), r.PathValue("name"), r.PathValue("group"), r.URL.Query().Get("start"))
+	if errors.Is(err, ErrGroupExists) {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusCreated)
+}
+
+func (h *Handler) readGroup(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	consumer := q.Get("consumer")
+	if consumer == "" {
+		http.Error(w, "consumer required", http.StatusBadRequest)
+		return
+	}
+	count, err := intParam(q.Get("count"), 10)
+	if err != nil {
+		http.Error(w, "invalid count", http.StatusBadRequest)
+		return
+	}
+	blockMs, err := intParam(q.Get("block"), 0)
+	if err != nil {
+		http.Error(w, "invalid block", http.StatusBadRequest)
+		return
+	}
+
+	msgs, err := h.broker.ReadGroup(r.Context(), r.PathValue("name"), r.PathValue("group"), consumer, count, time.Duration(blockMs)*time.Millisecond)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, msgs)
+}
+
+func (h *Handler) ack(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		IDs []string `json:"ids"`
+	}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBody)).Decode(&req); err != nil || len(req.IDs) == 0 {
+		http.Error(w, "ids required", http.StatusBadRequest)
+		return
+	}
+	n, err := h.broker.Ack(r.Context(), r.PathValue("name"), r.PathValue("group"), req.IDs...)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]int64{"acked": n})
+}
+
+func (h *Handler) pending(w http.ResponseWriter, r *http.Request) {
+	count, err := intParam(r.URL.Query().Get("count"), 100)
+	if err != nil {
+		http.Error(w, "invalid count", http.StatusBadRequest)
+		return
+	}
+	list, err := h.broker.Pending(r.Context(), r.PathValue("name"), r.PathValue("group"), count)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, list)
+}
+
+func (h *Handler) claim(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	consumer := q.Get("consumer")
+	if consumer == "" {
+		http.Error(w, "consumer required", http.StatusBadRequest)
+		return
+	}
+	minIdleMs, err := intParam(q.Get("min_idle"), 30000)
+	if err != nil {
+		http.Error(w, "invalid min_idle", http.StatusBadRequest)
+		return
+	}
+	count, err := intParam(q.Get("count"), 10)
+	if err != nil {
+		http.Error(w, "invalid count", http.StatusBadRequest)
+		return
+	}
+
+	msgs, err := h.broker.Claim(r.Context(), r.PathValue("name"), r.PathValue("group"), consumer, time.Duration(minIdleMs)*time.Millisecond, count)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, msgs)
+}
+
+func intParam(s string, def int64) (int64, error) {
+	if s == "" {
+		return def, nil
+	}
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || n < 0 {
+		return 0, errors.New("invalid")
+	}
+	return n, nil
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
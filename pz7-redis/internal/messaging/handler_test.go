@@ -0,0 +1,130 @@
+package messaging_test
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+
+	"github.com/icestormerrr/pz7-redis/internal/messaging"
+)
+
+func newServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { rdb.Close() })
+
+	mux := http.NewServeMux()
+	messaging.NewHandler(messaging.NewBroker(rdb)).Register(mux)
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func do(t *testing.T, method, url, body string, want int, out any) {
+	t.Helper()
+	req, err := http.NewRequest(method, url, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != want {
+		t.Fatalf("%s %s: want %d got %d", method, url, want, resp.StatusCode)
+	}
+	if out != nil {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestPublishSubscribe(t *testing.T) {
+	srv := newServer(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL+"/subscribe/news", nil)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("want text/event-stream got %q", ct)
+	}
+
+	var pub struct {
+		Receivers int64 `json:"receivers"`
+	}
+	do(t, http.MethodPost, srv.URL+"/publish/news", "hello", http.StatusOK, &pub)
+	if pub.Receivers != 1 {
+		t.Fatalf("want 1 receiver got %d", pub.Receivers)
+	}
+
+	sc := bufio.NewScanner(resp.Body)
+	for sc.Scan() {
+		if line := sc.Text(); strings.HasPrefix(line, "data: ") {
+			if line != "data: hello" {
+				t.Fatalf("unexpected event %q", line)
+			}
+			return
+		}
+	}
+	t.Fatal("no event received")
+}
+
+func TestStreamConsumerGroup(t *testing.T) {
+	srv := newServer(t)
+	base := srv.URL + "/streams/orders"
+
+	do(t, http.MethodPost, base+"/groups/billing?start=0", "", http.StatusCreated, nil)
+	do(t, http.MethodPost, base+"/groups/billing", "", http.StatusConflict, nil)
+
+	var added struct {
+		ID string `json:"id"`
+	}
+	do(t, http.MethodPost, base, `{"order":"42","total":10}`, http.StatusCreated, &added)
+
+	var msgs []messaging.Message
+	do(t, http.MethodGet, base+"/groups/billing/messages?consumer=a", "", http.StatusOK, &msgs)
+	if len(msgs) != 1 || msgs[0].ID != added.ID || msgs[0].Values["total"] != "10" {
+		t.Fatalf("unexpected messages: %+v", msgs)
+	}
+
+	var pending []messaging.Pending
+	do(t, http.MethodGet, base+"/groups/billing/pending", "", http.StatusOK, &pending)
+	if len(pending) != 1 || pending[0].Consumer != "a" {
+		t.Fatalf("unexpected pending: %+v", pending)
+	}
+
+	var claimed []messaging.Message
+	do(t, http.MethodPost, base+"/groups/billing/claim?consumer=b&min_idle=0", "", http.StatusOK, &claimed)
+	if len(claimed) != 1 || claimed[0].ID != added.ID {
+		t.Fatalf("unexpected claimed: %+v", claimed)
+	}
+
+	var acked struct {
+		Acked int64 `json:"acked"`
+	}
+	do(t, http.MethodPost, base+"/groups/billing/ack", `{"ids":["`+added.ID+`"]}`, http.StatusOK, &acked)
+	if acked.Acked != 1 {
+		t.Fatalf("want 1 acked got %d", acked.Acked)
+	}
+
+	do(t, http.MethodGet, base+"/groups/billing/pending", "", http.StatusOK, &pending)
+	if len(pending) != 0 {
+		t.Fatalf("pending must be empty: %+v", pending)
+	}
+}
@@ -0,0 +1,228 @@
+// Package ratelimit ограничение частоты запросов поверх Redis: фиксированное окно, скользящий журнал
+// и token bucket, каждый алгоритм выполняется атомарно одним Lua-скриптом.
+package ratelimit
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// ErrInvalidConfig некорректные параметры лимитера.
+var ErrInvalidConfig = errors.New("ratelimit: invalid config")
+
+// Result результат одной проверки лимита.
+type Result struct {
+	Allowed    bool
+	Limit      int
+	Remaining  int
+	ResetAfter time.Duration
+	RetryAfter time.Duration
+}
+
+// Limiter общий интерфейс для всех алгоритмов.
+type Limiter interface {
+	Allow(ctx context.Context, key string) (Result, error)
+}
+
+var fixedWindowScript = redis.NewScript(`
+local current = redis.call("INCR", KEYS[1])
+if current == 1 then
+	redis.call("PEXPIRE", KEYS[1], ARGV[1])
+end
+local ttl = redis.call("PTTL", KEYS[1])
+if ttl < 0 then
+	redis.call("PEXPIRE", KEYS[1], ARGV[1])
+	ttl = tonumber(ARGV[1])
+end
+return {current, ttl}
+`)
+
+var slidingLogScript = redis.NewScript(`
+local now = tonumber(ARGV[1])
+local window = tonumber(ARGV[2])
+local limit = tonumber(ARGV[3])
+
+redis.call("ZREMRANGEBYSCORE", KEYS[1], "-inf", now - window)
+local count = redis.call("ZCARD", KEYS[1])
+local allowed = 0
+if count < limit then
+	redis.call("ZADD", KEYS[1], now, ARGV[4])
+	redis.call("PEXPIRE", KEYS[1], window)
+	count = count + 1
+	allowed = 1
+end
+
+local oldest = redis.call("ZRANGE", KEYS[1], 0, 0, "WITHSCORES")
+local reset = window
+if oldest[2] then
+	reset = tonumber(oldest[2]) + window - now
+end
+return {allowed, limit - count, reset}
+`)
+
+var tokenBucketScript = redis.NewScript(`
+local rate = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+
+local data = redis.call("HMGET", KEYS[1], "tokens", "ts")
+local tokens = tonumber(data[1])
+local ts = tonumber(data[2])
+if tokens == nil or ts == nil then
+	tokens = burst
+	ts = now
+end
+if now > ts then
+	tokens = math.min(burst, tokens + (now - ts) * rate)
+end
+
+local allowed = 0
+local retry = 0
+if tokens >= 1 then
+	tokens = tokens - 1
+	allowed = 1
+else
+	retry = math.ceil((1 - tokens) / rate)
+end
+
+redis.call("HSET", KEYS[1], "tokens", tostring(tokens), "ts", now)
+redis.call("PEXPIRE", KEYS[1], math.ceil(burst / rate))
+return {allowed, math.floor(tokens), retry, math.ceil((burst - tokens) / rate)}
+`)
+
+// FixedWindow считает запросы в окне фиксированной длины (INCR + PEXPIRE).
+type FixedWindow struct {
+	rdb    redis.Scripter
+	limit  int
+	window time.Duration
+}
+
+func NewFixedWindow(rdb redis.Scripter, limit int, window time.Duration) (*FixedWindow, error) {
+	if err := checkWindow(limit, window); err != nil {
+		return nil, err
+	}
+	return &FixedWindow{rdb: rdb, limit: limit, window: window}, nil
+}
+
+func (l *FixedWindow) Allow(ctx context.Context, key string) (Result, error) {
+	res, err := fixedWindowScript.Run(ctx, l.rdb, []string{"ratelimit:fw:" + key}, l.window.Milliseconds()).Int64Slice()
+	if err != nil {
+		return Result{}, err
+	}
+	count, ttl := int(res[0]), time.Duration(res[1])*time.Millisecond
+
+	r := Result{
+		Allowed:    count <= l.limit,
+		Limit:      l.limit,
+		Remaining:  max(l.limit-count, 0),
+		ResetAfter: ttl,
+	}
+	if !r.Allowed {
+		r.RetryAfter = ttl
+	}
+	return r, nil
+}
+
+// SlidingLog хранит метки времени запросов в ZSET и точно считает их за последнее окно.
+type SlidingLog struct {
+	rdb    redis.Scripter
+	limit  int
+	window time.Duration
+	now    func() time.Time
+}
+
+func NewSlidingLog(rdb redis.Scripter, limit int, window time.Duration) (*SlidingLog, error) {
+	if err := checkWindow(limit, window); err != nil {
+		return nil, err
+	}
+	return &SlidingLog{rdb: rdb, limit: limit, window: window, now: time.Now}, nil
+}
+
+func (l *SlidingLog) Allow(ctx context.Context, key string) (Result, error) {
+	member, err := randomMember()
+	if err != nil {
+		return Result{}, err
+	}
+	res, err := slidingLogScript.Run(ctx, l.rdb, []string{"ratelimit:sl:" + key},
+		l.now().UnixMilli(), l.window.Milliseconds(), l.limit, member,
+	).Int64Slice()
+	if err != nil {
+		return Result{}, err
+	}
+	reset := time.Duration(res[2]) * time.Millisecond
+
+	r := Result{
+		Allowed:    res[0] == 1,
+		Limit:      l.limit,
+		Remaining:  int(res[1]),
+		ResetAfter: reset,
+	}
+	if !r.Allowed {
+		r.RetryAfter = reset
+	}
+	return r, nil
+}
+
+// TokenBucket пополняет корзину со скоростью rate токенов в секунду, не более burst.
+type TokenBucket struct {
+	rdb   redis.Scripter
+	rate  float64
+	burst int
+	now   func() time.Time
+}
+
+// NewTokenBucket rate должен быть положительным: Lua-скрипт делит на него при расчёте Retry-After.
+func NewTokenBucket(rdb redis.Scripter, rate float64, burst int) (*TokenBucket, error) {
+	if !(rate > 0) || math.IsInf(rate, 1) {
+		return nil, fmt.Errorf("%w: rate must be positive, got %v", ErrInvalidConfig, rate)
+	}
+	if burst <= 0 {
+		return nil, fmt.Errorf("%w: burst must be positive, got %d", ErrInvalidConfig, burst)
+	}
+	return &TokenBucket{rdb: rdb, rate: rate, burst: burst, now: time.Now}, nil
+}
+
+func (l *TokenBucket) Allow(ctx context.Context, key string) (Result, error) {
+	perMs := strconv.FormatFloat(l.rate/1000, 'f', -1, 64)
+	res, err := tokenBucketScript.Run(ctx, l.rdb, []string{"ratelimit:tb:" + key},
+		perMs, l.burst, l.now().UnixMilli(),
+	).Int64Slice()
+	if err != nil {
+		return Result{}, err
+	}
+
+	return Result{
+		Allowed:    res[0] == 1,
+		Limit:      l.burst,
+		Remaining:  int(res[1]),
+		RetryAfter: time.Duration(res[2]) * time.Millisecond,
+		ResetAfter: time.Duration(res[3]) * time.Millisecond,
+	}, nil
+}
+
+// checkWindow окно короче миллисекунды скрипты не различают (PEXPIRE 0 — ошибка).
+func checkWindow(limit int, window time.Duration) error {
+	if limit <= 0 {
+		return fmt.Errorf("%w: limit must be positive, got %d", ErrInvalidConfig, limit)
+	}
+	if window < time.Millisecond {
+		return fmt.Errorf("%w: window must be at least 1ms, got %v", ErrInvalidConfig, window)
+	}
+	return nil
+}
+
+func randomMember() (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,167 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+func newRedis(t *testing.T) (*redis.Client, *miniredis.Miniredis) {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { rdb.Close() })
+	return rdb, mr
+}
+
+type clock struct{ t time.Time }
+
+func (c *clock) now() time.Time          { return c.t }
+func (c *clock) advance(d time.Duration) { c.t = c.t.Add(d) }
+
+func allowN(t *testing.T, l Limiter, n int) Result {
+	t.Helper()
+	var res Result
+	for i := 0; i < n; i++ {
+		r, err := l.Allow(context.Background(), "k")
+		if err != nil {
+			t.Fatal(err)
+		}
+		res = r
+	}
+	return res
+}
+
+func TestFixedWindow(t *testing.T) {
+	rdb, mr := newRedis(t)
+	l, err := NewFixedWindow(rdb, 3, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if res := allowN(t, l, 3); !res.Allowed || res.Remaining != 0 {
+		t.Fatalf("third request: %+v", res)
+	}
+	res := allowN(t, l, 1)
+	if res.Allowed || res.RetryAfter <= 0 {
+		t.Fatalf("fourth request must be denied: %+v", res)
+	}
+
+	mr.FastForward(time.Minute)
+	if res := allowN(t, l, 1); !res.Allowed || res.Remaining != 2 {
+		t.Fatalf("new window: %+v", res)
+	}
+}
+
+func TestSlidingLog(t *testing.T) {
+	rdb, _ := newRedis(t)
+	c := &clock{t: time.Unix(1_700_000_000, 0)}
+	l, err := NewSlidingLog(rdb, 2, 10*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.now = c.now
+
+	allowN(t, l, 1)
+	c.advance(6 * time.Second)
+	allowN(t, l, 1)
+
+	res := allowN(t, l, 1)
+	if res.Allowed {
+		t.Fatalf("limit exceeded but allowed: %+v", res)
+	}
+	if res.RetryAfter != 4*time.Second {
+		t.Fatalf("want retry after 4s got %v", res.RetryAfter)
+	}
+
+	c.advance(4 * time.Second)
+	if res := allowN(t, l, 1); !res.Allowed {
+		t.Fatalf("oldest entry left the window: %+v", res)
+	}
+}
+
+func TestTokenBucket(t *testing.T) {
+	rdb, _ := newRedis(t)
+	c := &clock{t: time.Unix(1_700_000_000, 0)}
+	l, err := NewTokenBucket(rdb, 2, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.now = c.now
+
+	if res := allowN(t, l, 4); !res.Allowed || res.Remaining != 0 {
+		t.Fatalf("burst: %+v", res)
+	}
+	res := allowN(t, l, 1)
+	if res.Allowed || res.RetryAfter != 500*time.Millisecond {
+		t.Fatalf("empty bucket: %+v", res)
+	}
+
+	c.advance(time.Second)
+	if res := allowN(t, l, 2); !res.Allowed {
+		t.Fatalf("refilled bucket: %+v", res)
+	}
+	if res := allowN(t, l, 1); res.Allowed {
+		t.Fatalf("refill must not exceed rate: %+v", res)
+	}
+}
+
+func TestInvalidConfig(t *testing.T) {
+	rdb, _ := newRedis(t)
+	for name, err := range map[string]error{
+		"zero limit":  second(NewFixedWindow(rdb, 0, time.Minute)),
+		"zero window": second(NewSlidingLog(rdb, 1, 0)),
+		"zero rate":   second(NewTokenBucket(rdb, 0, 1)),
+		"negative":    second(NewTokenBucket(rdb, -1, 1)),
+		"zero burst":  second(NewTokenBucket(rdb, 1, 0)),
+	} {
+		if !errors.Is(err, ErrInvalidConfig) {
+			t.Fatalf("%s: want ErrInvalidConfig, got %v", name, err)
+		}
+	}
+}
+
+func second[T any](_ T, err error) error { return err }
+
+func TestMiddleware(t *testing.T) {
+	rdb, _ := newRedis(t)
+	l, err := NewFixedWindow(rdb, 1, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := Middleware(l, KeyByIP)(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		}),
+	)
+
+	do := func() *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/get", nil)
+		req.RemoteAddr = "10.0.0.1:1234"
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := do()
+	if rec.Code != http.StatusOK {
+		t.Fatalf("want 200 got %d", rec.Code)
+	}
+	if rec.Header().Get("RateLimit-Limit") != "1" || rec.Header().Get("RateLimit-Remaining") != "0" {
+		t.Fatalf("unexpected headers: %v", rec.Header())
+	}
+
+	rec = do()
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("want 429 got %d", rec.Code)
+	}
+	if rec.Header().Get("Retry-After") != "60" {
+		t.Fatalf("want Retry-After 60 got %q", rec.Header().Get("Retry-After"))
+	}
+}
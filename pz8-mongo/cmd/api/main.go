@@ -5,6 +5,7 @@ import (
 	"log"
 	"net/http"
 	"os"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/icestormerrr/pz8-mongo/internal/db"
@@ -26,7 +27,11 @@ func main() {
 	if err != nil {
 		log.Fatal("notes repo:", err)
 	}
-	h := notes.NewHandler(repo)
+	cacheTTL, err := time.ParseDuration(getenv("STATS_CACHE_TTL", "0s"))
+	if err != nil {
+		log.Fatal("STATS_CACHE_TTL:", err)
+	}
+	h := notes.NewHandler(repo).WithCache(cacheTTL)
 
 	r := chi.NewRouter()
 	r.Get("/health", func(w http.ResponseWriter, r *http.Request) {
@@ -0,0 +1,122 @@
+package notes
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// границы гистограммы длины содержимого (в символах)
+var lengthBuckets = bson.A{0, 100, 500, 1000, 5000, maxContentLen + 1}
+
+type DayCount struct {
+	Date  string `bson:"_id"   json:"date"`
+	Count int64  `bson:"count" json:"count"`
+}
+
+type TermCount struct {
+	Term  string `bson:"_id"   json:"term"`
+	Count int64  `bson:"count" json:"count"`
+}
+
+type LengthBucket struct {
+	From  any   `bson:"_id"   json:"from"`
+	Count int64 `bson:"count" json:"count"`
+}
+
+type Analytics struct {
+	NoteStats
+	PerDay          []DayCount     `json:"perDay"`
+	TopTerms        []TermCount    `json:"topTerms"`
+	LengthHistogram []LengthBucket `json:"lengthHistogram"`
+}
+
+// Analytics считает всю аналитику одним запросом через $facet
+func (r *Repo) Analytics(ctx context.Context, days, top int) (*Analytics, error) {
+	since := time.Now().UTC().AddDate(0, 0, -days)
+
+	perDay := bson.A{
+		bson.M{"$match": bson.M{"createdAt": bson.M{"$gte": since}}},
+		bson.M{"$group": bson.M{
+			"_id":   bson.M{"$dateToString": bson.M{"format": "%Y-%m-%d", "date": "$createdAt"}},
+			"count": bson.M{"$sum": 1},
+		}},
+		bson.M{"$sort": bson.M{"_id": 1}},
+	}
+
+	topTerms := bson.A{
+		bson.M{"$project": bson.M{"term": bson.M{"$split": bson.A{
+			bson.M{"$toLower": bson.M{"$concat": bson.A{"$title", " ", "$content"}}}, " ",
+		}}}},
+		bson.M{"$unwind": "$term"},
+		bson.M{"$project": bson.M{"term": bson.M{"$trim": bson.M{"input": "$term", "chars": ".,!?;:\"'()[]{}\n\t"}}}},
+		// короткие слова почти всегда служебные
+		bson.M{"$match": bson.M{"$expr": bson.M{"$gte": bson.A{bson.M{"$strLenCP": "$term"}, 4}}}},
+		bson.M{"$group": bson.M{"_id": "$term", "count": bson.M{"$sum": 1}}},
+		bson.M{"$sort": bson.D{{Key: "count", Value: -1}, {Key: "_id", Value: 1}}},
+		bson.M{"$limit": top},
+	}
+
+	histogram := bson.A{
+		bson.M{"$bucket": bson.M{
+			"groupBy":    bson.M{"$strLenCP": "$content"},
+			"boundaries": lengthBuckets,
+			"default":    "other",
+			"output":     bson.M{"count": bson.M{"$sum": 1}},
+		}},
+	}
+
+	totals := bson.A{
+		bson.M{"$group": bson.M{
+			"_id":       nil,
+			"total":     bson.M{"$sum": 1},
+			"avgLength": bson.M{"$avg": bson.M{"$strLenCP": "$content"}},
+		}},
+	}
+
+	pipeline := mongo.Pipeline{
+		{{Key: "$facet", Value: bson.M{
+			"totals":          totals,
+			"perDay":          perDay,
+			"topTerms":        topTerms,
+			"lengthHistogram": histogram,
+		}}},
+	}
+
+	cur, err := r.col.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(ctx)
+
+	var results []struct {
+		Totals          []NoteStats    `bson:"totals"`
+		PerDay          []DayCount     `bson:"perDay"`
+		TopTerms        []TermCount    `bson:"topTerms"`
+		LengthHistogram []LengthBucket `bson:"lengthHistogram"`
+	}
+	if err := cur.All(ctx, &results); err != nil {
+		return nil, err
+	}
+
+	out := &Analytics{PerDay: []DayCount{}, TopTerms: []TermCount{}, LengthHistogram: []LengthBucket{}}
+	if len(results) == 0 {
+		return out, nil
+	}
+	res := results[0]
+	if len(res.Totals) > 0 {
+		out.NoteStats = res.Totals[0]
+	}
+	if res.PerDay != nil {
+		out.PerDay = res.PerDay
+	}
+	if res.TopTerms != nil {
+		out.TopTerms = res.TopTerms
+	}
+	if res.LengthHistogram != nil {
+		out.LengthHistogram = res.LengthHistogram
+	}
+	return out, nil
+}
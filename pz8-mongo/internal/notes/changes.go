@@ -0,0 +1,125 @@
+package notes
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ChangeEvent изменение заметки из change stream.
+// Token — resume token, по которому можно продолжить поток после этого события.
+type ChangeEvent struct {
+	Token     string             `json:"-"`
+	Operation string             `json:"operation"`
+	NoteID    primitive.ObjectID `json:"noteId"`
+	Note      *Note              `json:"note,omitempty"`
+	At        time.Time          `json:"at"`
+}
+
+type changeDoc struct {
+	OperationType string              `bson:"operationType"`
+	ClusterTime   primitive.Timestamp `bson:"clusterTime"`
+	DocumentKey   struct {
+		ID primitive.ObjectID `bson:"_id"`
+	} `bson:"documentKey"`
+	FullDocument bson.Raw `bson:"fullDocument"`
+}
+
+// ChangeFeed обёртка над mongo.ChangeStream коллекции заметок
+type ChangeFeed struct {
+	repo *Repo
+	cs   *mongo.ChangeStream
+}
+
+// Changes открывает change stream; resumeAfter — токен последнего полученного события или "".
+// Change streams доступны только на replica set.
+func (r *Repo) Changes(ctx context.Context, resumeAfter string) (*ChangeFeed, error) {
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{
+			"operationType": bson.M{"$in": bson.A{"insert", "update", "replace", "delete"}},
+		}}},
+	}
+	opts := options.ChangeStream().
+		SetFullDocument(options.UpdateLookup).
+		SetMaxAwaitTime(time.Second)
+	if resumeAfter != "" {
+		opts.SetResumeAfter(bson.M{"_data": resumeAfter})
+	}
+
+	cs, err := r.col.Watch(ctx, pipeline, opts)
+	if err != nil {
+		return nil, err
+	}
+	return &ChangeFeed{repo: r, cs: cs}, nil
+}
+
+// TryNext ждёт событие не дольше MaxAwaitTime; ok == false означает, что событий пока нет
+func (f *ChangeFeed) TryNext(ctx context.Context) (ChangeEvent, bool, error) {
+	if !f.cs.TryNext(ctx) {
+		return ChangeEvent{}, false, f.cs.Err()
+	}
+
+	var doc changeDoc
+	if err := f.cs.Decode(&doc); err != nil {
+		return ChangeEvent{}, false, err
+	}
+	ev := ChangeEvent{
+		Token:     f.ResumeToken(),
+		Operation: doc.OperationType,
+		NoteID:    doc.DocumentKey.ID,
+		At:        time.Unix(int64(doc.ClusterTime.T), 0).UTC(),
+	}
+	if doc.FullDocument != nil {
+		// старые версии документа поднимаем только в памяти: поток не должен писать в коллекцию
+		n, err := f.repo.decodeNote(ctx, doc.FullDocument, false)
+		if err != nil {
+			return ChangeEvent{}, false, err
+		}
+		ev.Note = &n
+	}
+	return ev, true, nil
+}
+
+// ResumeToken текущая позиция потока (в том числе после пустого ожидания)
+func (f *ChangeFeed) ResumeToken() string {
+	tok := f.cs.ResumeToken()
+	if tok == nil {
+		return ""
+	}
+	data, ok := tok.Lookup("_data").StringValueOK()
+	if !ok {
+		return ""
+	}
+	return data
+}
+
+func (f *ChangeFeed) Close(ctx context.Context) error {
+	return f.cs.Close(ctx)
+}
+
+// SaveResumeToken запоминает позицию именованного потребителя, чтобы он мог
+// переподключиться без потери событий даже без Last-Event-ID
+func (r *Repo) SaveResumeToken(ctx context.Context, consumer, token string) error {
+	_, err := r.tokens.UpdateOne(ctx,
+		bson.M{"_id": consumer},
+		bson.M{"$set": bson.M{"token": token, "updatedAt": time.Now()}},
+		options.Update().SetUpsert(true),
+	)
+	return err
+}
+
+func (r *Repo) LoadResumeToken(ctx context.Context, consumer string) (string, error) {
+	var doc struct {
+		Token string `bson:"token"`
+	}
+	err := r.tokens.FindOne(ctx, bson.M{"_id": consumer}).Decode(&doc)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return "", nil
+	}
+	return doc.Token, err
+}
@@ -0,0 +1,89 @@
+package notes_test
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/icestormerrr/pz8-mongo/internal/db"
+	"github.com/icestormerrr/pz8-mongo/internal/notes"
+)
+
+// Change streams работают только на replica set:
+// docker-compose -f docker-compose.dev.yml up -d mongo-rs
+func rsURI() string {
+	if v := os.Getenv("MONGO_RS_URI"); v != "" {
+		return v
+	}
+	return "mongodb://localhost:27018/?replicaSet=rs0&directConnection=true"
+}
+
+func nextEvent(t *testing.T, ctx context.Context, feed *notes.ChangeFeed) notes.ChangeEvent {
+	t.Helper()
+	deadline := time.Now().Add(10 * time.Second)
+	for time.Now().Before(deadline) {
+		ev, ok, err := feed.TryNext(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ok {
+			return ev
+		}
+	}
+	t.Fatal("no change event received")
+	return notes.ChangeEvent{}
+}
+
+func TestChangesResume(t *testing.T) {
+	ctx := context.Background()
+	deps, err := db.ConnectMongo(ctx, rsURI(), "pz8_changes_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		deps.Client.Database("pz8_changes_test").Drop(ctx)
+		deps.Client.Disconnect(ctx)
+	})
+	r, err := notes.NewRepo(deps.Database)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	feed, err := r.Changes(ctx, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	first, err := r.Create(ctx, notes.CreateInput{Title: "first"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ev := nextEvent(t, ctx, feed)
+	if ev.Operation != "insert" || ev.NoteID != first.ID || ev.Note == nil || ev.Note.Title != "first" {
+		t.Fatalf("unexpected event %+v", ev)
+	}
+	if err := r.SaveResumeToken(ctx, "worker", ev.Token); err != nil {
+		t.Fatal(err)
+	}
+	feed.Close(ctx)
+
+	// изменения, произошедшие пока потребитель был отключён, не теряются
+	if err := r.Delete(ctx, first.ID.Hex()); err != nil {
+		t.Fatal(err)
+	}
+
+	token, err := r.LoadResumeToken(ctx, "worker")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resumed, err := r.Changes(ctx, token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resumed.Close(ctx)
+
+	ev = nextEvent(t, ctx, resumed)
+	if ev.Operation != "delete" || ev.NoteID != first.ID {
+		t.Fatalf("want delete of %s got %+v", first.ID.Hex(), ev)
+	}
+}
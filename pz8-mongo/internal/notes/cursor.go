@@ -0,0 +1,63 @@
+package notes
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// Cursor позиция последней отданной заметки для keyset-пагинации.
+// Обычный список сортируется по (createdAt, _id), поиск — по (score, _id).
+type Cursor struct {
+	CreatedAt time.Time          `json:"t,omitempty"`
+	Score     *float64           `json:"s,omitempty"`
+	ID        primitive.ObjectID `json:"id"`
+}
+
+// Page страница списка; NextCursor пустой на последней странице
+type Page struct {
+	Items      []Note `json:"items"`
+	NextCursor string `json:"nextCursor,omitempty"`
+}
+
+func (c Cursor) Encode() string {
+	b, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func DecodeCursor(s string) (*Cursor, error) {
+	if s == "" {
+		return nil, nil
+	}
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	var c Cursor
+	if err := json.Unmarshal(b, &c); err != nil || c.ID.IsZero() {
+		return nil, ErrInvalidCursor
+	}
+	return &c, nil
+}
+
+func cursorAfter(n Note, search bool) Cursor {
+	if search {
+		score := n.Score
+		return Cursor{Score: &score, ID: n.ID}
+	}
+	return Cursor{CreatedAt: n.CreatedAt, ID: n.ID}
+}
+
+// after ветки $or для условия "строго после курсора" при сортировке по убыванию field и _id
+func (c Cursor) after(field string, value any) bson.A {
+	return bson.A{
+		bson.M{field: bson.M{"$lt": value}},
+		bson.M{field: value, "_id": bson.M{"$lt": c.ID}},
+	}
+}
@@ -0,0 +1,48 @@
+package notes
+
+import (
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestCursorRoundTrip(t *testing.T) {
+	n := Note{ID: primitive.NewObjectID(), CreatedAt: time.Now().UTC().Truncate(time.Millisecond), Score: 1.5}
+
+	for _, search := range []bool{false, true} {
+		c, err := DecodeCursor(cursorAfter(n, search).Encode())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if c.ID != n.ID {
+			t.Fatalf("want id %s got %s", n.ID.Hex(), c.ID.Hex())
+		}
+		if search && (c.Score == nil || *c.Score != n.Score) {
+			t.Fatalf("want score %v got %v", n.Score, c.Score)
+		}
+		if !search && !c.CreatedAt.Equal(n.CreatedAt) {
+			t.Fatalf("want createdAt %v got %v", n.CreatedAt, c.CreatedAt)
+		}
+	}
+}
+
+func TestDecodeCursorInvalid(t *testing.T) {
+	for _, s := range []string{"%%%", "e30"} {
+		if _, err := DecodeCursor(s); err != ErrInvalidCursor {
+			t.Fatalf("%q: want ErrInvalidCursor got %v", s, err)
+		}
+	}
+}
+
+func TestResultCacheExpires(t *testing.T) {
+	c := newResultCache(20 * time.Millisecond)
+	c.set("k", 1)
+	if v, ok := c.get("k"); !ok || v != 1 {
+		t.Fatalf("want cached value got %v %v", v, ok)
+	}
+	time.Sleep(30 * time.Millisecond)
+	if _, ok := c.get("k"); ok {
+		t.Fatal("entry must expire")
+	}
+}
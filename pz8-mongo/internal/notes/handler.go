@@ -4,24 +4,43 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
+	"log"
 	"net/http"
+	"net/url"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/go-chi/chi/v5"
 )
 
-type Handler struct{ repo *Repo }
+type Handler struct {
+	repo  *Repo
+	cache *resultCache
+}
 
 func NewHandler(r *Repo) *Handler { return &Handler{repo: r} }
 
+// WithCache включает кэширование /stats и /analytics на ttl; по умолчанию кэш выключен
+func (h *Handler) WithCache(ttl time.Duration) *Handler {
+	if ttl > 0 {
+		h.cache = newResultCache(ttl)
+	}
+	return h
+}
+
 func (h *Handler) Routes() chi.Router {
 	r := chi.NewRouter()
 	r.Get("/", h.list)
 	r.Post("/", h.create)
-	r.Get("/{id}", h.get)
+	// статические маршруты регистрируются до /{id}
 	r.Get("/stats", h.stats)
+	r.Get("/analytics", h.analytics)
+	r.Get("/changes", h.changes)
+	r.Get("/{id}", h.get)
 	r.Patch("/{id}", h.patch)
+	r.Post("/{id}/extend", h.extend)
 	r.Delete("/{id}", h.del)
 	return r
 }
@@ -36,18 +55,27 @@ func writeJSON(w http.ResponseWriter, code int, v any) {
 	_ = json.NewEncoder(w).Encode(v)
 }
 
-func (h *Handler) create(w http.ResponseWriter, r *http.Request) {
-	var in struct {
-		Title   string `json:"title"`
-		Content string `json:"content"`
+func writeValidation(w http.ResponseWriter, err error) bool {
+	var ve *ValidationError
+	if !errors.As(err, &ve) {
+		return false
 	}
-	if err := json.NewDecoder(r.Body).Decode(&in); err != nil || in.Title == "" {
-		writeJSON(w, 400, map[string]string{"error": "invalid_json_or_title"})
+	writeJSON(w, 400, map[string]any{"error": "validation_failed", "fields": ve.Fields})
+	return true
+}
+
+func (h *Handler) create(w http.ResponseWriter, r *http.Request) {
+	var in CreateInput
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		writeJSON(w, 400, map[string]string{"error": "invalid_json"})
 		return
 	}
 	c, cancel := reqCtx(r)
 	defer cancel()
-	n, err := h.repo.Create(c, in.Title, in.Content)
+	n, err := h.repo.Create(c, in)
+	if writeValidation(w, err) {
+		return
+	}
 	if err != nil {
 		writeJSON(w, 409, map[string]string{"error": err.Error()})
 		return
@@ -55,6 +83,90 @@ func (h *Handler) create(w http.ResponseWriter, r *http.Request) {
 	writeJSON(w, 201, n)
 }
 
+// changes транслирует изменения заметок как Server-Sent Events.
+// Продолжить поток можно по заголовку Last-Event-ID, параметру resume
+// или по сохранённой позиции именованного потребителя (?consumer=...).
+func (h *Handler) changes(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeJSON(w, 500, map[string]string{"error": "streaming_unsupported"})
+		return
+	}
+	ctx := r.Context()
+	consumer := r.URL.Query().Get("consumer")
+
+	token := r.Header.Get("Last-Event-ID")
+	if token == "" {
+		token = r.URL.Query().Get("resume")
+	}
+	if token == "" && consumer != "" {
+		saved, err := h.repo.LoadResumeToken(ctx, consumer)
+		if err != nil {
+			writeJSON(w, 500, map[string]string{"error": err.Error()})
+			return
+		}
+		token = saved
+	}
+
+	feed, err := h.repo.Changes(ctx, token)
+	if err != nil && token != "" {
+		writeJSON(w, 400, map[string]string{"error": "invalid_resume_token"})
+		return
+	}
+	if err != nil {
+		writeJSON(w, 500, map[string]string{"error": err.Error()})
+		return
+	}
+	defer feed.Close(context.Background())
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(200)
+	flusher.Flush()
+
+	save := func(tok string) {
+		if consumer == "" || tok == "" || tok == token {
+			return
+		}
+		if err := h.repo.SaveResumeToken(ctx, consumer, tok); err != nil {
+			log.Println("save resume token:", err)
+			return
+		}
+		token = tok
+	}
+
+	lastWrite := time.Now()
+	for {
+		ev, ok, err := feed.TryNext(ctx)
+		if err != nil {
+			if ctx.Err() == nil {
+				log.Println("change stream:", err)
+			}
+			return
+		}
+		if ok {
+			data, _ := json.Marshal(ev)
+			fmt.Fprintf(w, "id: %s\nevent: %s\ndata: %s\n\n", ev.Token, ev.Operation, data)
+			flusher.Flush()
+			save(ev.Token)
+			lastWrite = time.Now()
+			continue
+		}
+
+		if ctx.Err() != nil {
+			return
+		}
+		// поток пуст: двигаем сохранённую позицию и держим соединение живым
+		save(feed.ResumeToken())
+		if time.Since(lastWrite) > 15*time.Second {
+			fmt.Fprint(w, ": ping\n\n")
+			flusher.Flush()
+			lastWrite = time.Now()
+		}
+	}
+}
+
 func (h *Handler) get(w http.ResponseWriter, r *http.Request) {
 	id := chi.URLParam(r, "id")
 	c, cancel := reqCtx(r)
@@ -72,43 +184,125 @@ func (h *Handler) get(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *Handler) list(w http.ResponseWriter, r *http.Request) {
-	q := r.URL.Query().Get("q")
-	limit, _ := strconv.ParseInt(r.URL.Query().Get("limit"), 10, 64)
-	skip, _ := strconv.ParseInt(r.URL.Query().Get("skip"), 10, 64)
+	query := r.URL.Query()
+	limit, _ := strconv.ParseInt(query.Get("limit"), 10, 64)
 	if limit <= 0 || limit > 200 {
 		limit = 20
 	}
-	if skip < 0 {
-		skip = 0
+	after, err := DecodeCursor(query.Get("cursor"))
+	if err != nil {
+		writeJSON(w, 400, map[string]string{"error": "invalid_cursor"})
+		return
+	}
+
+	f, err := parseListFilter(query)
+	if err != nil {
+		writeJSON(w, 400, map[string]string{"error": err.Error()})
+		return
 	}
 
 	c, cancel := reqCtx(r)
 	defer cancel()
-	items, err := h.repo.List(c, q, limit, skip)
+	page, err := h.repo.List(c, f, limit, after)
+	if errors.Is(err, ErrInvalidCursor) {
+		writeJSON(w, 400, map[string]string{"error": "invalid_cursor"})
+		return
+	}
 	if err != nil {
 		writeJSON(w, 500, map[string]string{"error": err.Error()})
 		return
 	}
-	writeJSON(w, 200, items)
+	writeJSON(w, 200, page)
+}
+
+// parseListFilter разбирает q, phrase, tags=a,b, author, archived и диапазон from/to (RFC3339 или YYYY-MM-DD)
+func parseListFilter(query url.Values) (ListFilter, error) {
+	f := ListFilter{
+		Query:  query.Get("q"),
+		Phrase: query.Get("phrase"),
+		Author: query.Get("author"),
+	}
+	if tags := query.Get("tags"); tags != "" {
+		f.Tags = strings.Split(tags, ",")
+	}
+	if v := query.Get("archived"); v != "" {
+		archived, err := strconv.ParseBool(v)
+		if err != nil {
+			return ListFilter{}, errors.New("invalid_archived")
+		}
+		f.Archived = &archived
+	}
+	if v := query.Get("from"); v != "" {
+		from, err := parseTime(v)
+		if err != nil {
+			return ListFilter{}, errors.New("invalid_from")
+		}
+		f.From = &from
+	}
+	if v := query.Get("to"); v != "" {
+		to, err := parseTime(v)
+		if err != nil {
+			return ListFilter{}, errors.New("invalid_to")
+		}
+		f.To = &to
+	}
+	return f, nil
+}
+
+func parseTime(v string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, v); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.DateOnly, v)
 }
 
 func (h *Handler) patch(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	var in UpdateInput
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		writeJSON(w, 400, map[string]string{"error": "invalid_json"})
+		return
+	}
+	c, cancel := reqCtx(r)
+	defer cancel()
+	n, err := h.repo.Update(c, id, in)
+	if writeValidation(w, err) {
+		return
+	}
+	if errors.Is(err, ErrNotFound) {
+		writeJSON(w, 404, map[string]string{"error": "not_found"})
+		return
+	}
+	if err != nil {
+		writeJSON(w, 500, map[string]string{"error": err.Error()})
+		return
+	}
+	writeJSON(w, 200, n)
+}
+
+func (h *Handler) extend(w http.ResponseWriter, r *http.Request) {
 	id := chi.URLParam(r, "id")
 	var in struct {
-		Title   *string `json:"title"`
-		Content *string `json:"content"`
+		TTL Duration `json:"ttl"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
-		writeJSON(w, 400, map[string]string{"error": "invalid_json"})
+		writeJSON(w, 400, map[string]string{"error": "invalid_json_or_ttl"})
 		return
 	}
 	c, cancel := reqCtx(r)
 	defer cancel()
-	n, err := h.repo.Update(c, id, in.Title, in.Content)
+	n, err := h.repo.Extend(c, id, time.Duration(in.TTL))
+	if writeValidation(w, err) {
+		return
+	}
 	if errors.Is(err, ErrNotFound) {
 		writeJSON(w, 404, map[string]string{"error": "not_found"})
 		return
 	}
+	if errors.Is(err, ErrNeverExpires) {
+		writeJSON(w, 409, map[string]string{"error": "never_expires"})
+		return
+	}
 	if err != nil {
 		writeJSON(w, 500, map[string]string{"error": err.Error()})
 		return
@@ -131,11 +325,47 @@ func (h *Handler) del(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *Handler) stats(w http.ResponseWriter, r *http.Request) {
-	stats, err := h.repo.Stats(r.Context())
+	h.cached(w, r, "stats", func(c context.Context) (any, error) {
+		return h.repo.Stats(c)
+	})
+}
+
+func (h *Handler) analytics(w http.ResponseWriter, r *http.Request) {
+	days, _ := strconv.Atoi(r.URL.Query().Get("days"))
+	if days <= 0 || days > 365 {
+		days = 30
+	}
+	top, _ := strconv.Atoi(r.URL.Query().Get("top"))
+	if top <= 0 || top > 100 {
+		top = 10
+	}
+
+	key := "analytics:" + strconv.Itoa(days) + ":" + strconv.Itoa(top)
+	h.cached(w, r, key, func(c context.Context) (any, error) {
+		return h.repo.Analytics(c, days, top)
+	})
+}
+
+// cached отдаёт результат из кэша, если он включён, иначе выполняет load
+func (h *Handler) cached(w http.ResponseWriter, r *http.Request, key string, load func(context.Context) (any, error)) {
+	if h.cache != nil {
+		if v, ok := h.cache.get(key); ok {
+			w.Header().Set("X-Cache", "HIT")
+			writeJSON(w, 200, v)
+			return
+		}
+		w.Header().Set("X-Cache", "MISS")
+	}
+
+	c, cancel := reqCtx(r)
+	defer cancel()
+	v, err := load(c)
 	if err != nil {
 		writeJSON(w, 500, map[string]string{"error": err.Error()})
 		return
 	}
-
-	writeJSON(w, 200, stats)
+	if h.cache != nil {
+		h.cache.set(key, v)
+	}
+	writeJSON(w, 200, v)
 }
@@ -0,0 +1,133 @@
+package notes
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Migration изменение уровня коллекции (индексы, переименования полей и т.п.).
+// Применённые миграции записываются в коллекцию _migrations и повторно не выполняются.
+type Migration struct {
+	ID string
+	Up func(ctx context.Context, db *mongo.Database) error
+}
+
+type MigrationState struct {
+	ID        string     `json:"id"`
+	AppliedAt *time.Time `json:"appliedAt,omitempty"`
+}
+
+// порядок важен: новые миграции добавляются только в конец
+var migrations = []Migration{
+	{ID: "0001_notes_text_index", Up: func(ctx context.Context, db *mongo.Database) error {
+		col := db.Collection("notes")
+		// в коллекции может быть только один текстовый индекс, старый индекс по title удаляем
+		if err := dropIndexIfExists(ctx, col, "title_text"); err != nil {
+			return err
+		}
+		_, err := col.Indexes().CreateOne(ctx, mongo.IndexModel{
+			Keys: bson.D{{Key: "title", Value: "text"}, {Key: "content", Value: "text"}},
+			Options: options.Index().
+				SetName("notes_text").
+				SetWeights(bson.D{{Key: "title", Value: 10}, {Key: "content", Value: 2}}),
+		})
+		return err
+	}},
+	{ID: "0002_notes_ttl_index", Up: func(ctx context.Context, db *mongo.Database) error {
+		_, err := db.Collection("notes").Indexes().CreateOne(ctx, mongo.IndexModel{
+			Keys:    bson.D{{Key: "expiresAt", Value: 1}},
+			Options: options.Index().SetExpireAfterSeconds(0),
+		})
+		return err
+	}},
+	{ID: "0003_notes_filter_indexes", Up: func(ctx context.Context, db *mongo.Database) error {
+		_, err := db.Collection("notes").Indexes().CreateMany(ctx, []mongo.IndexModel{
+			{Keys: bson.D{{Key: "createdAt", Value: -1}, {Key: "_id", Value: -1}}},
+			{Keys: bson.D{{Key: "tags", Value: 1}, {Key: "createdAt", Value: -1}}},
+			{Keys: bson.D{{Key: "author", Value: 1}, {Key: "createdAt", Value: -1}}},
+		})
+		return err
+	}},
+}
+
+func dropIndexIfExists(ctx context.Context, col *mongo.Collection, name string) error {
+	specs, err := col.Indexes().ListSpecifications(ctx)
+	if err != nil {
+		return err
+	}
+	for _, s := range specs {
+		if s.Name == name {
+			_, err := col.Indexes().DropOne(ctx, name)
+			return err
+		}
+	}
+	return nil
+}
+
+// ApplyMigrations выполняет ещё не применённые миграции и возвращает их ID
+func ApplyMigrations(ctx context.Context, db *mongo.Database) ([]string, error) {
+	applied, err := appliedMigrations(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+
+	done := []string{}
+	for _, m := range migrations {
+		if _, ok := applied[m.ID]; ok {
+			continue
+		}
+		if err := m.Up(ctx, db); err != nil {
+			return done, fmt.Errorf("migration %s: %w", m.ID, err)
+		}
+		_, err := db.Collection("_migrations").InsertOne(ctx, bson.M{"_id": m.ID, "appliedAt": time.Now()})
+		// параллельно запущенный экземпляр мог успеть записать ту же миграцию
+		if err != nil && !mongo.IsDuplicateKeyError(err) {
+			return done, err
+		}
+		done = append(done, m.ID)
+	}
+	return done, nil
+}
+
+// MigrationStatus список всех известных миграций с датой применения
+func MigrationStatus(ctx context.Context, db *mongo.Database) ([]MigrationState, error) {
+	applied, err := appliedMigrations(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]MigrationState, 0, len(migrations))
+	for _, m := range migrations {
+		st := MigrationState{ID: m.ID}
+		if at, ok := applied[m.ID]; ok {
+			st.AppliedAt = &at
+		}
+		out = append(out, st)
+	}
+	return out, nil
+}
+
+func appliedMigrations(ctx context.Context, db *mongo.Database) (map[string]time.Time, error) {
+	cur, err := db.Collection("_migrations").Find(ctx, bson.M{})
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(ctx)
+
+	var docs []struct {
+		ID        string    `bson:"_id"`
+		AppliedAt time.Time `bson:"appliedAt"`
+	}
+	if err := cur.All(ctx, &docs); err != nil {
+		return nil, err
+	}
+	out := make(map[string]time.Time, len(docs))
+	for _, d := range docs {
+		out[d.ID] = d.AppliedAt
+	}
+	return out, nil
+}
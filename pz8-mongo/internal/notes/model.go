@@ -1,16 +1,96 @@
 package notes
 
 import (
+	"bytes"
+	"encoding/json"
 	"time"
 
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
 type Note struct {
-	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id"`
-	Title     string             `bson:"title"         json:"title"`
-	Content   string             `bson:"content"       json:"content"`
-	CreatedAt time.Time          `bson:"createdAt"     json:"createdAt"`
-	UpdatedAt time.Time          `bson:"updatedAt"     json:"updatedAt"`
-	ExpiresAt time.Time          `bson:"expiresAt"`
+	ID        primitive.ObjectID `bson:"_id,omitempty"       json:"id"`
+	Title     string             `bson:"title"               json:"title"`
+	Content   string             `bson:"content"             json:"content"`
+	Tags      []string           `bson:"tags"                json:"tags"`
+	Archived  bool               `bson:"archived"            json:"archived"`
+	Author    string             `bson:"author"              json:"author"`
+	CreatedAt time.Time          `bson:"createdAt"           json:"createdAt"`
+	UpdatedAt time.Time          `bson:"updatedAt"           json:"updatedAt"`
+	ExpiresAt *time.Time         `bson:"expiresAt,omitempty" json:"expiresAt"`
+	Score     float64            `bson:"score,omitempty"     json:"score,omitempty"`
+
+	SchemaVersion int `bson:"schemaVersion" json:"-"`
+}
+
+// DefaultTTL срок жизни заметки, если при создании он не указан
+const DefaultTTL = 1000 * time.Hour
+
+// Duration длительность в формате time.ParseDuration ("72h", "30m")
+type Duration time.Duration
+
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return err
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(v)
+	return nil
+}
+
+// NullableTime отличает отсутствующее поле (Set == false) от явного null (Set == true, Value == nil)
+type NullableTime struct {
+	Set   bool
+	Value *time.Time
+}
+
+func (t *NullableTime) UnmarshalJSON(b []byte) error {
+	t.Set = true
+	if bytes.Equal(b, []byte("null")) {
+		t.Value = nil
+		return nil
+	}
+	var v time.Time
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+	t.Value = &v
+	return nil
+}
+
+// CreateInput поля новой заметки.
+// Срок жизни задаётся либо ttl, либо expiresAt; "expiresAt": null — заметка не истекает.
+type CreateInput struct {
+	Title     string       `json:"title"`
+	Content   string       `json:"content"`
+	Tags      []string     `json:"tags"`
+	Author    string       `json:"author"`
+	TTL       *Duration    `json:"ttl"`
+	ExpiresAt NullableTime `json:"expiresAt"`
+}
+
+// UpdateInput частичное обновление: nil означает "не менять"
+type UpdateInput struct {
+	Title    *string   `json:"title"`
+	Content  *string   `json:"content"`
+	Tags     *[]string `json:"tags"`
+	Archived *bool     `json:"archived"`
+
+	TTL       *Duration    `json:"ttl"`
+	ExpiresAt NullableTime `json:"expiresAt"`
+}
+
+// ListFilter параметры поиска и фильтрации списка
+type ListFilter struct {
+	Query    string
+	Phrase   string
+	Tags     []string
+	Author   string
+	Archived *bool
+	From     *time.Time
+	To       *time.Time
 }
@@ -3,6 +3,7 @@ package notes
 import (
 	"context"
 	"errors"
+	"strings"
 	"time"
 
 	"go.mongodb.org/mongo-driver/bson"
@@ -11,34 +12,41 @@ import (
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
-var ErrNotFound = errors.New("note not found")
+var (
+	ErrNotFound     = errors.New("note not found")
+	ErrNeverExpires = errors.New("note never expires")
+)
 
 type Repo struct {
-	col *mongo.Collection
+	col    *mongo.Collection
+	tokens *mongo.Collection
 }
 
+// NewRepo применяет недостающие миграции коллекции (индексы) и возвращает репозиторий
 func NewRepo(db *mongo.Database) (*Repo, error) {
-	col := db.Collection("notes")
-	_, errTitleIndex := col.Indexes().CreateOne(context.Background(), mongo.IndexModel{
-		Keys:    bson.D{{Key: "title", Value: "text"}},
-		Options: options.Index(),
-	})
-	if errTitleIndex != nil {
-		return nil, errTitleIndex
-	}
-	_, errExpirationIndex := col.Indexes().CreateOne(context.Background(), mongo.IndexModel{
-		Keys:    bson.D{{Key: "expiresAt", Value: 1}},
-		Options: options.Index().SetExpireAfterSeconds(0),
-	})
-	if errExpirationIndex != nil {
-		return nil, errExpirationIndex
-	}
-	return &Repo{col: col}, nil
+	if _, err := ApplyMigrations(context.Background(), db); err != nil {
+		return nil, err
+	}
+	return &Repo{col: db.Collection("notes"), tokens: db.Collection("note_change_tokens")}, nil
 }
 
-func (r *Repo) Create(ctx context.Context, title, content string) (Note, error) {
+func (r *Repo) Create(ctx context.Context, in CreateInput) (Note, error) {
+	in.normalize()
+	if err := in.validate(); err != nil {
+		return Note{}, err
+	}
 	now := time.Now()
-	n := Note{Title: title, Content: content, CreatedAt: now, UpdatedAt: now, ExpiresAt: now.Add(time.Hour * 1000)}
+	n := Note{
+		Title:     in.Title,
+		Content:   in.Content,
+		Tags:      in.Tags,
+		Author:    in.Author,
+		CreatedAt: now,
+		UpdatedAt: now,
+		ExpiresAt: in.expiry(now),
+
+		SchemaVersion: SchemaVersion,
+	}
 	res, err := r.col.InsertOne(ctx, n)
 	if err != nil {
 		return Note{}, err
@@ -52,62 +60,184 @@ func (r *Repo) ByID(ctx context.Context, idHex string) (Note, error) {
 	if err != nil {
 		return Note{}, ErrNotFound
 	}
-	var n Note
-	if err := r.col.FindOne(ctx, bson.M{"_id": oid}).Decode(&n); err != nil {
+	raw, err := r.col.FindOne(ctx, bson.M{"_id": oid}).Raw()
+	if err != nil {
 		if errors.Is(err, mongo.ErrNoDocuments) {
 			return Note{}, ErrNotFound
 		}
 		return Note{}, err
 	}
-	return n, nil
+	return r.decodeNote(ctx, raw, true)
 }
 
-func (r *Repo) List(ctx context.Context, q string, limit, skip int64) ([]Note, error) {
+// List возвращает страницу заметок с keyset-пагинацией: вместо skip используется
+// курсор последней заметки предыдущей страницы, поэтому запрос опирается на индекс.
+func (r *Repo) List(ctx context.Context, f ListFilter, limit int64, after *Cursor) (Page, error) {
 	filter := bson.M{}
-	if q != "" {
-		filter = bson.M{"$text": bson.M{"$search": q}}
+	if len(f.Tags) > 0 {
+		filter["tags"] = bson.M{"$all": normalizeTags(f.Tags)}
+	}
+	if f.Author != "" {
+		filter["author"] = f.Author
+	}
+	if f.Archived != nil {
+		filter["archived"] = *f.Archived
+	}
+	if f.From != nil || f.To != nil {
+		created := bson.M{}
+		if f.From != nil {
+			created["$gte"] = *f.From
+		}
+		if f.To != nil {
+			created["$lt"] = *f.To
+		}
+		filter["createdAt"] = created
+	}
+
+	search := textSearch(f.Query, f.Phrase)
+	if after != nil && (after.Score != nil) != (search != "") {
+		return Page{}, ErrInvalidCursor
+	}
+
+	var (
+		cur *mongo.Cursor
+		err error
+	)
+	if search != "" {
+		filter["$text"] = bson.M{"$search": search}
+		// textScore нельзя использовать в фильтре find, поэтому поиск идёт через aggregate
+		pipeline := mongo.Pipeline{
+			{{Key: "$match", Value: filter}},
+			{{Key: "$addFields", Value: bson.M{"score": bson.M{"$meta": "textScore"}}}},
+		}
+		if after != nil {
+			pipeline = append(pipeline, bson.D{{Key: "$match", Value: bson.M{"$or": after.after("score", *after.Score)}}})
+		}
+		pipeline = append(pipeline,
+			bson.D{{Key: "$sort", Value: bson.D{{Key: "score", Value: -1}, {Key: "_id", Value: -1}}}},
+			bson.D{{Key: "$limit", Value: limit + 1}},
+		)
+		cur, err = r.col.Aggregate(ctx, pipeline)
+	} else {
+		if after != nil {
+			filter["$or"] = after.after("createdAt", after.CreatedAt)
+		}
+		opts := options.Find().
+			SetLimit(limit + 1).
+			SetSort(bson.D{{Key: "createdAt", Value: -1}, {Key: "_id", Value: -1}})
+		cur, err = r.col.Find(ctx, filter, opts)
 	}
-	opts := options.Find().SetLimit(limit).SetSkip(skip).SetSort(bson.D{{Key: "createdAt", Value: -1}})
-	cur, err := r.col.Find(ctx, filter, opts)
 	if err != nil {
-		return nil, err
+		return Page{}, err
 	}
 	defer cur.Close(ctx)
 
-	var out []Note
-	for cur.Next(ctx) {
-		var n Note
-		if err := cur.Decode(&n); err != nil {
-			return nil, err
-		}
-		out = append(out, n)
+	items, err := r.decodeAll(ctx, cur)
+	if err != nil {
+		return Page{}, err
+	}
+
+	// лишняя запись показывает, что есть следующая страница
+	page := Page{Items: items}
+	if int64(len(items)) > limit {
+		page.Items = items[:limit]
+		page.NextCursor = cursorAfter(page.Items[limit-1], search != "").Encode()
+	}
+	return page, nil
+}
+
+// textSearch собирает строку для $text: фраза берётся в кавычки и ищется целиком
+func textSearch(q, phrase string) string {
+	q = strings.TrimSpace(q)
+	phrase = strings.TrimSpace(strings.ReplaceAll(phrase, `"`, ""))
+	if phrase == "" {
+		return q
+	}
+	if q == "" {
+		return `"` + phrase + `"`
 	}
-	return out, cur.Err()
+	return `"` + phrase + `" ` + q
 }
 
-func (r *Repo) Update(ctx context.Context, idHex string, title, content *string) (Note, error) {
+func (r *Repo) Update(ctx context.Context, idHex string, in UpdateInput) (Note, error) {
 	oid, err := primitive.ObjectIDFromHex(idHex)
 	if err != nil {
 		return Note{}, ErrNotFound
 	}
+	in.normalize()
+	if err := in.validate(); err != nil {
+		return Note{}, err
+	}
 
 	set := bson.M{"updatedAt": time.Now()}
-	if title != nil {
-		set["title"] = *title
+	if in.Title != nil {
+		set["title"] = *in.Title
+	}
+	if in.Content != nil {
+		set["content"] = *in.Content
+	}
+	if in.Tags != nil {
+		set["tags"] = *in.Tags
 	}
-	if content != nil {
-		set["content"] = *content
+	if in.Archived != nil {
+		set["archived"] = *in.Archived
+	}
+	update := bson.M{"$set": set}
+	// отсутствующее поле TTL-индекс игнорирует, поэтому "никогда не истекает" = $unset
+	if expiresAt, ok := in.expiry(time.Now()); ok && expiresAt != nil {
+		set["expiresAt"] = *expiresAt
+	} else if ok {
+		update["$unset"] = bson.M{"expiresAt": ""}
 	}
 
 	after := options.FindOneAndUpdate().SetReturnDocument(options.After)
-	var updated Note
-	if err := r.col.FindOneAndUpdate(ctx, bson.M{"_id": oid}, bson.M{"$set": set}, after).Decode(&updated); err != nil {
+	raw, err := r.col.FindOneAndUpdate(ctx, bson.M{"_id": oid}, update, after).Raw()
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return Note{}, ErrNotFound
+		}
+		return Note{}, err
+	}
+	return r.decodeNote(ctx, raw, true)
+}
+
+// Extend продлевает срок жизни на ttl от текущего срока (или от now, если он уже прошёл)
+func (r *Repo) Extend(ctx context.Context, idHex string, ttl time.Duration) (Note, error) {
+	oid, err := primitive.ObjectIDFromHex(idHex)
+	if err != nil {
+		return Note{}, ErrNotFound
+	}
+	v := validator{}
+	checkTTL(v, ttl)
+	if err := v.err(); err != nil {
+		return Note{}, err
+	}
+
+	n, err := r.ByID(ctx, idHex)
+	if err != nil {
+		return Note{}, err
+	}
+	if n.ExpiresAt == nil {
+		return Note{}, ErrNeverExpires
+	}
+	now := time.Now()
+	base := *n.ExpiresAt
+	if base.Before(now) {
+		base = now
+	}
+
+	after := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	filter := bson.M{"_id": oid, "expiresAt": n.ExpiresAt}
+	update := bson.M{"$set": bson.M{"expiresAt": base.Add(ttl), "updatedAt": now}}
+	raw, err := r.col.FindOneAndUpdate(ctx, filter, update, after).Raw()
+	if err != nil {
 		if errors.Is(err, mongo.ErrNoDocuments) {
+			// заметку удалили или срок изменили параллельно
 			return Note{}, ErrNotFound
 		}
 		return Note{}, err
 	}
-	return updated, nil
+	return r.decodeNote(ctx, raw, true)
 }
 
 func (r *Repo) Delete(ctx context.Context, idHex string) error {
@@ -24,7 +24,7 @@ func TestCreateAndGet(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	created, err := r.Create(ctx, "T1", "C1")
+	created, err := r.Create(ctx, notes.CreateInput{Title: "T1", Content: "C1"})
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -0,0 +1,164 @@
+package notes
+
+import (
+	"context"
+	"fmt"
+	"math"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// SchemaVersion текущая версия документа заметки.
+// Документы без поля schemaVersion считаются версией 1.
+const SchemaVersion = 2
+
+// docMigrations[v] переводит документ из версии v в v+1
+var docMigrations = map[int]func(doc bson.M){
+	// v2: теги, архив и автор (раньше отсутствовали и читались нулевыми значениями)
+	1: func(doc bson.M) {
+		if doc["tags"] == nil {
+			doc["tags"] = bson.A{}
+		}
+		if _, ok := doc["archived"]; !ok {
+			doc["archived"] = false
+		}
+		if _, ok := doc["author"]; !ok {
+			doc["author"] = ""
+		}
+	},
+}
+
+func docVersion(doc bson.M) int {
+	switch v := doc["schemaVersion"].(type) {
+	case int32:
+		return int(v)
+	case int64:
+		return int(v)
+	case int:
+		return v
+	default:
+		return 1
+	}
+}
+
+// upgradeDoc применяет миграции по порядку; возвращает исходную версию документа
+func upgradeDoc(doc bson.M) (int, error) {
+	from := docVersion(doc)
+	for v := from; v < SchemaVersion; v++ {
+		m, ok := docMigrations[v]
+		if !ok {
+			return from, fmt.Errorf("no migration from schema version %d", v)
+		}
+		m(doc)
+	}
+	doc["schemaVersion"] = SchemaVersion
+	return from, nil
+}
+
+// decodeNote декодирует заметку, при необходимости поднимая версию схемы.
+// Обновлённый документ сохраняется обратно (ленивая миграция при чтении),
+// если persist == true; запись условна, чтобы не затереть параллельное изменение.
+func (r *Repo) decodeNote(ctx context.Context, raw bson.Raw, persist bool) (Note, error) {
+	var doc bson.M
+	if err := bson.Unmarshal(raw, &doc); err != nil {
+		return Note{}, err
+	}
+	from, err := upgradeDoc(doc)
+	if err != nil {
+		return Note{}, err
+	}
+
+	var n Note
+	if from == SchemaVersion {
+		err := bson.Unmarshal(raw, &n)
+		return n, err
+	}
+	b, err := bson.Marshal(doc)
+	if err != nil {
+		return Note{}, err
+	}
+	if err := bson.Unmarshal(b, &n); err != nil {
+		return Note{}, err
+	}
+
+	if persist {
+		delete(doc, "score")
+		filter := bson.M{"_id": doc["_id"], "updatedAt": doc["updatedAt"], "schemaVersion": bson.M{"$ne": SchemaVersion}}
+		if _, err := r.col.ReplaceOne(ctx, filter, doc); err != nil {
+			return Note{}, err
+		}
+	}
+	return n, nil
+}
+
+func (r *Repo) decodeAll(ctx context.Context, cur *mongo.Cursor) ([]Note, error) {
+	out := []Note{}
+	for cur.Next(ctx) {
+		n, err := r.decodeNote(ctx, cur.Current, true)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, n)
+	}
+	return out, cur.Err()
+}
+
+// UpgradeAll массово поднимает версию схемы у всех устаревших документов
+func (r *Repo) UpgradeAll(ctx context.Context, batch int) (int64, error) {
+	if batch <= 0 || batch > math.MaxInt32 {
+		return 0, fmt.Errorf("invalid batch size %d", batch)
+	}
+	filter := bson.M{"$or": bson.A{
+		bson.M{"schemaVersion": bson.M{"$exists": false}},
+		bson.M{"schemaVersion": bson.M{"$lt": SchemaVersion}},
+	}}
+	cur, err := r.col.Find(ctx, filter, options.Find().SetBatchSize(int32(batch)))
+	if err != nil {
+		return 0, err
+	}
+	defer cur.Close(ctx)
+
+	var upgraded int64
+	models := make([]mongo.WriteModel, 0, batch)
+	flush := func() error {
+		if len(models) == 0 {
+			return nil
+		}
+		res, err := r.col.BulkWrite(ctx, models, options.BulkWrite().SetOrdered(false))
+		if err != nil {
+			return err
+		}
+		upgraded += res.ModifiedCount
+		models = models[:0]
+		return nil
+	}
+
+	for cur.Next(ctx) {
+		var doc bson.M
+		if err := cur.Decode(&doc); err != nil {
+			return upgraded, err
+		}
+		from, err := upgradeDoc(doc)
+		if err != nil {
+			return upgraded, err
+		}
+		filter := bson.M{"_id": doc["_id"]}
+		if from == 1 {
+			filter["schemaVersion"] = bson.M{"$exists": false}
+		} else {
+			filter["schemaVersion"] = from
+		}
+		models = append(models, mongo.NewReplaceOneModel().SetFilter(filter).SetReplacement(doc))
+		if len(models) == batch {
+			if err := flush(); err != nil {
+				return upgraded, err
+			}
+		}
+	}
+	if err := cur.Err(); err != nil {
+		return upgraded, err
+	}
+	return upgraded, flush()
+}
@@ -0,0 +1,55 @@
+package notes
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestUpgradeDocFromV1(t *testing.T) {
+	// документ в формате до появления tags/archived/author и schemaVersion
+	raw, err := bson.Marshal(bson.M{
+		"_id":       primitive.NewObjectID(),
+		"title":     "old",
+		"content":   "c",
+		"createdAt": time.Now(),
+		"updatedAt": time.Now(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var r Repo
+	n, err := r.decodeNote(context.Background(), raw, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n.SchemaVersion != SchemaVersion {
+		t.Fatalf("want schema version %d got %d", SchemaVersion, n.SchemaVersion)
+	}
+	if n.Tags == nil || len(n.Tags) != 0 {
+		t.Fatalf("want empty tags got %#v", n.Tags)
+	}
+}
+
+func TestUpgradeDocCurrentIsNoop(t *testing.T) {
+	doc := bson.M{"schemaVersion": int32(SchemaVersion), "tags": nil}
+	from, err := upgradeDoc(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if from != SchemaVersion || doc["tags"] != nil {
+		t.Fatalf("current document must not change: %v", doc)
+	}
+}
+
+func TestEveryVersionHasMigration(t *testing.T) {
+	for v := 1; v < SchemaVersion; v++ {
+		if _, ok := docMigrations[v]; !ok {
+			t.Fatalf("missing migration from version %d", v)
+		}
+	}
+}
@@ -0,0 +1,187 @@
+package notes
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	maxTitleLen   = 200
+	maxContentLen = 20000
+	maxAuthorLen  = 100
+	maxTags       = 20
+	maxTagLen     = 32
+	maxTTL        = 10 * 365 * 24 * time.Hour
+)
+
+// ValidationError содержит описание ошибки для каждого некорректного поля
+type ValidationError struct {
+	Fields map[string]string
+}
+
+func (e *ValidationError) Error() string {
+	keys := make([]string, 0, len(e.Fields))
+	for k := range e.Fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	parts := make([]string, 0, len(keys))
+	for _, k := range keys {
+		parts = append(parts, k+": "+e.Fields[k])
+	}
+	return "validation failed: " + strings.Join(parts, "; ")
+}
+
+type validator map[string]string
+
+func (v validator) add(field, msg string) {
+	if _, ok := v[field]; !ok {
+		v[field] = msg
+	}
+}
+
+func (v validator) err() error {
+	if len(v) == 0 {
+		return nil
+	}
+	return &ValidationError{Fields: v}
+}
+
+// normalize обрезает пробелы и приводит теги к нижнему регистру без повторов
+func (in *CreateInput) normalize() {
+	in.Title = strings.TrimSpace(in.Title)
+	in.Author = strings.TrimSpace(in.Author)
+	in.Tags = normalizeTags(in.Tags)
+}
+
+func (in *CreateInput) validate() error {
+	v := validator{}
+	checkTitle(v, in.Title)
+	checkContent(v, in.Content)
+	checkTags(v, in.Tags)
+	if utf8.RuneCountInString(in.Author) > maxAuthorLen {
+		v.add("author", fmt.Sprintf("must be at most %d characters", maxAuthorLen))
+	}
+	checkExpiry(v, in.TTL, in.ExpiresAt)
+	return v.err()
+}
+
+// expiry вычисляет срок жизни новой заметки; nil — заметка не истекает
+func (in *CreateInput) expiry(now time.Time) *time.Time {
+	if in.TTL != nil {
+		t := now.Add(time.Duration(*in.TTL))
+		return &t
+	}
+	if in.ExpiresAt.Set {
+		return in.ExpiresAt.Value
+	}
+	t := now.Add(DefaultTTL)
+	return &t
+}
+
+func (in *UpdateInput) normalize() {
+	if in.Title != nil {
+		t := strings.TrimSpace(*in.Title)
+		in.Title = &t
+	}
+	if in.Tags != nil {
+		t := normalizeTags(*in.Tags)
+		in.Tags = &t
+	}
+}
+
+func (in *UpdateInput) validate() error {
+	v := validator{}
+	if in.Title != nil {
+		checkTitle(v, *in.Title)
+	}
+	if in.Content != nil {
+		checkContent(v, *in.Content)
+	}
+	if in.Tags != nil {
+		checkTags(v, *in.Tags)
+	}
+	checkExpiry(v, in.TTL, in.ExpiresAt)
+	return v.err()
+}
+
+// expiry возвращает новый срок жизни и признак того, что его нужно менять
+func (in *UpdateInput) expiry(now time.Time) (*time.Time, bool) {
+	if in.TTL != nil {
+		t := now.Add(time.Duration(*in.TTL))
+		return &t, true
+	}
+	if in.ExpiresAt.Set {
+		return in.ExpiresAt.Value, true
+	}
+	return nil, false
+}
+
+func checkExpiry(v validator, ttl *Duration, expiresAt NullableTime) {
+	if ttl != nil && expiresAt.Set {
+		v.add("ttl", "use either ttl or expiresAt")
+		return
+	}
+	if ttl != nil {
+		checkTTL(v, time.Duration(*ttl))
+	}
+	if expiresAt.Value != nil && !expiresAt.Value.After(time.Now()) {
+		v.add("expiresAt", "must be in the future")
+	}
+}
+
+func checkTTL(v validator, ttl time.Duration) {
+	switch {
+	case ttl <= 0:
+		v.add("ttl", "must be positive")
+	case ttl > maxTTL:
+		v.add("ttl", "must be at most 10 years")
+	}
+}
+
+func checkTitle(v validator, title string) {
+	switch n := utf8.RuneCountInString(title); {
+	case n == 0:
+		v.add("title", "required")
+	case n > maxTitleLen:
+		v.add("title", fmt.Sprintf("must be at most %d characters", maxTitleLen))
+	}
+}
+
+func checkContent(v validator, content string) {
+	if utf8.RuneCountInString(content) > maxContentLen {
+		v.add("content", fmt.Sprintf("must be at most %d characters", maxContentLen))
+	}
+}
+
+func checkTags(v validator, tags []string) {
+	if len(tags) > maxTags {
+		v.add("tags", fmt.Sprintf("at most %d tags allowed", maxTags))
+	}
+	for _, t := range tags {
+		if utf8.RuneCountInString(t) > maxTagLen {
+			v.add("tags", fmt.Sprintf("tag %q is longer than %d characters", t, maxTagLen))
+		}
+		if strings.ContainsAny(t, " ,") {
+			v.add("tags", fmt.Sprintf("tag %q must not contain spaces or commas", t))
+		}
+	}
+}
+
+func normalizeTags(tags []string) []string {
+	out := make([]string, 0, len(tags))
+	seen := make(map[string]bool, len(tags))
+	for _, t := range tags {
+		t = strings.ToLower(strings.TrimSpace(t))
+		if t == "" || seen[t] {
+			continue
+		}
+		seen[t] = true
+		out = append(out, t)
+	}
+	return out
+}
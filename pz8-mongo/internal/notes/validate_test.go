@@ -0,0 +1,78 @@
+package notes
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCreateInputValidate(t *testing.T) {
+	in := CreateInput{
+		Title:   "  ",
+		Content: strings.Repeat("x", maxContentLen+1),
+		Tags:    []string{"go", "bad tag"},
+	}
+	in.normalize()
+
+	var ve *ValidationError
+	if err := in.validate(); !errors.As(err, &ve) {
+		t.Fatalf("want ValidationError got %v", err)
+	}
+	for _, field := range []string{"title", "content", "tags"} {
+		if _, ok := ve.Fields[field]; !ok {
+			t.Fatalf("want error for %s, got %v", field, ve.Fields)
+		}
+	}
+}
+
+func TestNormalizeTags(t *testing.T) {
+	got := normalizeTags([]string{" Go ", "mongo", "go", ""})
+	if strings.Join(got, ",") != "go,mongo" {
+		t.Fatalf("want go,mongo got %v", got)
+	}
+}
+
+func TestTextSearch(t *testing.T) {
+	if got := textSearch("mongo", `hello "world`); got != `"hello world" mongo` {
+		t.Fatalf("unexpected search string %q", got)
+	}
+}
+
+func TestCreateInputExpiry(t *testing.T) {
+	now := time.Now()
+	cases := []struct {
+		body string
+		want *time.Time
+	}{
+		{`{"title":"a"}`, ptr(now.Add(DefaultTTL))},
+		{`{"title":"a","ttl":"2h"}`, ptr(now.Add(2 * time.Hour))},
+		{`{"title":"a","expiresAt":null}`, nil},
+	}
+	for _, c := range cases {
+		var in CreateInput
+		if err := json.Unmarshal([]byte(c.body), &in); err != nil {
+			t.Fatal(err)
+		}
+		if err := in.validate(); err != nil {
+			t.Fatalf("%s: %v", c.body, err)
+		}
+		got := in.expiry(now)
+		if (got == nil) != (c.want == nil) || (got != nil && !got.Equal(*c.want)) {
+			t.Fatalf("%s: want %v got %v", c.body, c.want, got)
+		}
+	}
+}
+
+func TestUpdateInputRejectsTTLAndExpiresAt(t *testing.T) {
+	var in UpdateInput
+	if err := json.Unmarshal([]byte(`{"ttl":"1h","expiresAt":null}`), &in); err != nil {
+		t.Fatal(err)
+	}
+	if err := in.validate(); err == nil {
+		t.Fatal("want validation error")
+	}
+}
+
+func ptr(t time.Time) *time.Time { return &t }
@@ -6,13 +6,21 @@ import (
 
 	"github.com/go-chi/chi/v5"
 
+	"github.com/icestormerrr/pz9-auth/internal/core"
 	"github.com/icestormerrr/pz9-auth/internal/http/handlers"
+	"github.com/icestormerrr/pz9-auth/internal/http/middleware"
+	"github.com/icestormerrr/pz9-auth/internal/mail"
+	"github.com/icestormerrr/pz9-auth/internal/password"
 	"github.com/icestormerrr/pz9-auth/internal/platform/config"
 	"github.com/icestormerrr/pz9-auth/internal/repo"
+	"github.com/icestormerrr/pz9-auth/internal/tokens"
 )
 
 func main() {
 	cfg := config.Load()
+	if len(cfg.TokenSecret) < config.MinTokenSecretLength {
+		log.Fatalf("TOKEN_SECRET must be at least %d bytes", config.MinTokenSecretLength)
+	}
 	db, err := repo.Open(cfg.DB_DSN)
 	if err != nil {
 		log.Fatal("db connect:", err)
@@ -25,13 +33,134 @@ func main() {
 	if err := users.AutoMigrate(); err != nil {
 		log.Fatal("migrate:", err)
 	}
+	sessions := repo.NewSessionRepo(db)
+	if err := sessions.AutoMigrate(); err != nil {
+		log.Fatal("migrate:", err)
+	}
+	oneTimeTokens := repo.NewTokenRepo(db)
+	if err := oneTimeTokens.AutoMigrate(); err != nil {
+		log.Fatal("migrate:", err)
+	}
+
+	lockouts := repo.NewLockoutRepo(db)
+	if err := lockouts.AutoMigrate(); err != nil {
+		log.Fatal("migrate:", err)
+	}
+	events := repo.NewEventRepo(db)
+	if err := events.AutoMigrate(); err != nil {
+		log.Fatal("migrate:", err)
+	}
 
-	auth := &handlers.AuthHandler{Users: users, BcryptCost: cfg.BcryptCost}
+	recovery := repo.NewRecoveryRepo(db)
+	if err := recovery.AutoMigrate(); err != nil {
+		log.Fatal("migrate:", err)
+	}
+
+	if cfg.HashAlgorithm != password.Bcrypt && cfg.HashAlgorithm != password.Argon2id {
+		log.Fatalf("unknown HASH_ALGORITHM %q", cfg.HashAlgorithm)
+	}
+
+	mailer, err := mail.New(cfg.MailDriver, cfg.MailFrom, cfg.MailDir, cfg.SMTPAddr, cfg.SMTPUser, cfg.SMTPPassword)
+	if err != nil {
+		log.Fatal("mailer:", err)
+	}
+
+	auth := &handlers.AuthHandler{
+		Users:    users,
+		Sessions: sessions,
+		Tokens:   oneTimeTokens,
+		Signer:   tokens.NewSigner(cfg.TokenSecret),
+		Mailer:   mailer,
+		Passwords: &password.Hasher{
+			Algorithm:  cfg.HashAlgorithm,
+			BcryptCost: cfg.BcryptCost,
+			Argon2: password.Argon2Params{
+				MemoryKB: uint32(cfg.Argon2MemoryKB),
+				Time:     uint32(cfg.Argon2Time),
+				Threads:  uint8(cfg.Argon2Threads),
+				SaltLen:  password.DefaultArgon2.SaltLen,
+				KeyLen:   password.DefaultArgon2.KeyLen,
+			},
+		},
+		Policy: password.Policy{
+			MinLength:  cfg.PasswordMinLength,
+			MaxLength:  password.DefaultPolicy.MaxLength,
+			MinClasses: cfg.PasswordMinClasses,
+		},
+		Breach:   breachChecker(cfg.BreachCheck),
+		Lockouts: lockouts,
+		Events:   events,
+		Recovery: recovery,
+
+		AccountLockout: core.LockoutPolicy{
+			Threshold: cfg.LockoutThreshold,
+			Base:      cfg.LockoutBase,
+			Max:       cfg.LockoutMax,
+			Window:    cfg.LockoutWindow,
+		},
+		IPLockout: core.LockoutPolicy{
+			Threshold: cfg.LockoutIPThreshold,
+			Base:      cfg.LockoutBase,
+			Max:       cfg.LockoutMax,
+			Window:    cfg.LockoutWindow,
+		},
+
+		VerifyTokenTTL:           cfg.VerifyTokenTTL,
+		VerifyResendInterval:     cfg.VerifyResendInterval,
+		ResetTokenTTL:            cfg.ResetTokenTTL,
+		RequireEmailVerification: cfg.RequireEmailVerification,
+		SessionIdleTimeout:       cfg.SessionIdleTimeout,
+		SessionAbsoluteTimeout:   cfg.SessionAbsoluteTimeout,
+		TOTPIssuer:               cfg.TOTPIssuer,
+		TwoFactorChallengeTTL:    cfg.TwoFactorChallengeTTL,
+	}
 
 	r := chi.NewRouter()
 	r.Post("/auth/register", auth.Register)
 	r.Post("/auth/login", auth.Login)
+	r.Post("/auth/verify-email", auth.VerifyEmail)
+	r.Post("/auth/resend-verification", auth.ResendVerification)
+	r.Post("/auth/forgot-password", auth.ForgotPassword)
+	r.Post("/auth/reset-password", auth.ResetPassword)
+	r.Post("/auth/2fa/verify", auth.VerifyTwoFactor)
+
+	r.Group(func(r chi.Router) {
+		r.Use(middleware.RequireSession(sessions, cfg.SessionIdleTimeout))
+		r.Post("/auth/logout", auth.Logout)
+		r.Get("/auth/me", auth.Me)
+		r.Get("/auth/sessions", auth.ListSessions)
+		r.Delete("/auth/sessions", auth.RevokeOtherSessions)
+		r.Delete("/auth/sessions/{id}", auth.RevokeSession)
+
+		r.Get("/auth/2fa", auth.TwoFactorStatus)
+		r.Post("/auth/2fa/setup", auth.SetupTwoFactor)
+		r.Get("/auth/2fa/qr.png", auth.TwoFactorQR)
+		r.Post("/auth/2fa/confirm", auth.ConfirmTwoFactor)
+		r.Post("/auth/2fa/disable", auth.DisableTwoFactor)
+		r.Post("/auth/2fa/recovery-codes", auth.RegenerateRecoveryCodes)
+	})
+
+	admin := &handlers.AdminHandler{Users: users, Lockouts: lockouts, Events: events}
+	r.Route("/admin", func(r chi.Router) {
+		r.Use(middleware.RequireSession(sessions, cfg.SessionIdleTimeout))
+		r.Use(middleware.RequireAdmin(users, cfg.AdminEmails, cfg.AdminRequire2FA))
+		r.Get("/auth-events", admin.AuthEvents)
+		r.Get("/lockouts", admin.ListLockouts)
+		r.Post("/users/{id}/unlock", admin.UnlockUser)
+		r.Post("/ips/{ip}/unlock", admin.UnlockIP)
+	})
 
 	log.Println("listening on", cfg.Addr)
 	log.Fatal(http.ListenAndServe(":"+cfg.Addr, r))
 }
+
+func breachChecker(mode string) password.BreachChecker {
+	switch mode {
+	case "hibp":
+		return password.NewHIBPChecker()
+	case "off":
+		return nil
+	default:
+		return password.NewOfflineChecker()
+	}
+}
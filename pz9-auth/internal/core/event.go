@@ -0,0 +1,23 @@
+package core
+
+import "time"
+
+const (
+	EventRegister = "register"
+	EventLogin    = "login"
+	// включение/отключение 2FA и неверные коды второго фактора
+	EventTwoFactor = "2fa"
+)
+
+// AuthEvent запись журнала аутентификации (таблица auth_events)
+type AuthEvent struct {
+	ID        int64     `gorm:"primaryKey" json:"id"`
+	Type      string    `gorm:"size:32;not null;index:idx_auth_events_type_created" json:"type"`
+	Success   bool      `gorm:"not null" json:"success"`
+	Reason    string    `gorm:"size:64" json:"reason,omitempty"`
+	UserID    *int64    `gorm:"index" json:"userId,omitempty"`
+	Email     string    `gorm:"size:255;index" json:"email"`
+	IP        string    `gorm:"size:64;index" json:"ip"`
+	UserAgent string    `gorm:"size:255" json:"userAgent"`
+	CreatedAt time.Time `gorm:"index:idx_auth_events_type_created" json:"createdAt"`
+}
@@ -0,0 +1,62 @@
+package core
+
+import "time"
+
+const (
+	LockAccount = "account"
+	LockIP      = "ip"
+)
+
+// LoginFailure счётчик неудачных входов по email (LockAccount) или по IP (LockIP)
+type LoginFailure struct {
+	Kind          string     `gorm:"primaryKey;size:16" json:"kind"`
+	Key           string     `gorm:"primaryKey;size:255" json:"key"`
+	Failures      int        `gorm:"not null;default:0" json:"failures"`
+	LastFailureAt time.Time  `json:"lastFailureAt"`
+	LockedUntil   *time.Time `json:"lockedUntil,omitempty"`
+}
+
+func (f LoginFailure) Locked(now time.Time) bool {
+	return f.LockedUntil != nil && now.Before(*f.LockedUntil)
+}
+
+// LockoutPolicy после Threshold неудач подряд блокирует вход на Base,
+// каждая следующая неудача удваивает срок (не больше Max).
+// Счётчик сбрасывается, если с последней неудачи (или окончания блокировки) прошло больше Window.
+type LockoutPolicy struct {
+	Threshold int
+	Base      time.Duration
+	Max       time.Duration
+	Window    time.Duration
+}
+
+// Fail учитывает очередную неудачу
+func (p LockoutPolicy) Fail(f *LoginFailure, now time.Time) {
+	last := f.LastFailureAt
+	if f.LockedUntil != nil && f.LockedUntil.After(last) {
+		last = *f.LockedUntil
+	}
+	if now.Sub(last) > p.Window {
+		f.Failures = 0
+		f.LockedUntil = nil
+	}
+
+	f.Failures++
+	f.LastFailureAt = now
+	if p.Threshold <= 0 || f.Failures < p.Threshold {
+		return
+	}
+	until := now.Add(p.lockFor(f.Failures - p.Threshold))
+	f.LockedUntil = &until
+}
+
+func (p LockoutPolicy) lockFor(extra int) time.Duration {
+	d := p.Base
+	for i := 0; i < extra && d < p.Max; i++ {
+		d *= 2
+	}
+	if p.Max > 0 && d > p.Max {
+		d = p.Max
+	}
+	return d
+}
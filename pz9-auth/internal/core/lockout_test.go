@@ -0,0 +1,36 @@
+package core
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLockoutPolicy(t *testing.T) {
+	p := LockoutPolicy{Threshold: 3, Base: time.Minute, Max: 5 * time.Minute, Window: 15 * time.Minute}
+	now := time.Now()
+	var f LoginFailure
+
+	p.Fail(&f, now)
+	p.Fail(&f, now)
+	if f.Locked(now) {
+		t.Fatal("locked before threshold")
+	}
+
+	// срок блокировки растёт экспоненциально и ограничен Max
+	for _, want := range []time.Duration{time.Minute, 2 * time.Minute, 4 * time.Minute, 5 * time.Minute} {
+		p.Fail(&f, now)
+		if got := f.LockedUntil.Sub(now); got != want {
+			t.Fatalf("failure %d: want lock %s got %s", f.Failures, want, got)
+		}
+	}
+	if !f.Locked(now) || f.Locked(now.Add(5*time.Minute)) {
+		t.Fatal("unexpected lock state")
+	}
+
+	// после долгой паузы счёт начинается заново
+	later := f.LockedUntil.Add(p.Window + time.Second)
+	p.Fail(&f, later)
+	if f.Failures != 1 || f.Locked(later) {
+		t.Fatalf("counter not reset: %+v", f)
+	}
+}
@@ -0,0 +1,13 @@
+package core
+
+import "time"
+
+// RecoveryCode одноразовый резервный код входа при потере устройства с TOTP.
+// Хранится только SHA-256 кода.
+type RecoveryCode struct {
+	ID        int64  `gorm:"primaryKey"`
+	UserID    int64  `gorm:"index;not null"`
+	CodeHash  string `gorm:"uniqueIndex;size:64;not null"`
+	UsedAt    *time.Time
+	CreatedAt time.Time
+}
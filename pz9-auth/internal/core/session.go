@@ -0,0 +1,24 @@
+package core
+
+import "time"
+
+// Session серверная сессия. Клиент получает непрозрачный токен,
+// в БД хранится только его SHA-256.
+type Session struct {
+	ID         int64      `gorm:"primaryKey" json:"id"`
+	UserID     int64      `gorm:"index;not null" json:"-"`
+	TokenHash  string     `gorm:"uniqueIndex;size:64;not null" json:"-"`
+	IP         string     `gorm:"size:64" json:"ip"`
+	UserAgent  string     `gorm:"size:255" json:"userAgent"`
+	CreatedAt  time.Time  `json:"createdAt"`
+	LastSeenAt time.Time  `gorm:"not null" json:"lastSeenAt"`
+	ExpiresAt  time.Time  `gorm:"not null" json:"expiresAt"`
+	RevokedAt  *time.Time `json:"-"`
+	// сессия открыта с подтверждением вторым фактором
+	TwoFactor bool `gorm:"not null;default:false" json:"twoFactor"`
+}
+
+// Active сессия не отозвана и не истекла ни по простою, ни по абсолютному сроку
+func (s Session) Active(now time.Time, idle time.Duration) bool {
+	return s.RevokedAt == nil && now.Before(s.ExpiresAt) && now.Before(s.LastSeenAt.Add(idle))
+}
@@ -0,0 +1,27 @@
+package core
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSessionActive(t *testing.T) {
+	now := time.Now()
+	revoked := now.Add(-time.Second)
+	idle := 30 * time.Minute
+
+	cases := map[string]struct {
+		s    Session
+		want bool
+	}{
+		"fresh":            {Session{LastSeenAt: now, ExpiresAt: now.Add(time.Hour)}, true},
+		"idle timeout":     {Session{LastSeenAt: now.Add(-time.Hour), ExpiresAt: now.Add(time.Hour)}, false},
+		"absolute timeout": {Session{LastSeenAt: now, ExpiresAt: now.Add(-time.Second)}, false},
+		"revoked":          {Session{LastSeenAt: now, ExpiresAt: now.Add(time.Hour), RevokedAt: &revoked}, false},
+	}
+	for name, c := range cases {
+		if got := c.s.Active(now, idle); got != c.want {
+			t.Errorf("%s: want %v got %v", name, c.want, got)
+		}
+	}
+}
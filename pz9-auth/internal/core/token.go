@@ -0,0 +1,15 @@
+package core
+
+import "time"
+
+// OneTimeToken запись об одноразовом токене (подтверждение email, сброс пароля).
+// Хранится только хэш nonce, поэтому утечка таблицы не позволяет воспользоваться токенами.
+type OneTimeToken struct {
+	ID        int64     `gorm:"primaryKey"`
+	UserID    int64     `gorm:"index;not null"`
+	Purpose   string    `gorm:"size:32;not null"`
+	NonceHash string    `gorm:"uniqueIndex;size:64;not null"`
+	ExpiresAt time.Time `gorm:"not null"`
+	UsedAt    *time.Time
+	CreatedAt time.Time
+}
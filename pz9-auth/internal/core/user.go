@@ -2,10 +2,44 @@ package core
 
 import "time"
 
+const (
+	TwoFactorDisabled = "disabled"
+	// секрет выдан, но ещё не подтверждён кодом из приложения
+	TwoFactorPending = "pending"
+	TwoFactorEnabled = "enabled"
+)
+
 type User struct {
-	ID           int64     `gorm:"primaryKey" json:"id"`
-	Email        string    `gorm:"uniqueIndex;size:255;not null" json:"email"`
-	PasswordHash string    `gorm:"size:255;not null" json:"-"`
-	CreatedAt    time.Time `json:"createdAt"`
-	UpdatedAt    time.Time `json:"updatedAt"`
+	ID              int64      `gorm:"primaryKey" json:"id"`
+	Email           string     `gorm:"uniqueIndex;size:255;not null" json:"email"`
+	PasswordHash    string     `gorm:"size:255;not null" json:"-"`
+	EmailVerifiedAt *time.Time `json:"emailVerifiedAt"`
+
+	TOTPSecret         string     `gorm:"size:64" json:"-"`
+	TwoFactorEnabledAt *time.Time `json:"twoFactorEnabledAt,omitempty"`
+	// последний использованный шаг TOTP — защита от повторного ввода того же кода
+	TOTPLastStep int64 `gorm:"not null;default:0" json:"-"`
+
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+func (u User) EmailVerified() bool {
+	return u.EmailVerifiedAt != nil
+}
+
+func (u User) TwoFactorEnabled() bool {
+	return u.TwoFactorEnabledAt != nil
+}
+
+// TwoFactorStatus disabled | pending | enabled
+func (u User) TwoFactorStatus() string {
+	switch {
+	case u.TwoFactorEnabled():
+		return TwoFactorEnabled
+	case u.TOTPSecret != "":
+		return TwoFactorPending
+	default:
+		return TwoFactorDisabled
+	}
 }
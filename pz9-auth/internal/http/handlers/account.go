@@ -0,0 +1,250 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/icestormerrr/pz9-auth/internal/core"
+	"github.com/icestormerrr/pz9-auth/internal/mail"
+	"github.com/icestormerrr/pz9-auth/internal/repo"
+	"github.com/icestormerrr/pz9-auth/internal/tokens"
+)
+
+// issueToken выпускает подписанный токен и сохраняет хэш его nonce для однократного использования
+func (h *AuthHandler) issueToken(ctx context.Context, purpose string, userID int64, ttl time.Duration) (string, error) {
+	token, claims, err := h.Signer.Issue(purpose, userID, ttl)
+	if err != nil {
+		return "", err
+	}
+	rec := core.OneTimeToken{
+		UserID:    userID,
+		Purpose:   purpose,
+		NonceHash: tokens.HashNonce(claims.Nonce),
+		ExpiresAt: time.Unix(claims.ExpiresAt, 0),
+	}
+	if err := h.Tokens.Create(ctx, &rec); err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// consumeToken проверяет подпись и срок, затем атомарно гасит токен
+func (h *AuthHandler) consumeToken(ctx context.Context, token, purpose string) (int64, error) {
+	claims, err := h.Signer.Parse(token, purpose)
+	if err != nil {
+		return 0, err
+	}
+	if err := h.Tokens.Consume(ctx, purpose, claims.UserID, tokens.HashNonce(claims.Nonce)); err != nil {
+		return 0, err
+	}
+	return claims.UserID, nil
+}
+
+func (h *AuthHandler) sendVerification(ctx context.Context, u core.User) error {
+	token, err := h.issueToken(ctx, tokens.PurposeVerifyEmail, u.ID, h.VerifyTokenTTL)
+	if err != nil {
+		return err
+	}
+	return h.Mailer.Send(ctx, mail.Message{
+		To:      u.Email,
+		Subject: "Подтверждение email",
+		Body: fmt.Sprintf("Для подтверждения адреса отправьте токен в POST /auth/verify-email:\n%s\n\n"+
+			"Токен действителен %s.", token, h.VerifyTokenTTL),
+	})
+}
+
+func writeTokenErr(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, tokens.ErrExpired):
+		writeErr(w, http.StatusBadRequest, "token_expired")
+	case errors.Is(err, tokens.ErrInvalid), errors.Is(err, repo.ErrTokenUsed):
+		writeErr(w, http.StatusBadRequest, "invalid_token")
+	default:
+		writeErr(w, http.StatusInternalServerError, "db_error")
+	}
+}
+
+type tokenReq struct {
+	Token string `json:"token"`
+}
+
+func (h *AuthHandler) VerifyEmail(w http.ResponseWriter, r *http.Request) {
+	var in tokenReq
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil || in.Token == "" {
+		writeErr(w, http.StatusBadRequest, "token_required")
+		return
+	}
+
+	userID, err := h.consumeToken(r.Context(), in.Token, tokens.PurposeVerifyEmail)
+	if err != nil {
+		writeTokenErr(w, err)
+		return
+	}
+	if err := h.Users.MarkEmailVerified(r.Context(), userID); err != nil {
+		writeErr(w, http.StatusInternalServerError, "db_error")
+		return
+	}
+	writeJSON(w, http.StatusOK, authResp{Status: "ok"})
+}
+
+type forgotReq struct {
+	Email string `json:"email"`
+}
+
+// ForgotPassword всегда отвечает 202, чтобы по ответу нельзя было проверить существование email
+func (h *AuthHandler) ForgotPassword(w http.ResponseWriter, r *http.Request) {
+	var in forgotReq
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		writeErr(w, http.StatusBadRequest, "invalid_json")
+		return
+	}
+	in.Email = strings.TrimSpace(strings.ToLower(in.Email))
+	if in.Email == "" {
+		writeErr(w, http.StatusBadRequest, "email_required")
+		return
+	}
+
+	if u, err := h.Users.ByEmail(r.Context(), in.Email); err == nil {
+		if err := h.sendReset(r.Context(), u); err != nil {
+			log.Println("send reset:", err)
+		}
+	} else if !errors.Is(err, repo.ErrUserNotFound) {
+		log.Println("forgot password:", err)
+	}
+
+	writeJSON(w, http.StatusAccepted, authResp{Status: "ok"})
+}
+
+// ResendVerification повторно отправляет письмо подтверждения. Как и ForgotPassword, всегда отвечает 202;
+// для одного аккаунта письмо уходит не чаще раза в VerifyResendInterval.
+func (h *AuthHandler) ResendVerification(w http.ResponseWriter, r *http.Request) {
+	var in forgotReq
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		writeErr(w, http.StatusBadRequest, "invalid_json")
+		return
+	}
+	in.Email = strings.TrimSpace(strings.ToLower(in.Email))
+	if in.Email == "" {
+		writeErr(w, http.StatusBadRequest, "email_required")
+		return
+	}
+
+	if u, err := h.Users.ByEmail(r.Context(), in.Email); err == nil {
+		if err := h.resendVerification(r.Context(), u); err != nil {
+			log.Println("resend verification:", err)
+		}
+	} else if !errors.Is(err, repo.ErrUserNotFound) {
+		log.Println("resend verification:", err)
+	}
+
+	writeJSON(w, http.StatusAccepted, authResp{Status: "ok"})
+}
+
+func (h *AuthHandler) resendVerification(ctx context.Context, u core.User) error {
+	if u.EmailVerified() {
+		return nil
+	}
+	last, err := h.Tokens.LastIssuedAt(ctx, u.ID, tokens.PurposeVerifyEmail)
+	if err != nil {
+		return err
+	}
+	if time.Since(last) < h.VerifyResendInterval {
+		return nil
+	}
+	// действует только последнее письмо
+	if err := h.Tokens.RevokeAll(ctx, u.ID, tokens.PurposeVerifyEmail); err != nil {
+		return err
+	}
+	return h.sendVerification(ctx, u)
+}
+
+func (h *AuthHandler) sendReset(ctx context.Context, u core.User) error {
+	// действует только последняя ссылка на сброс
+	if err := h.Tokens.RevokeAll(ctx, u.ID, tokens.PurposeResetPassword); err != nil {
+		return err
+	}
+	token, err := h.issueToken(ctx, tokens.PurposeResetPassword, u.ID, h.ResetTokenTTL)
+	if err != nil {
+		return err
+	}
+	return h.Mailer.Send(ctx, mail.Message{
+		To:      u.Email,
+		Subject: "Сброс пароля",
+		Body: fmt.Sprintf("Для сброса пароля отправьте токен в POST /auth/reset-password:\n%s\n\n"+
+			"Токен действителен %s. Если вы не запрашивали сброс, просто проигнорируйте письмо.", token, h.ResetTokenTTL),
+	})
+}
+
+type resetReq struct {
+	Token    string `json:"token"`
+	Password string `json:"password"`
+}
+
+func (h *AuthHandler) ResetPassword(w http.ResponseWriter, r *http.Request) {
+	var in resetReq
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		writeErr(w, http.StatusBadRequest, "invalid_json")
+		return
+	}
+	if in.Token == "" {
+		writeErr(w, http.StatusBadRequest, "token_required")
+		return
+	}
+
+	// сначала проверяем подпись и пароль, и только потом гасим токен,
+	// чтобы слабый пароль не «сжигал» ссылку из письма
+	claims, err := h.Signer.Parse(in.Token, tokens.PurposeResetPassword)
+	if err != nil {
+		writeTokenErr(w, err)
+		return
+	}
+	u, err := h.Users.ByID(r.Context(), claims.UserID)
+	if errors.Is(err, repo.ErrUserNotFound) {
+		writeErr(w, http.StatusBadRequest, "invalid_token")
+		return
+	}
+	if err != nil {
+		writeErr(w, http.StatusInternalServerError, "db_error")
+		return
+	}
+	if !h.checkPassword(w, r, in.Password, u.Email) {
+		return
+	}
+
+	hash, err := h.Passwords.Hash(in.Password)
+	if err != nil {
+		writeErr(w, http.StatusInternalServerError, "hash_failed")
+		return
+	}
+
+	userID := u.ID
+	if err := h.Tokens.Consume(r.Context(), tokens.PurposeResetPassword, claims.UserID, tokens.HashNonce(claims.Nonce)); err != nil {
+		writeTokenErr(w, err)
+		return
+	}
+	if err := h.Users.UpdatePassword(r.Context(), userID, hash); err != nil {
+		writeErr(w, http.StatusInternalServerError, "db_error")
+		return
+	}
+	// после смены пароля все существующие сессии становятся недействительными
+	if _, err := h.Sessions.RevokeAll(r.Context(), userID, 0); err != nil {
+		log.Println("revoke sessions:", err)
+	}
+	// токен из письма подтверждает владение адресом
+	if err := h.Users.MarkEmailVerified(r.Context(), userID); err != nil {
+		log.Println("mark verified:", err)
+	}
+	// владелец подтвердил себя через почту — блокировка, вызванная чужим перебором, больше не нужна
+	if h.Lockouts != nil {
+		if _, err := h.Lockouts.Reset(r.Context(), core.LockAccount, u.Email); err != nil {
+			log.Println("lockout:", err)
+		}
+	}
+	writeJSON(w, http.StatusOK, authResp{Status: "ok"})
+}
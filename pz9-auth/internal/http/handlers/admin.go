@@ -0,0 +1,132 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/icestormerrr/pz9-auth/internal/core"
+	"github.com/icestormerrr/pz9-auth/internal/repo"
+)
+
+type AdminHandler struct {
+	Users    *repo.UserRepo
+	Lockouts *repo.LockoutRepo
+	Events   *repo.EventRepo
+}
+
+const (
+	defaultEventsLimit = 50
+	maxEventsLimit     = 500
+)
+
+// AuthEvents журнал аутентификации с фильтрами:
+// ?userId=&email=&ip=&type=register|login&success=true|false&since=&until=&before=&limit=
+func (h *AdminHandler) AuthEvents(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	f := repo.EventFilter{
+		Email: q.Get("email"),
+		IP:    q.Get("ip"),
+		Type:  q.Get("type"),
+		Limit: defaultEventsLimit,
+	}
+
+	var err error
+	if v := q.Get("userId"); v != "" {
+		if f.UserID, err = strconv.ParseInt(v, 10, 64); err != nil {
+			writeErr(w, http.StatusBadRequest, "invalid_user_id")
+			return
+		}
+	}
+	if v := q.Get("before"); v != "" {
+		if f.BeforeID, err = strconv.ParseInt(v, 10, 64); err != nil {
+			writeErr(w, http.StatusBadRequest, "invalid_before")
+			return
+		}
+	}
+	if v := q.Get("success"); v != "" {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			writeErr(w, http.StatusBadRequest, "invalid_success")
+			return
+		}
+		f.Success = &b
+	}
+	if v := q.Get("since"); v != "" {
+		if f.Since, err = time.Parse(time.RFC3339, v); err != nil {
+			writeErr(w, http.StatusBadRequest, "invalid_since")
+			return
+		}
+	}
+	if v := q.Get("until"); v != "" {
+		if f.Until, err = time.Parse(time.RFC3339, v); err != nil {
+			writeErr(w, http.StatusBadRequest, "invalid_until")
+			return
+		}
+	}
+	if v := q.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			writeErr(w, http.StatusBadRequest, "invalid_limit")
+			return
+		}
+		f.Limit = min(n, maxEventsLimit)
+	}
+
+	events, err := h.Events.List(r.Context(), f)
+	if err != nil {
+		writeErr(w, http.StatusInternalServerError, "db_error")
+		return
+	}
+	resp := map[string]any{"items": events}
+	if len(events) == f.Limit {
+		resp["nextBefore"] = events[len(events)-1].ID
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// ListLockouts действующие блокировки аккаунтов и IP
+func (h *AdminHandler) ListLockouts(w http.ResponseWriter, r *http.Request) {
+	list, err := h.Lockouts.Locked(r.Context())
+	if err != nil {
+		writeErr(w, http.StatusInternalServerError, "db_error")
+		return
+	}
+	writeJSON(w, http.StatusOK, list)
+}
+
+// UnlockUser снимает блокировку входа с аккаунта
+func (h *AdminHandler) UnlockUser(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		writeErr(w, http.StatusBadRequest, "invalid_id")
+		return
+	}
+	u, err := h.Users.ByID(r.Context(), id)
+	if errors.Is(err, repo.ErrUserNotFound) {
+		writeErr(w, http.StatusNotFound, "not_found")
+		return
+	}
+	if err != nil {
+		writeErr(w, http.StatusInternalServerError, "db_error")
+		return
+	}
+	h.unlock(w, r, core.LockAccount, u.Email)
+}
+
+// UnlockIP снимает блокировку входа с IP-адреса
+func (h *AdminHandler) UnlockIP(w http.ResponseWriter, r *http.Request) {
+	h.unlock(w, r, core.LockIP, chi.URLParam(r, "ip"))
+}
+
+func (h *AdminHandler) unlock(w http.ResponseWriter, r *http.Request, kind, key string) {
+	found, err := h.Lockouts.Reset(r.Context(), kind, key)
+	if err != nil {
+		writeErr(w, http.StatusInternalServerError, "db_error")
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"status": "ok", "unlocked": found})
+}
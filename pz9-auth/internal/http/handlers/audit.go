@@ -0,0 +1,86 @@
+package handlers
+
+import (
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/icestormerrr/pz9-auth/internal/core"
+)
+
+// audit пишет событие в auth_events; сбой журнала не должен ломать вход
+func (h *AuthHandler) audit(r *http.Request, typ string, success bool, userID int64, email, reason string) {
+	if h.Events == nil {
+		return
+	}
+	e := core.AuthEvent{
+		Type:      typ,
+		Success:   success,
+		Reason:    reason,
+		Email:     email,
+		IP:        clientIP(r),
+		UserAgent: truncate(r.UserAgent(), 255),
+	}
+	if userID != 0 {
+		e.UserID = &userID
+	}
+	if err := h.Events.Create(r.Context(), &e); err != nil {
+		log.Println("audit:", err)
+	}
+}
+
+// lockedUntil момент окончания самой долгой из действующих блокировок (по email и по IP)
+func (h *AuthHandler) lockedUntil(r *http.Request, email string) (time.Time, error) {
+	var until time.Time
+	if h.Lockouts == nil {
+		return until, nil
+	}
+	now := time.Now()
+	for _, k := range [][2]string{{core.LockAccount, email}, {core.LockIP, clientIP(r)}} {
+		f, err := h.Lockouts.Get(r.Context(), k[0], k[1])
+		if err != nil {
+			return until, err
+		}
+		if f.Locked(now) && f.LockedUntil.After(until) {
+			until = *f.LockedUntil
+		}
+	}
+	return until, nil
+}
+
+// loginFailed учитывает неудачу и в счётчике аккаунта, и в счётчике IP.
+// Счётчик аккаунта ведётся и для несуществующих email, чтобы блокировка не выдавала их наличие.
+func (h *AuthHandler) loginFailed(r *http.Request, email string) {
+	if h.Lockouts == nil {
+		return
+	}
+	if _, err := h.Lockouts.Fail(r.Context(), core.LockAccount, email, h.AccountLockout); err != nil {
+		log.Println("lockout:", err)
+	}
+	if _, err := h.Lockouts.Fail(r.Context(), core.LockIP, clientIP(r), h.IPLockout); err != nil {
+		log.Println("lockout:", err)
+	}
+}
+
+// loginSucceeded сбрасывает счётчик аккаунта после полного входа (с 2FA — только после кода,
+// иначе знающий пароль мог бы обнулять счётчик между попытками подбора кода).
+// Счётчик IP не сбрасывается: перебор по многим аккаунтам обнулялся бы одним своим входом.
+func (h *AuthHandler) loginSucceeded(r *http.Request, email string) {
+	if h.Lockouts == nil {
+		return
+	}
+	if _, err := h.Lockouts.Reset(r.Context(), core.LockAccount, email); err != nil {
+		log.Println("lockout:", err)
+	}
+}
+
+func writeLocked(w http.ResponseWriter, until time.Time) {
+	secs := int(math.Ceil(time.Until(until).Seconds()))
+	if secs < 1 {
+		secs = 1
+	}
+	w.Header().Set("Retry-After", strconv.Itoa(secs))
+	writeJSON(w, http.StatusTooManyRequests, map[string]any{"error": "too_many_attempts", "retryAfter": secs})
+}
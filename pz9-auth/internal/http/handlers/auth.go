@@ -1,20 +1,46 @@
 package handlers
 
 import (
-	"context"
 	"encoding/json"
 	"errors"
+	"log"
+	"net"
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/icestormerrr/pz9-auth/internal/core"
+	"github.com/icestormerrr/pz9-auth/internal/http/middleware"
+	"github.com/icestormerrr/pz9-auth/internal/mail"
+	"github.com/icestormerrr/pz9-auth/internal/password"
 	"github.com/icestormerrr/pz9-auth/internal/repo"
-	"golang.org/x/crypto/bcrypt"
+	"github.com/icestormerrr/pz9-auth/internal/tokens"
 )
 
 type AuthHandler struct {
-	Users      *repo.UserRepo
-	BcryptCost int
+	Users     *repo.UserRepo
+	Sessions  *repo.SessionRepo
+	Tokens    *repo.TokenRepo
+	Signer    *tokens.Signer
+	Mailer    mail.Mailer
+	Passwords *password.Hasher
+	Policy    password.Policy
+	Breach    password.BreachChecker
+	Lockouts  *repo.LockoutRepo
+	Events    *repo.EventRepo
+	Recovery  *repo.RecoveryRepo
+
+	AccountLockout core.LockoutPolicy
+	IPLockout      core.LockoutPolicy
+
+	VerifyTokenTTL           time.Duration
+	VerifyResendInterval     time.Duration
+	ResetTokenTTL            time.Duration
+	RequireEmailVerification bool
+	SessionIdleTimeout       time.Duration
+	SessionAbsoluteTimeout   time.Duration
+	TOTPIssuer               string
+	TwoFactorChallengeTTL    time.Duration
 }
 
 type registerReq struct {
@@ -23,8 +49,11 @@ type registerReq struct {
 }
 
 type authResp struct {
-	Status string      `json:"status"`
-	User   interface{} `json:"user,omitempty"`
+	Status    string      `json:"status"`
+	User      interface{} `json:"user,omitempty"`
+	Token     string      `json:"token,omitempty"`
+	Challenge string      `json:"challenge,omitempty"`
+	ExpiresAt *time.Time  `json:"expiresAt,omitempty"`
 }
 
 func (h *AuthHandler) Register(w http.ResponseWriter, r *http.Request) {
@@ -34,22 +63,26 @@ func (h *AuthHandler) Register(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	in.Email = strings.TrimSpace(strings.ToLower(in.Email))
-	if in.Email == "" || len(in.Password) < 8 {
-		writeErr(w, http.StatusBadRequest, "email_required_and_password_min_8")
+	if in.Email == "" {
+		writeErr(w, http.StatusBadRequest, "email_required")
+		return
+	}
+	if !h.checkPassword(w, r, in.Password, in.Email) {
+		h.audit(r, core.EventRegister, false, 0, in.Email, "weak_password")
 		return
 	}
 
-	// bcrypt hash
-	hash, err := bcrypt.GenerateFromPassword([]byte(in.Password), h.BcryptCost)
+	hash, err := h.Passwords.Hash(in.Password)
 	if err != nil {
 		writeErr(w, http.StatusInternalServerError, "hash_failed")
 		return
 	}
 
-	u := core.User{Email: in.Email, PasswordHash: string(hash)}
+	u := core.User{Email: in.Email, PasswordHash: hash}
 	if err := h.Users.Create(r.Context(), &u); err != nil {
 
 		if errors.Is(err, repo.ErrEmailTaken) {
+			h.audit(r, core.EventRegister, false, 0, in.Email, "email_taken")
 			writeErr(w, http.StatusConflict, "email_taken")
 			return
 		}
@@ -57,9 +90,15 @@ func (h *AuthHandler) Register(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// аккаунт активируется только после перехода по ссылке из письма
+	if err := h.sendVerification(r.Context(), u); err != nil {
+		log.Println("send verification:", err)
+	}
+	h.audit(r, core.EventRegister, true, u.ID, u.Email, "")
+
 	writeJSON(w, http.StatusCreated, authResp{
 		Status: "ok",
-		User:   map[string]any{"id": u.ID, "email": u.Email},
+		User:   map[string]any{"id": u.ID, "email": u.Email, "emailVerified": false},
 	})
 }
 
@@ -80,23 +119,115 @@ func (h *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	u, err := h.Users.ByEmail(context.Background(), in.Email)
+	// во время блокировки пароль не проверяем вовсе, даже верный
+	until, err := h.lockedUntil(r, in.Email)
 	if err != nil {
+		writeErr(w, http.StatusInternalServerError, "db_error")
+		return
+	}
+	if !until.IsZero() {
+		h.audit(r, core.EventLogin, false, 0, in.Email, "locked")
+		writeLocked(w, until)
+		return
+	}
+
+	u, err := h.Users.ByEmail(r.Context(), in.Email)
+	if err != nil {
+		h.loginFailed(r, in.Email)
+		h.audit(r, core.EventLogin, false, 0, in.Email, "unknown_email")
 		// не раскрываем, что именно не так
 		writeErr(w, http.StatusUnauthorized, "invalid_credentials")
 		return
 	}
 
-	if bcrypt.CompareHashAndPassword([]byte(u.PasswordHash), []byte(in.Password)) != nil {
+	needsRehash, err := h.Passwords.Verify(u.PasswordHash, in.Password)
+	if err != nil {
+		h.loginFailed(r, in.Email)
+		h.audit(r, core.EventLogin, false, u.ID, in.Email, "invalid_password")
 		writeErr(w, http.StatusUnauthorized, "invalid_credentials")
 		return
 	}
+	// пароль известен только в момент входа — пересчитываем хэш под текущие настройки
+	if needsRehash {
+		if hash, err := h.Passwords.Hash(in.Password); err == nil {
+			if err := h.Users.UpdatePassword(r.Context(), u.ID, hash); err != nil {
+				log.Println("rehash:", err)
+			}
+		}
+	}
 
-	// В ПЗ10 здесь будет генерация JWT; пока просто ok
+	if h.RequireEmailVerification && !u.EmailVerified() {
+		h.audit(r, core.EventLogin, false, u.ID, u.Email, "email_not_verified")
+		writeErr(w, http.StatusForbidden, "email_not_verified")
+		return
+	}
+
+	// с включённой 2FA пароль даёт только вызов, который завершается в /auth/2fa/verify
+	if u.TwoFactorEnabled() {
+		h.startChallenge(w, r, u)
+		return
+	}
+
+	if h.startSession(w, r, u, false) {
+		h.loginSucceeded(r, u.Email)
+		h.audit(r, core.EventLogin, true, u.ID, u.Email, "")
+	}
+}
+
+// startSession создаёт серверную сессию и отдаёт токен в теле ответа и в HttpOnly cookie;
+// false означает, что ответ с ошибкой уже записан
+func (h *AuthHandler) startSession(w http.ResponseWriter, r *http.Request, u core.User, twoFactor bool) bool {
+	now := time.Now()
+	s := core.Session{
+		UserID:     u.ID,
+		IP:         clientIP(r),
+		UserAgent:  truncate(r.UserAgent(), 255),
+		LastSeenAt: now,
+		ExpiresAt:  now.Add(h.SessionAbsoluteTimeout),
+		TwoFactor:  twoFactor,
+	}
+	token, err := h.Sessions.Create(r.Context(), &s)
+	if err != nil {
+		writeErr(w, http.StatusInternalServerError, "db_error")
+		return false
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     middleware.SessionCookie,
+		Value:    token,
+		Path:     "/",
+		Expires:  s.ExpiresAt,
+		HttpOnly: true,
+		Secure:   r.TLS != nil,
+		SameSite: http.SameSiteLaxMode,
+	})
 	writeJSON(w, http.StatusOK, authResp{
-		Status: "ok",
-		User:   map[string]any{"id": u.ID, "email": u.Email},
+		Status:    "ok",
+		User:      map[string]any{"id": u.ID, "email": u.Email},
+		Token:     token,
+		ExpiresAt: &s.ExpiresAt,
 	})
+	return true
+}
+
+// checkPassword проверяет пароль политикой и по базе утечек; при нарушении сам пишет ответ 400
+func (h *AuthHandler) checkPassword(w http.ResponseWriter, r *http.Request, pw, email string) bool {
+	violations := h.Policy.Validate(pw, email)
+	if len(violations) == 0 && h.Breach != nil {
+		breached, err := h.Breach.Breached(r.Context(), pw)
+		if err != nil {
+			// недоступность сервиса утечек не должна блокировать регистрацию
+			log.Println("breach check:", err)
+		}
+		if breached {
+			violations = append(violations, "breached")
+		}
+	}
+	if len(violations) > 0 {
+		writeJSON(w, http.StatusBadRequest, map[string]any{"error": "weak_password", "violations": violations})
+		return false
+	}
+	return true
 }
 
 // helpers
@@ -109,3 +240,18 @@ func writeJSON(w http.ResponseWriter, code int, v any) {
 func writeErr(w http.ResponseWriter, code int, msg string) {
 	writeJSON(w, code, map[string]string{"error": msg})
 }
+
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n]
+}
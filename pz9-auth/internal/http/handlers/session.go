@@ -0,0 +1,112 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/icestormerrr/pz9-auth/internal/core"
+	"github.com/icestormerrr/pz9-auth/internal/http/middleware"
+	"github.com/icestormerrr/pz9-auth/internal/repo"
+)
+
+func (h *AuthHandler) Logout(w http.ResponseWriter, r *http.Request) {
+	s, _ := middleware.SessionFrom(r.Context())
+	if err := h.Sessions.Revoke(r.Context(), s.UserID, s.ID); err != nil && !errors.Is(err, repo.ErrSessionNotFound) {
+		writeErr(w, http.StatusInternalServerError, "db_error")
+		return
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     middleware.SessionCookie,
+		Value:    "",
+		Path:     "/",
+		Expires:  time.Unix(0, 0),
+		MaxAge:   -1,
+		HttpOnly: true,
+	})
+	writeJSON(w, http.StatusOK, authResp{Status: "ok"})
+}
+
+func (h *AuthHandler) Me(w http.ResponseWriter, r *http.Request) {
+	s, _ := middleware.SessionFrom(r.Context())
+	u, err := h.Users.ByID(r.Context(), s.UserID)
+	if errors.Is(err, repo.ErrUserNotFound) {
+		writeErr(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+	if err != nil {
+		writeErr(w, http.StatusInternalServerError, "db_error")
+		return
+	}
+	writeJSON(w, http.StatusOK, struct {
+		core.User
+		TwoFactor string `json:"twoFactor"`
+	}{u, u.TwoFactorStatus()})
+}
+
+type sessionResp struct {
+	ID         int64     `json:"id"`
+	IP         string    `json:"ip"`
+	UserAgent  string    `json:"userAgent"`
+	CreatedAt  time.Time `json:"createdAt"`
+	LastSeenAt time.Time `json:"lastSeenAt"`
+	ExpiresAt  time.Time `json:"expiresAt"`
+	TwoFactor  bool      `json:"twoFactor"`
+	Current    bool      `json:"current"`
+}
+
+func (h *AuthHandler) ListSessions(w http.ResponseWriter, r *http.Request) {
+	cur, _ := middleware.SessionFrom(r.Context())
+	list, err := h.Sessions.ActiveByUser(r.Context(), cur.UserID, h.SessionIdleTimeout)
+	if err != nil {
+		writeErr(w, http.StatusInternalServerError, "db_error")
+		return
+	}
+
+	out := make([]sessionResp, 0, len(list))
+	for _, s := range list {
+		out = append(out, sessionResp{
+			ID:         s.ID,
+			IP:         s.IP,
+			UserAgent:  s.UserAgent,
+			CreatedAt:  s.CreatedAt,
+			LastSeenAt: s.LastSeenAt,
+			ExpiresAt:  s.ExpiresAt,
+			TwoFactor:  s.TwoFactor,
+			Current:    s.ID == cur.ID,
+		})
+	}
+	writeJSON(w, http.StatusOK, out)
+}
+
+func (h *AuthHandler) RevokeSession(w http.ResponseWriter, r *http.Request) {
+	cur, _ := middleware.SessionFrom(r.Context())
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		writeErr(w, http.StatusBadRequest, "invalid_id")
+		return
+	}
+	// чужие сессии для пользователя выглядят так же, как несуществующие
+	if err := h.Sessions.Revoke(r.Context(), cur.UserID, id); errors.Is(err, repo.ErrSessionNotFound) {
+		writeErr(w, http.StatusNotFound, "not_found")
+		return
+	} else if err != nil {
+		writeErr(w, http.StatusInternalServerError, "db_error")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// RevokeOtherSessions завершает все сессии пользователя, кроме текущей
+func (h *AuthHandler) RevokeOtherSessions(w http.ResponseWriter, r *http.Request) {
+	cur, _ := middleware.SessionFrom(r.Context())
+	n, err := h.Sessions.RevokeAll(r.Context(), cur.UserID, cur.ID)
+	if err != nil {
+		writeErr(w, http.StatusInternalServerError, "db_error")
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"status": "ok", "revoked": n})
+}
@@ -0,0 +1,377 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"encoding/base32"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/icestormerrr/pz9-auth/internal/core"
+	"github.com/icestormerrr/pz9-auth/internal/http/middleware"
+	"github.com/icestormerrr/pz9-auth/internal/repo"
+	"github.com/icestormerrr/pz9-auth/internal/tokens"
+	"github.com/icestormerrr/pz9-auth/internal/totp"
+)
+
+const (
+	recoveryCodeCount = 10
+	// сколько соседних 30-секундных окон принимаем из-за расхождения часов
+	totpSkew = 1
+	qrSize   = 256
+)
+
+var errInvalidCode = errors.New("invalid second factor code")
+
+// startChallenge выдаёт одноразовый вызов второго шага входа
+func (h *AuthHandler) startChallenge(w http.ResponseWriter, r *http.Request, u core.User) {
+	challenge, err := h.issueToken(r.Context(), tokens.PurposeTwoFactor, u.ID, h.TwoFactorChallengeTTL)
+	if err != nil {
+		writeErr(w, http.StatusInternalServerError, "db_error")
+		return
+	}
+	expires := time.Now().Add(h.TwoFactorChallengeTTL)
+	writeJSON(w, http.StatusOK, authResp{Status: "2fa_required", Challenge: challenge, ExpiresAt: &expires})
+}
+
+type secondFactorReq struct {
+	Challenge    string `json:"challenge"`
+	Password     string `json:"password"`
+	Code         string `json:"code"`
+	RecoveryCode string `json:"recoveryCode"`
+}
+
+// checkSecondFactor проверяет TOTP или резервный код
+func (h *AuthHandler) checkSecondFactor(r *http.Request, u core.User, code, recovery string) error {
+	if recovery != "" {
+		if err := h.Recovery.Use(r.Context(), u.ID, recovery); errors.Is(err, repo.ErrRecoveryCodeInvalid) {
+			return errInvalidCode
+		} else if err != nil {
+			return err
+		}
+		return nil
+	}
+
+	step, ok := totp.Validate(u.TOTPSecret, code, time.Now(), totpSkew, u.TOTPLastStep)
+	if !ok {
+		return errInvalidCode
+	}
+	if err := h.Users.UseTOTPStep(r.Context(), u.ID, step); errors.Is(err, repo.ErrCodeReused) {
+		return errInvalidCode
+	} else if err != nil {
+		return err
+	}
+	return nil
+}
+
+// requireSecondFactor проверяет код с учётом блокировки перебора;
+// при ошибке сам пишет ответ и возвращает false
+func (h *AuthHandler) requireSecondFactor(w http.ResponseWriter, r *http.Request, u core.User, in secondFactorReq) bool {
+	if in.Code == "" && in.RecoveryCode == "" {
+		writeErr(w, http.StatusBadRequest, "code_required")
+		return false
+	}
+	until, err := h.lockedUntil(r, u.Email)
+	if err != nil {
+		writeErr(w, http.StatusInternalServerError, "db_error")
+		return false
+	}
+	if !until.IsZero() {
+		writeLocked(w, until)
+		return false
+	}
+
+	err = h.checkSecondFactor(r, u, in.Code, in.RecoveryCode)
+	if errors.Is(err, errInvalidCode) {
+		// 10^6 вариантов перебираются быстро, поэтому неверный код считается неудачным входом
+		h.loginFailed(r, u.Email)
+		h.audit(r, core.EventTwoFactor, false, u.ID, u.Email, "invalid_code")
+		writeErr(w, http.StatusUnauthorized, "invalid_code")
+		return false
+	}
+	if err != nil {
+		writeErr(w, http.StatusInternalServerError, "db_error")
+		return false
+	}
+	return true
+}
+
+// VerifyTwoFactor второй шаг входа: вызов из Login + код из приложения или резервный код
+func (h *AuthHandler) VerifyTwoFactor(w http.ResponseWriter, r *http.Request) {
+	var in secondFactorReq
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		writeErr(w, http.StatusBadRequest, "invalid_json")
+		return
+	}
+	if in.Challenge == "" {
+		writeErr(w, http.StatusBadRequest, "challenge_required")
+		return
+	}
+
+	claims, err := h.Signer.Parse(in.Challenge, tokens.PurposeTwoFactor)
+	if err != nil {
+		writeTokenErr(w, err)
+		return
+	}
+	u, err := h.Users.ByID(r.Context(), claims.UserID)
+	if errors.Is(err, repo.ErrUserNotFound) {
+		writeErr(w, http.StatusBadRequest, "invalid_token")
+		return
+	}
+	if err != nil {
+		writeErr(w, http.StatusInternalServerError, "db_error")
+		return
+	}
+	if !u.TwoFactorEnabled() {
+		writeErr(w, http.StatusBadRequest, "invalid_token")
+		return
+	}
+	if !h.requireSecondFactor(w, r, u, in) {
+		return
+	}
+	// вызов гасим только после верного кода, чтобы опечатка не заставляла вводить пароль заново
+	if err := h.Tokens.Consume(r.Context(), tokens.PurposeTwoFactor, claims.UserID, tokens.HashNonce(claims.Nonce)); err != nil {
+		writeTokenErr(w, err)
+		return
+	}
+
+	reason := ""
+	if in.RecoveryCode != "" {
+		reason = "recovery_code"
+	}
+	if h.startSession(w, r, u, true) {
+		h.loginSucceeded(r, u.Email)
+		h.audit(r, core.EventLogin, true, u.ID, u.Email, reason)
+	}
+}
+
+// sessionUser пользователь текущей сессии; при ошибке пишет ответ
+func (h *AuthHandler) sessionUser(w http.ResponseWriter, r *http.Request) (core.Session, core.User, bool) {
+	s, _ := middleware.SessionFrom(r.Context())
+	u, err := h.Users.ByID(r.Context(), s.UserID)
+	if errors.Is(err, repo.ErrUserNotFound) {
+		writeErr(w, http.StatusUnauthorized, "unauthorized")
+		return s, u, false
+	}
+	if err != nil {
+		writeErr(w, http.StatusInternalServerError, "db_error")
+		return s, u, false
+	}
+	return s, u, true
+}
+
+func (h *AuthHandler) TwoFactorStatus(w http.ResponseWriter, r *http.Request) {
+	_, u, ok := h.sessionUser(w, r)
+	if !ok {
+		return
+	}
+	resp := map[string]any{"status": u.TwoFactorStatus()}
+	if u.TwoFactorEnabled() {
+		n, err := h.Recovery.Remaining(r.Context(), u.ID)
+		if err != nil {
+			writeErr(w, http.StatusInternalServerError, "db_error")
+			return
+		}
+		resp["recoveryCodesRemaining"] = n
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// SetupTwoFactor выдаёт новый секрет; 2FA включится только после ConfirmTwoFactor
+func (h *AuthHandler) SetupTwoFactor(w http.ResponseWriter, r *http.Request) {
+	_, u, ok := h.sessionUser(w, r)
+	if !ok {
+		return
+	}
+	if u.TwoFactorEnabled() {
+		writeErr(w, http.StatusConflict, "two_factor_enabled")
+		return
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		writeErr(w, http.StatusInternalServerError, "secret_failed")
+		return
+	}
+	if err := h.Users.SetTOTPSecret(r.Context(), u.ID, secret); errors.Is(err, repo.ErrTwoFactorEnabled) {
+		writeErr(w, http.StatusConflict, "two_factor_enabled")
+		return
+	} else if err != nil {
+		writeErr(w, http.StatusInternalServerError, "db_error")
+		return
+	}
+
+	uri := totp.URI(h.TOTPIssuer, u.Email, secret)
+	png, err := totp.QRCode(uri, qrSize)
+	if err != nil {
+		writeErr(w, http.StatusInternalServerError, "qr_failed")
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"secret": secret,
+		"uri":    uri,
+		"qrCode": "data:image/png;base64," + base64.StdEncoding.EncodeToString(png),
+	})
+}
+
+// TwoFactorQR QR-код неподтверждённого секрета в виде PNG
+func (h *AuthHandler) TwoFactorQR(w http.ResponseWriter, r *http.Request) {
+	_, u, ok := h.sessionUser(w, r)
+	if !ok {
+		return
+	}
+	// после включения секрет больше не показывается
+	if u.TwoFactorStatus() != core.TwoFactorPending {
+		writeErr(w, http.StatusNotFound, "not_found")
+		return
+	}
+	png, err := totp.QRCode(totp.URI(h.TOTPIssuer, u.Email, u.TOTPSecret), qrSize)
+	if err != nil {
+		writeErr(w, http.StatusInternalServerError, "qr_failed")
+		return
+	}
+	w.Header().Set("Content-Type", "image/png")
+	w.Header().Set("Cache-Control", "no-store")
+	_, _ = w.Write(png)
+}
+
+// ConfirmTwoFactor включает 2FA по первому коду из приложения и выдаёт резервные коды
+func (h *AuthHandler) ConfirmTwoFactor(w http.ResponseWriter, r *http.Request) {
+	s, u, ok := h.sessionUser(w, r)
+	if !ok {
+		return
+	}
+	var in secondFactorReq
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil || in.Code == "" {
+		writeErr(w, http.StatusBadRequest, "code_required")
+		return
+	}
+	switch u.TwoFactorStatus() {
+	case core.TwoFactorEnabled:
+		writeErr(w, http.StatusConflict, "two_factor_enabled")
+		return
+	case core.TwoFactorDisabled:
+		writeErr(w, http.StatusBadRequest, "setup_required")
+		return
+	}
+
+	step, valid := totp.Validate(u.TOTPSecret, in.Code, time.Now(), totpSkew, 0)
+	if !valid {
+		writeErr(w, http.StatusBadRequest, "invalid_code")
+		return
+	}
+	if err := h.Users.EnableTwoFactor(r.Context(), u.ID, step); errors.Is(err, repo.ErrTwoFactorEnabled) {
+		writeErr(w, http.StatusConflict, "two_factor_enabled")
+		return
+	} else if err != nil {
+		writeErr(w, http.StatusInternalServerError, "db_error")
+		return
+	}
+
+	codes, err := h.replaceRecoveryCodes(r, u.ID)
+	if err != nil {
+		writeErr(w, http.StatusInternalServerError, "db_error")
+		return
+	}
+	// текущая сессия только что подтвердила владение устройством
+	if err := h.Sessions.MarkTwoFactor(r.Context(), s.ID); err != nil {
+		log.Println("mark session:", err)
+	}
+	h.audit(r, core.EventTwoFactor, true, u.ID, u.Email, "enabled")
+	writeJSON(w, http.StatusOK, map[string]any{"status": "ok", "recoveryCodes": codes})
+}
+
+// DisableTwoFactor требует пароль и действующий код (или резервный код)
+func (h *AuthHandler) DisableTwoFactor(w http.ResponseWriter, r *http.Request) {
+	_, u, ok := h.sessionUser(w, r)
+	if !ok {
+		return
+	}
+	var in secondFactorReq
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		writeErr(w, http.StatusBadRequest, "invalid_json")
+		return
+	}
+	if u.TwoFactorStatus() == core.TwoFactorDisabled {
+		writeErr(w, http.StatusConflict, "two_factor_disabled")
+		return
+	}
+	if _, err := h.Passwords.Verify(u.PasswordHash, in.Password); err != nil {
+		writeErr(w, http.StatusUnauthorized, "invalid_credentials")
+		return
+	}
+	// неподтверждённую настройку можно отменить одним паролем
+	if u.TwoFactorEnabled() && !h.requireSecondFactor(w, r, u, in) {
+		return
+	}
+
+	if err := h.Users.DisableTwoFactor(r.Context(), u.ID); err != nil {
+		writeErr(w, http.StatusInternalServerError, "db_error")
+		return
+	}
+	if err := h.Recovery.DeleteAll(r.Context(), u.ID); err != nil {
+		log.Println("delete recovery codes:", err)
+	}
+	h.audit(r, core.EventTwoFactor, true, u.ID, u.Email, "disabled")
+	writeJSON(w, http.StatusOK, authResp{Status: "ok"})
+}
+
+// RegenerateRecoveryCodes выдаёт новый набор резервных кодов, старые перестают действовать
+func (h *AuthHandler) RegenerateRecoveryCodes(w http.ResponseWriter, r *http.Request) {
+	_, u, ok := h.sessionUser(w, r)
+	if !ok {
+		return
+	}
+	var in secondFactorReq
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		writeErr(w, http.StatusBadRequest, "invalid_json")
+		return
+	}
+	if !u.TwoFactorEnabled() {
+		writeErr(w, http.StatusConflict, "two_factor_disabled")
+		return
+	}
+	// резервным кодом нельзя выпустить новые резервные коды
+	in.RecoveryCode = ""
+	if !h.requireSecondFactor(w, r, u, in) {
+		return
+	}
+
+	codes, err := h.replaceRecoveryCodes(r, u.ID)
+	if err != nil {
+		writeErr(w, http.StatusInternalServerError, "db_error")
+		return
+	}
+	h.audit(r, core.EventTwoFactor, true, u.ID, u.Email, "recovery_codes_regenerated")
+	writeJSON(w, http.StatusOK, map[string]any{"status": "ok", "recoveryCodes": codes})
+}
+
+func (h *AuthHandler) replaceRecoveryCodes(r *http.Request, userID int64) ([]string, error) {
+	codes, err := newRecoveryCodes(recoveryCodeCount)
+	if err != nil {
+		return nil, err
+	}
+	if err := h.Recovery.Replace(r.Context(), userID, codes); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// newRecoveryCodes коды вида xxxxx-xxxxx (50 бит случайности каждый)
+func newRecoveryCodes(n int) ([]string, error) {
+	enc := base32.StdEncoding.WithPadding(base32.NoPadding)
+	out := make([]string, 0, n)
+	b := make([]byte, 7)
+	for range n {
+		if _, err := rand.Read(b); err != nil {
+			return nil, err
+		}
+		c := strings.ToLower(enc.EncodeToString(b))[:10]
+		out = append(out, c[:5]+"-"+c[5:])
+	}
+	return out, nil
+}
@@ -0,0 +1,39 @@
+package middleware
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/icestormerrr/pz9-auth/internal/repo"
+)
+
+// RequireAdmin пропускает только пользователей из списка администраторов (по email).
+// С requireTwoFactor сессия администратора должна быть открыта со вторым фактором.
+// Должен стоять после RequireSession.
+func RequireAdmin(users *repo.UserRepo, emails []string, requireTwoFactor bool) func(http.Handler) http.Handler {
+	admins := make(map[string]bool, len(emails))
+	for _, e := range emails {
+		if e = strings.TrimSpace(strings.ToLower(e)); e != "" {
+			admins[e] = true
+		}
+	}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			s, ok := SessionFrom(r.Context())
+			if !ok {
+				writeErr(w, http.StatusUnauthorized, "unauthorized")
+				return
+			}
+			u, err := users.ByID(r.Context(), s.UserID)
+			if err != nil || !admins[u.Email] {
+				writeErr(w, http.StatusForbidden, "forbidden")
+				return
+			}
+			if requireTwoFactor && !s.TwoFactor {
+				writeErr(w, http.StatusForbidden, "2fa_required")
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,71 @@
+package middleware
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/icestormerrr/pz9-auth/internal/core"
+	"github.com/icestormerrr/pz9-auth/internal/repo"
+)
+
+const SessionCookie = "session"
+
+type ctxKey struct{}
+
+// SessionFrom сессия текущего запроса (доступна после RequireSession)
+func SessionFrom(ctx context.Context) (core.Session, bool) {
+	s, ok := ctx.Value(ctxKey{}).(core.Session)
+	return s, ok
+}
+
+// TokenFrom токен из заголовка Authorization: Bearer или из cookie
+func TokenFrom(r *http.Request) string {
+	if h := r.Header.Get("Authorization"); strings.HasPrefix(h, "Bearer ") {
+		return strings.TrimPrefix(h, "Bearer ")
+	}
+	if c, err := r.Cookie(SessionCookie); err == nil {
+		return c.Value
+	}
+	return ""
+}
+
+// RequireSession пропускает запрос только с действующей сессией и продлевает её простой
+func RequireSession(sessions *repo.SessionRepo, idle time.Duration) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			token := TokenFrom(r)
+			if token == "" {
+				writeErr(w, http.StatusUnauthorized, "unauthorized")
+				return
+			}
+			s, err := sessions.ByToken(r.Context(), token)
+			if err != nil {
+				writeErr(w, http.StatusUnauthorized, "unauthorized")
+				return
+			}
+
+			now := time.Now()
+			if !s.Active(now, idle) {
+				writeErr(w, http.StatusUnauthorized, "session_expired")
+				return
+			}
+			// не пишем в БД на каждый запрос: точности до минуты достаточно
+			if now.Sub(s.LastSeenAt) > time.Minute {
+				if err := sessions.Touch(r.Context(), s.ID, now); err == nil {
+					s.LastSeenAt = now
+				}
+			}
+
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), ctxKey{}, s)))
+		})
+	}
+}
+
+func writeErr(w http.ResponseWriter, code int, msg string) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(map[string]string{"error": msg})
+}
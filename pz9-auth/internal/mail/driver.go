@@ -0,0 +1,17 @@
+package mail
+
+import "fmt"
+
+// New выбирает реализацию Mailer по имени драйвера
+func New(driver, from, dir, smtpAddr, smtpUser, smtpPassword string) (Mailer, error) {
+	switch driver {
+	case "", "log":
+		return LogMailer{}, nil
+	case "file":
+		return &FileMailer{Dir: dir, From: from}, nil
+	case "smtp":
+		return &SMTPMailer{Addr: smtpAddr, From: from, Username: smtpUser, Password: smtpPassword}, nil
+	default:
+		return nil, fmt.Errorf("unknown mail driver %q", driver)
+	}
+}
@@ -0,0 +1,84 @@
+package mail
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/smtp"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer отправка писем; реализация выбирается конфигурацией (MAIL_DRIVER)
+type Mailer interface {
+	Send(ctx context.Context, m Message) error
+}
+
+func render(from string, m Message) []byte {
+	var b strings.Builder
+	fmt.Fprintf(&b, "From: %s\r\n", from)
+	fmt.Fprintf(&b, "To: %s\r\n", m.To)
+	fmt.Fprintf(&b, "Subject: %s\r\n", m.Subject)
+	fmt.Fprintf(&b, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	b.WriteString(strings.ReplaceAll(m.Body, "\n", "\r\n"))
+	return []byte(b.String())
+}
+
+// SMTPMailer отправляет письма через SMTP (подходит и для локального MailHog/Mailpit)
+type SMTPMailer struct {
+	Addr     string
+	From     string
+	Username string
+	Password string
+}
+
+func (s *SMTPMailer) Send(ctx context.Context, m Message) error {
+	var auth smtp.Auth
+	if s.Username != "" {
+		host := s.Addr
+		if i := strings.LastIndex(host, ":"); i >= 0 {
+			host = host[:i]
+		}
+		auth = smtp.PlainAuth("", s.Username, s.Password, host)
+	}
+	return smtp.SendMail(s.Addr, auth, s.From, []string{m.To}, render(s.From, m))
+}
+
+// LogMailer пишет письма в лог — удобно для разработки
+type LogMailer struct{}
+
+func (LogMailer) Send(ctx context.Context, m Message) error {
+	log.Printf("mail to=%s subject=%q\n%s", m.To, m.Subject, m.Body)
+	return nil
+}
+
+// FileMailer сохраняет каждое письмо в отдельный .eml файл в Dir
+type FileMailer struct {
+	Dir  string
+	From string
+
+	mu  sync.Mutex
+	seq int
+}
+
+func (f *FileMailer) Send(ctx context.Context, m Message) error {
+	if err := os.MkdirAll(f.Dir, 0o755); err != nil {
+		return err
+	}
+	f.mu.Lock()
+	f.seq++
+	name := fmt.Sprintf("%s-%03d.eml", time.Now().Format("20060102-150405"), f.seq)
+	f.mu.Unlock()
+	return os.WriteFile(filepath.Join(f.Dir, name), render(f.From, m), 0o644)
+}
@@ -0,0 +1,88 @@
+package password
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// BreachChecker проверяет пароль по базе утечек
+type BreachChecker interface {
+	Breached(ctx context.Context, pw string) (bool, error)
+}
+
+func sha1Hex(pw string) string {
+	sum := sha1.Sum([]byte(pw))
+	return strings.ToUpper(hex.EncodeToString(sum[:]))
+}
+
+// HIBPChecker k-anonymity запрос к Pwned Passwords: на сервер уходят только
+// первые 5 символов SHA-1, сравнение суффиксов происходит локально
+type HIBPChecker struct {
+	BaseURL string
+	Client  *http.Client
+}
+
+func NewHIBPChecker() *HIBPChecker {
+	return &HIBPChecker{
+		BaseURL: "https://api.pwnedpasswords.com/range/",
+		Client:  &http.Client{Timeout: 3 * time.Second},
+	}
+}
+
+func (c *HIBPChecker) Breached(ctx context.Context, pw string) (bool, error) {
+	h := sha1Hex(pw)
+	prefix, suffix := h[:5], h[5:]
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.BaseURL+prefix, nil)
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Add-Padding", "true")
+	resp, err := c.Client.Do(req)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return false, fmt.Errorf("pwned passwords: status %d", resp.StatusCode)
+	}
+
+	sc := bufio.NewScanner(resp.Body)
+	for sc.Scan() {
+		// строка ответа: SUFFIX:COUNT (с Add-Padding встречаются записи с COUNT=0)
+		s, count, ok := strings.Cut(strings.TrimSpace(sc.Text()), ":")
+		if ok && s == suffix && count != "0" {
+			return true, nil
+		}
+	}
+	return false, sc.Err()
+}
+
+// OfflineChecker заглушка без сети: пароль считается скомпрометированным,
+// если его SHA-1 есть в наборе (по умолчанию — встроенный список частых паролей)
+type OfflineChecker struct {
+	hashes map[string]bool
+}
+
+func NewOfflineChecker(passwords ...string) *OfflineChecker {
+	if len(passwords) == 0 {
+		for w := range common {
+			passwords = append(passwords, w)
+		}
+	}
+	c := &OfflineChecker{hashes: make(map[string]bool, len(passwords))}
+	for _, pw := range passwords {
+		c.hashes[sha1Hex(pw)] = true
+	}
+	return c
+}
+
+func (c *OfflineChecker) Breached(ctx context.Context, pw string) (bool, error) {
+	return c.hashes[sha1Hex(pw)], nil
+}
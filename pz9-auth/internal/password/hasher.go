@@ -0,0 +1,116 @@
+package password
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	Bcrypt   = "bcrypt"
+	Argon2id = "argon2id"
+)
+
+var ErrMismatch = errors.New("password mismatch")
+
+type Argon2Params struct {
+	MemoryKB uint32
+	Time     uint32
+	Threads  uint8
+	SaltLen  uint32
+	KeyLen   uint32
+}
+
+var DefaultArgon2 = Argon2Params{MemoryKB: 64 * 1024, Time: 3, Threads: 2, SaltLen: 16, KeyLen: 32}
+
+// Hasher хэширует пароли выбранным алгоритмом и проверяет хэши любого поддерживаемого формата.
+// Verify сообщает, что хэш устарел (другой алгоритм или параметры) и его стоит пересчитать.
+type Hasher struct {
+	Algorithm  string
+	BcryptCost int
+	Argon2     Argon2Params
+}
+
+func (h *Hasher) Hash(pw string) (string, error) {
+	switch h.Algorithm {
+	case Argon2id:
+		return h.hashArgon2(pw)
+	case Bcrypt, "":
+		b, err := bcrypt.GenerateFromPassword([]byte(pw), h.BcryptCost)
+		return string(b), err
+	default:
+		return "", fmt.Errorf("unknown hash algorithm %q", h.Algorithm)
+	}
+}
+
+func (h *Hasher) Verify(hash, pw string) (needsRehash bool, err error) {
+	if strings.HasPrefix(hash, "$argon2id$") {
+		p, salt, key, err := decodeArgon2(hash)
+		if err != nil {
+			return false, err
+		}
+		got := argon2.IDKey([]byte(pw), salt, p.Time, p.MemoryKB, p.Threads, uint32(len(key)))
+		if subtle.ConstantTimeCompare(got, key) != 1 {
+			return false, ErrMismatch
+		}
+		want := h.Argon2
+		return h.Algorithm != Argon2id || p.MemoryKB != want.MemoryKB || p.Time != want.Time || p.Threads != want.Threads, nil
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(pw)); err != nil {
+		if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+			return false, ErrMismatch
+		}
+		return false, err
+	}
+	cost, err := bcrypt.Cost([]byte(hash))
+	if err != nil {
+		return false, err
+	}
+	return (h.Algorithm != Bcrypt && h.Algorithm != "") || cost != h.BcryptCost, nil
+}
+
+// формат PHC: $argon2id$v=19$m=65536,t=3,p=2$<salt>$<hash>
+func (h *Hasher) hashArgon2(pw string) (string, error) {
+	p := h.Argon2
+	salt := make([]byte, p.SaltLen)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	key := argon2.IDKey([]byte(pw), salt, p.Time, p.MemoryKB, p.Threads, p.KeyLen)
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version, p.MemoryKB, p.Time, p.Threads,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key),
+	), nil
+}
+
+func decodeArgon2(hash string) (Argon2Params, []byte, []byte, error) {
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 {
+		return Argon2Params{}, nil, nil, errors.New("invalid argon2id hash")
+	}
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return Argon2Params{}, nil, nil, errors.New("unsupported argon2 version")
+	}
+	var p Argon2Params
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &p.MemoryKB, &p.Time, &p.Threads); err != nil {
+		return Argon2Params{}, nil, nil, errors.New("invalid argon2id params")
+	}
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return Argon2Params{}, nil, nil, err
+	}
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil {
+		return Argon2Params{}, nil, nil, err
+	}
+	return p, salt, key, nil
+}
@@ -0,0 +1,108 @@
+package password
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+var testArgon2 = Argon2Params{MemoryKB: 1024, Time: 1, Threads: 1, SaltLen: 16, KeyLen: 32}
+
+func TestHasherRehash(t *testing.T) {
+	old := &Hasher{Algorithm: Bcrypt, BcryptCost: bcrypt.MinCost}
+	hash, err := old.Hash("Correct-Horse-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if needs, err := old.Verify(hash, "Correct-Horse-1"); err != nil || needs {
+		t.Fatalf("same settings: needsRehash=%v err=%v", needs, err)
+	}
+	if _, err := old.Verify(hash, "wrong"); !errors.Is(err, ErrMismatch) {
+		t.Fatalf("want ErrMismatch got %v", err)
+	}
+
+	costlier := &Hasher{Algorithm: Bcrypt, BcryptCost: bcrypt.MinCost + 1}
+	if needs, _ := costlier.Verify(hash, "Correct-Horse-1"); !needs {
+		t.Fatal("cost change must require rehash")
+	}
+
+	argon := &Hasher{Algorithm: Argon2id, BcryptCost: bcrypt.MinCost, Argon2: testArgon2}
+	if needs, _ := argon.Verify(hash, "Correct-Horse-1"); !needs {
+		t.Fatal("algorithm change must require rehash")
+	}
+}
+
+func TestHasherArgon2id(t *testing.T) {
+	h := &Hasher{Algorithm: Argon2id, Argon2: testArgon2}
+	hash, err := h.Hash("Correct-Horse-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(hash, "$argon2id$v=19$m=1024,t=1,p=1$") {
+		t.Fatalf("unexpected hash format %s", hash)
+	}
+	if needs, err := h.Verify(hash, "Correct-Horse-1"); err != nil || needs {
+		t.Fatalf("needsRehash=%v err=%v", needs, err)
+	}
+	if _, err := h.Verify(hash, "wrong"); !errors.Is(err, ErrMismatch) {
+		t.Fatalf("want ErrMismatch got %v", err)
+	}
+
+	h.Argon2.Time = 2
+	if needs, _ := h.Verify(hash, "Correct-Horse-1"); !needs {
+		t.Fatal("params change must require rehash")
+	}
+}
+
+func TestPolicy(t *testing.T) {
+	p := DefaultPolicy
+	cases := map[string]string{
+		"short":         "Ab1",
+		"one class":     "abcdefghijk",
+		"common":        "Password123",
+		"contains mail": "Johnsmith-2024",
+	}
+	for name, pw := range cases {
+		if v := p.Validate(pw, "johnsmith@example.com"); len(v) == 0 {
+			t.Errorf("%s: %q must be rejected", name, pw)
+		}
+	}
+	if v := p.Validate("Tr0ub4dor&3x", "johnsmith@example.com"); len(v) != 0 {
+		t.Fatalf("strong password rejected: %v", v)
+	}
+}
+
+func TestOfflineChecker(t *testing.T) {
+	c := NewOfflineChecker()
+	if ok, _ := c.Breached(context.Background(), "qwerty123"); !ok {
+		t.Fatal("common password must be reported as breached")
+	}
+	if ok, _ := c.Breached(context.Background(), "Tr0ub4dor&3x"); ok {
+		t.Fatal("unexpected breach")
+	}
+}
+
+func TestHIBPCheckerSendsOnlyPrefix(t *testing.T) {
+	h := sha1Hex("hunter2")
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/range/"+h[:5] {
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+		fmt.Fprintf(w, "0000000000000000000000000000000000A:0\r\n%s:42\r\n", h[5:])
+	}))
+	defer srv.Close()
+
+	c := NewHIBPChecker()
+	c.BaseURL = srv.URL + "/range/"
+	ok, err := c.Breached(context.Background(), "hunter2")
+	if err != nil || !ok {
+		t.Fatalf("want breached, got %v %v", ok, err)
+	}
+}
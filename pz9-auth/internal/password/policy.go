@@ -0,0 +1,81 @@
+package password
+
+import (
+	"bufio"
+	_ "embed"
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+//go:embed common.txt
+var commonList string
+
+var common = func() map[string]bool {
+	m := map[string]bool{}
+	sc := bufio.NewScanner(strings.NewReader(commonList))
+	for sc.Scan() {
+		if w := strings.TrimSpace(sc.Text()); w != "" && !strings.HasPrefix(w, "#") {
+			m[strings.ToLower(w)] = true
+		}
+	}
+	return m
+}()
+
+// Policy требования к новому паролю
+type Policy struct {
+	MinLength int
+	MaxLength int
+	// сколько классов символов (строчные, заглавные, цифры, прочие) должно встречаться
+	MinClasses int
+}
+
+var DefaultPolicy = Policy{MinLength: 8, MaxLength: 72, MinClasses: 2}
+
+// Validate возвращает список нарушений (пустой, если пароль подходит)
+func (p Policy) Validate(pw, email string) []string {
+	var out []string
+	n := utf8.RuneCountInString(pw)
+	if n < p.MinLength {
+		out = append(out, fmt.Sprintf("min_length_%d", p.MinLength))
+	}
+	// bcrypt учитывает только первые 72 байта
+	if p.MaxLength > 0 && len(pw) > p.MaxLength {
+		out = append(out, fmt.Sprintf("max_length_%d", p.MaxLength))
+	}
+	if classes(pw) < p.MinClasses {
+		out = append(out, fmt.Sprintf("min_character_classes_%d", p.MinClasses))
+	}
+	lower := strings.ToLower(pw)
+	if common[lower] {
+		out = append(out, "too_common")
+	}
+	if local, _, ok := strings.Cut(strings.ToLower(email), "@"); ok && len(local) >= 3 && strings.Contains(lower, local) {
+		out = append(out, "contains_email")
+	}
+	return out
+}
+
+func classes(pw string) int {
+	var lower, upper, digit, other bool
+	for _, r := range pw {
+		switch {
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsDigit(r):
+			digit = true
+		default:
+			other = true
+		}
+	}
+	n := 0
+	for _, b := range []bool{lower, upper, digit, other} {
+		if b {
+			n++
+		}
+	}
+	return n
+}
@@ -3,12 +3,61 @@ package config
 import (
 	"os"
 	"strconv"
+	"strings"
+	"time"
 )
 
+// MinTokenSecretLength минимальная длина TOKEN_SECRET (256 бит для HMAC-SHA256)
+const MinTokenSecretLength = 32
+
 type Config struct {
 	DB_DSN     string
 	BcryptCost int
 	Addr       string
+
+	// bcrypt | argon2id — алгоритм для новых хэшей; старые пересчитываются при входе
+	HashAlgorithm  string
+	Argon2MemoryKB int
+	Argon2Time     int
+	Argon2Threads  int
+
+	PasswordMinLength  int
+	PasswordMinClasses int
+	// off | offline | hibp
+	BreachCheck string
+
+	// Секрет для подписи одноразовых токенов, не короче MinTokenSecretLength байт
+	TokenSecret              string
+	VerifyTokenTTL           time.Duration
+	VerifyResendInterval     time.Duration
+	ResetTokenTTL            time.Duration
+	RequireEmailVerification bool
+
+	SessionIdleTimeout     time.Duration
+	SessionAbsoluteTimeout time.Duration
+
+	// блокировка после LockoutThreshold неудачных входов подряд, срок удваивается до LockoutMax
+	LockoutThreshold   int
+	LockoutIPThreshold int
+	LockoutBase        time.Duration
+	LockoutMax         time.Duration
+	LockoutWindow      time.Duration
+
+	// email пользователей с доступом к /admin
+	AdminEmails []string
+	// доступ к /admin только из сессий, подтверждённых вторым фактором
+	AdminRequire2FA bool
+
+	TOTPIssuer            string
+	TwoFactorChallengeTTL time.Duration
+
+	// log | file | smtp
+	MailDriver   string
+	MailFrom     string
+	MailDir      string
+	SMTPAddr     string
+	SMTPUser     string
+	SMTPPassword string
 }
 
 func Load() Config {
@@ -30,5 +79,76 @@ func Load() Config {
 		DB_DSN:     os.Getenv("DB_DSN"),
 		BcryptCost: cost,
 		Addr:       addr,
+
+		HashAlgorithm:  getenv("HASH_ALGORITHM", "bcrypt"),
+		Argon2MemoryKB: getInt("ARGON2_MEMORY_KB", 64*1024),
+		Argon2Time:     getInt("ARGON2_TIME", 3),
+		Argon2Threads:  getInt("ARGON2_THREADS", 2),
+
+		PasswordMinLength:  getInt("PASSWORD_MIN_LENGTH", 8),
+		PasswordMinClasses: getInt("PASSWORD_MIN_CLASSES", 2),
+		BreachCheck:        getenv("BREACH_CHECK", "offline"),
+
+		TokenSecret:              os.Getenv("TOKEN_SECRET"),
+		VerifyTokenTTL:           getDuration("VERIFY_TOKEN_TTL", 24*time.Hour),
+		VerifyResendInterval:     getDuration("VERIFY_RESEND_INTERVAL", time.Minute),
+		ResetTokenTTL:            getDuration("RESET_TOKEN_TTL", time.Hour),
+		RequireEmailVerification: getBool("REQUIRE_EMAIL_VERIFICATION", true),
+
+		SessionIdleTimeout:     getDuration("SESSION_IDLE_TIMEOUT", 30*time.Minute),
+		SessionAbsoluteTimeout: getDuration("SESSION_ABSOLUTE_TIMEOUT", 24*time.Hour),
+
+		LockoutThreshold:   getInt("LOCKOUT_THRESHOLD", 5),
+		LockoutIPThreshold: getInt("LOCKOUT_IP_THRESHOLD", 20),
+		LockoutBase:        getDuration("LOCKOUT_BASE", time.Minute),
+		LockoutMax:         getDuration("LOCKOUT_MAX", time.Hour),
+		LockoutWindow:      getDuration("LOCKOUT_WINDOW", 15*time.Minute),
+
+		AdminEmails:     strings.Split(os.Getenv("ADMIN_EMAILS"), ","),
+		AdminRequire2FA: getBool("ADMIN_REQUIRE_2FA", true),
+
+		TOTPIssuer:            getenv("TOTP_ISSUER", "pz9-auth"),
+		TwoFactorChallengeTTL: getDuration("TWO_FACTOR_CHALLENGE_TTL", 5*time.Minute),
+
+		MailDriver:   getenv("MAIL_DRIVER", "log"),
+		MailFrom:     getenv("MAIL_FROM", "no-reply@localhost"),
+		MailDir:      getenv("MAIL_DIR", "mail"),
+		SMTPAddr:     getenv("SMTP_ADDR", "localhost:1025"),
+		SMTPUser:     os.Getenv("SMTP_USER"),
+		SMTPPassword: os.Getenv("SMTP_PASSWORD"),
+	}
+}
+
+func getenv(k, def string) string {
+	if v := os.Getenv(k); v != "" {
+		return v
+	}
+	return def
+}
+
+func getInt(k string, def int) int {
+	if v := os.Getenv(k); v != "" {
+		if n, err := strconv.Atoi(v); err == nil {
+			return n
+		}
+	}
+	return def
+}
+
+func getDuration(k string, def time.Duration) time.Duration {
+	if v := os.Getenv(k); v != "" {
+		if d, err := time.ParseDuration(v); err == nil {
+			return d
+		}
+	}
+	return def
+}
+
+func getBool(k string, def bool) bool {
+	if v := os.Getenv(k); v != "" {
+		if b, err := strconv.ParseBool(v); err == nil {
+			return b
+		}
 	}
+	return def
 }
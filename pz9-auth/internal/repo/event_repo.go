@@ -0,0 +1,68 @@
+package repo
+
+import (
+	"context"
+	"time"
+
+	"github.com/icestormerrr/pz9-auth/internal/core"
+	"gorm.io/gorm"
+)
+
+type EventRepo struct{ db *gorm.DB }
+
+func NewEventRepo(db *gorm.DB) *EventRepo { return &EventRepo{db: db} }
+
+func (r *EventRepo) AutoMigrate() error {
+	return r.db.AutoMigrate(&core.AuthEvent{})
+}
+
+func (r *EventRepo) Create(ctx context.Context, e *core.AuthEvent) error {
+	return r.db.WithContext(ctx).Create(e).Error
+}
+
+// EventFilter пустые поля не ограничивают выборку
+type EventFilter struct {
+	UserID  int64
+	Email   string
+	IP      string
+	Type    string
+	Success *bool
+	Since   time.Time
+	Until   time.Time
+	// записи с ID меньше BeforeID (для постраничного просмотра)
+	BeforeID int64
+	Limit    int
+}
+
+// List события от новых к старым
+func (r *EventRepo) List(ctx context.Context, f EventFilter) ([]core.AuthEvent, error) {
+	q := r.db.WithContext(ctx).Model(&core.AuthEvent{})
+	if f.UserID != 0 {
+		q = q.Where("user_id = ?", f.UserID)
+	}
+	if f.Email != "" {
+		q = q.Where("email = ?", f.Email)
+	}
+	if f.IP != "" {
+		q = q.Where("ip = ?", f.IP)
+	}
+	if f.Type != "" {
+		q = q.Where("type = ?", f.Type)
+	}
+	if f.Success != nil {
+		q = q.Where("success = ?", *f.Success)
+	}
+	if !f.Since.IsZero() {
+		q = q.Where("created_at >= ?", f.Since)
+	}
+	if !f.Until.IsZero() {
+		q = q.Where("created_at < ?", f.Until)
+	}
+	if f.BeforeID > 0 {
+		q = q.Where("id < ?", f.BeforeID)
+	}
+
+	out := []core.AuthEvent{}
+	err := q.Order("id DESC").Limit(f.Limit).Find(&out).Error
+	return out, err
+}
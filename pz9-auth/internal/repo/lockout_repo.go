@@ -0,0 +1,64 @@
+package repo
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/icestormerrr/pz9-auth/internal/core"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type LockoutRepo struct{ db *gorm.DB }
+
+func NewLockoutRepo(db *gorm.DB) *LockoutRepo { return &LockoutRepo{db: db} }
+
+func (r *LockoutRepo) AutoMigrate() error {
+	return r.db.AutoMigrate(&core.LoginFailure{})
+}
+
+// Get текущее состояние счётчика; для неизвестного ключа — нулевое значение
+func (r *LockoutRepo) Get(ctx context.Context, kind, key string) (core.LoginFailure, error) {
+	var f core.LoginFailure
+	err := r.db.WithContext(ctx).Where("kind = ? AND key = ?", kind, key).First(&f).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return core.LoginFailure{Kind: kind, Key: key}, nil
+	}
+	return f, err
+}
+
+// Fail атомарно увеличивает счётчик: строка блокируется на время транзакции,
+// поэтому параллельные попытки не теряют инкременты
+func (r *LockoutRepo) Fail(ctx context.Context, kind, key string, p core.LockoutPolicy) (core.LoginFailure, error) {
+	var f core.LoginFailure
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.OnConflict{DoNothing: true}).
+			Create(&core.LoginFailure{Kind: kind, Key: key}).Error; err != nil {
+			return err
+		}
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("kind = ? AND key = ?", kind, key).First(&f).Error; err != nil {
+			return err
+		}
+		p.Fail(&f, time.Now())
+		return tx.Save(&f).Error
+	})
+	return f, err
+}
+
+// Reset снимает блокировку и обнуляет счётчик; возвращает false, если записи не было
+func (r *LockoutRepo) Reset(ctx context.Context, kind, key string) (bool, error) {
+	res := r.db.WithContext(ctx).Where("kind = ? AND key = ?", kind, key).Delete(&core.LoginFailure{})
+	return res.RowsAffected > 0, res.Error
+}
+
+// Locked действующие блокировки, ближайшие к окончанию — последними
+func (r *LockoutRepo) Locked(ctx context.Context) ([]core.LoginFailure, error) {
+	out := []core.LoginFailure{}
+	err := r.db.WithContext(ctx).
+		Where("locked_until > ?", time.Now()).
+		Order("locked_until DESC").
+		Find(&out).Error
+	return out, err
+}
@@ -0,0 +1,69 @@
+package repo
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/icestormerrr/pz9-auth/internal/core"
+	"gorm.io/gorm"
+)
+
+var ErrRecoveryCodeInvalid = errors.New("recovery code invalid or used")
+
+type RecoveryRepo struct{ db *gorm.DB }
+
+func NewRecoveryRepo(db *gorm.DB) *RecoveryRepo { return &RecoveryRepo{db: db} }
+
+func (r *RecoveryRepo) AutoMigrate() error {
+	return r.db.AutoMigrate(&core.RecoveryCode{})
+}
+
+// HashRecoveryCode хэш без учёта регистра, дефисов и пробелов
+func HashRecoveryCode(code string) string {
+	code = strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(code))
+	sum := sha256.Sum256([]byte(code))
+	return hex.EncodeToString(sum[:])
+}
+
+// Replace заменяет все коды пользователя новым набором
+func (r *RecoveryRepo) Replace(ctx context.Context, userID int64, codes []string) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", userID).Delete(&core.RecoveryCode{}).Error; err != nil {
+			return err
+		}
+		recs := make([]core.RecoveryCode, 0, len(codes))
+		for _, c := range codes {
+			recs = append(recs, core.RecoveryCode{UserID: userID, CodeHash: HashRecoveryCode(c)})
+		}
+		return tx.Create(&recs).Error
+	})
+}
+
+// Use гасит код; повторное использование невозможно
+func (r *RecoveryRepo) Use(ctx context.Context, userID int64, code string) error {
+	res := r.db.WithContext(ctx).Model(&core.RecoveryCode{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", userID, HashRecoveryCode(code)).
+		Update("used_at", time.Now())
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrRecoveryCodeInvalid
+	}
+	return nil
+}
+
+func (r *RecoveryRepo) Remaining(ctx context.Context, userID int64) (int64, error) {
+	var n int64
+	err := r.db.WithContext(ctx).Model(&core.RecoveryCode{}).
+		Where("user_id = ? AND used_at IS NULL", userID).Count(&n).Error
+	return n, err
+}
+
+func (r *RecoveryRepo) DeleteAll(ctx context.Context, userID int64) error {
+	return r.db.WithContext(ctx).Where("user_id = ?", userID).Delete(&core.RecoveryCode{}).Error
+}
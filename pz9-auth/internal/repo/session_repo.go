@@ -0,0 +1,94 @@
+package repo
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"github.com/icestormerrr/pz9-auth/internal/core"
+	"gorm.io/gorm"
+)
+
+var ErrSessionNotFound = errors.New("session not found")
+
+type SessionRepo struct{ db *gorm.DB }
+
+func NewSessionRepo(db *gorm.DB) *SessionRepo { return &SessionRepo{db: db} }
+
+func (r *SessionRepo) AutoMigrate() error {
+	return r.db.AutoMigrate(&core.Session{})
+}
+
+func HashSessionToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// Create создаёт сессию и возвращает непрозрачный токен (показывается клиенту один раз)
+func (r *SessionRepo) Create(ctx context.Context, s *core.Session) (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	token := base64.RawURLEncoding.EncodeToString(b)
+	s.TokenHash = HashSessionToken(token)
+	if err := r.db.WithContext(ctx).Create(s).Error; err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+func (r *SessionRepo) ByToken(ctx context.Context, token string) (core.Session, error) {
+	var s core.Session
+	err := r.db.WithContext(ctx).Where("token_hash = ? AND revoked_at IS NULL", HashSessionToken(token)).First(&s).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return core.Session{}, ErrSessionNotFound
+	}
+	return s, err
+}
+
+func (r *SessionRepo) Touch(ctx context.Context, id int64, at time.Time) error {
+	return r.db.WithContext(ctx).Model(&core.Session{}).Where("id = ?", id).Update("last_seen_at", at).Error
+}
+
+// ActiveByUser сессии пользователя, не отозванные и не истёкшие
+func (r *SessionRepo) ActiveByUser(ctx context.Context, userID int64, idle time.Duration) ([]core.Session, error) {
+	now := time.Now()
+	var out []core.Session
+	err := r.db.WithContext(ctx).
+		Where("user_id = ? AND revoked_at IS NULL AND expires_at > ? AND last_seen_at > ?", userID, now, now.Add(-idle)).
+		Order("last_seen_at DESC").
+		Find(&out).Error
+	return out, err
+}
+
+// Revoke отзывает сессию, только если она принадлежит userID
+func (r *SessionRepo) Revoke(ctx context.Context, userID, id int64) error {
+	res := r.db.WithContext(ctx).Model(&core.Session{}).
+		Where("id = ? AND user_id = ? AND revoked_at IS NULL", id, userID).
+		Update("revoked_at", time.Now())
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrSessionNotFound
+	}
+	return nil
+}
+
+// RevokeAll отзывает все сессии пользователя, кроме exceptID (0 — без исключений)
+func (r *SessionRepo) RevokeAll(ctx context.Context, userID, exceptID int64) (int64, error) {
+	res := r.db.WithContext(ctx).Model(&core.Session{}).
+		Where("user_id = ? AND id <> ? AND revoked_at IS NULL", userID, exceptID).
+		Update("revoked_at", time.Now())
+	return res.RowsAffected, res.Error
+}
+
+// MarkTwoFactor отмечает сессию как подтверждённую вторым фактором
+func (r *SessionRepo) MarkTwoFactor(ctx context.Context, id int64) error {
+	return r.db.WithContext(ctx).Model(&core.Session{}).Where("id = ?", id).Update("two_factor", true).Error
+}
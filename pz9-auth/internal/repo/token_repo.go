@@ -0,0 +1,54 @@
+package repo
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/icestormerrr/pz9-auth/internal/core"
+	"gorm.io/gorm"
+)
+
+var ErrTokenUsed = errors.New("token already used or revoked")
+
+type TokenRepo struct{ db *gorm.DB }
+
+func NewTokenRepo(db *gorm.DB) *TokenRepo { return &TokenRepo{db: db} }
+
+func (r *TokenRepo) AutoMigrate() error {
+	return r.db.AutoMigrate(&core.OneTimeToken{})
+}
+
+func (r *TokenRepo) Create(ctx context.Context, t *core.OneTimeToken) error {
+	return r.db.WithContext(ctx).Create(t).Error
+}
+
+// Consume атомарно помечает токен использованным; повторное использование вернёт ErrTokenUsed.
+// userID из claims должен совпадать с владельцем токена, иначе nonce своего токена можно подставить в чужие claims.
+func (r *TokenRepo) Consume(ctx context.Context, purpose string, userID int64, nonceHash string) error {
+	res := r.db.WithContext(ctx).Model(&core.OneTimeToken{}).
+		Where("nonce_hash = ? AND purpose = ? AND user_id = ? AND used_at IS NULL AND expires_at > ?", nonceHash, purpose, userID, time.Now()).
+		Update("used_at", time.Now())
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrTokenUsed
+	}
+	return nil
+}
+
+// RevokeAll отзывает все неиспользованные токены пользователя с данным назначением
+func (r *TokenRepo) RevokeAll(ctx context.Context, userID int64, purpose string) error {
+	return r.db.WithContext(ctx).Model(&core.OneTimeToken{}).
+		Where("user_id = ? AND purpose = ? AND used_at IS NULL", userID, purpose).
+		Update("used_at", time.Now()).Error
+}
+
+// LastIssuedAt время выпуска последнего токена пользователя с данным назначением; нулевое, если токенов не было
+func (r *TokenRepo) LastIssuedAt(ctx context.Context, userID int64, purpose string) (time.Time, error) {
+	var t core.OneTimeToken
+	err := r.db.WithContext(ctx).Where("user_id = ? AND purpose = ?", userID, purpose).
+		Order("created_at DESC").Limit(1).Find(&t).Error
+	return t.CreatedAt, err
+}
@@ -3,6 +3,7 @@ package repo
 import (
 	"context"
 	"errors"
+	"time"
 
 	"github.com/icestormerrr/pz9-auth/internal/core"
 	"github.com/jackc/pgx/v5/pgconn"
@@ -17,7 +18,16 @@ type UserRepo struct{ db *gorm.DB }
 func NewUserRepo(db *gorm.DB) *UserRepo { return &UserRepo{db: db} }
 
 func (r *UserRepo) AutoMigrate() error {
-	return r.db.AutoMigrate(&core.User{})
+	backfill := r.db.Migrator().HasTable(&core.User{}) && !r.db.Migrator().HasColumn(&core.User{}, "EmailVerifiedAt")
+	if err := r.db.AutoMigrate(&core.User{}); err != nil {
+		return err
+	}
+	if backfill {
+		// пользователи, зарегистрированные до появления подтверждения email, считаются подтверждёнными
+		return r.db.Model(&core.User{}).Where("email_verified_at IS NULL").
+			Update("email_verified_at", gorm.Expr("created_at")).Error
+	}
+	return nil
 }
 
 func (r *UserRepo) Create(ctx context.Context, u *core.User) error {
@@ -31,6 +41,33 @@ func (r *UserRepo) Create(ctx context.Context, u *core.User) error {
 	return nil
 }
 
+func (r *UserRepo) ByID(ctx context.Context, id int64) (core.User, error) {
+	var u core.User
+	err := r.db.WithContext(ctx).First(&u, id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return core.User{}, ErrUserNotFound
+	}
+	return u, err
+}
+
+func (r *UserRepo) MarkEmailVerified(ctx context.Context, id int64) error {
+	res := r.db.WithContext(ctx).Model(&core.User{}).
+		Where("id = ? AND email_verified_at IS NULL", id).
+		Update("email_verified_at", time.Now())
+	return res.Error
+}
+
+func (r *UserRepo) UpdatePassword(ctx context.Context, id int64, hash string) error {
+	res := r.db.WithContext(ctx).Model(&core.User{}).Where("id = ?", id).Update("password_hash", hash)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrUserNotFound
+	}
+	return nil
+}
+
 func (r *UserRepo) ByEmail(ctx context.Context, email string) (core.User, error) {
 	var u core.User
 	err := r.db.WithContext(ctx).Where("email = ?", email).First(&u).Error
@@ -39,3 +76,54 @@ func (r *UserRepo) ByEmail(ctx context.Context, email string) (core.User, error)
 	}
 	return u, err
 }
+
+var ErrTwoFactorEnabled = errors.New("two-factor authentication already enabled")
+var ErrCodeReused = errors.New("totp code already used")
+
+// SetTOTPSecret сохраняет новый (ещё не подтверждённый) секрет; включённую 2FA не трогает
+func (r *UserRepo) SetTOTPSecret(ctx context.Context, id int64, secret string) error {
+	res := r.db.WithContext(ctx).Model(&core.User{}).
+		Where("id = ? AND two_factor_enabled_at IS NULL", id).
+		Updates(map[string]any{"totp_secret": secret, "totp_last_step": 0})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrTwoFactorEnabled
+	}
+	return nil
+}
+
+// EnableTwoFactor включает 2FA и запоминает шаг кода, которым она подтверждена
+func (r *UserRepo) EnableTwoFactor(ctx context.Context, id, step int64) error {
+	res := r.db.WithContext(ctx).Model(&core.User{}).
+		Where("id = ? AND two_factor_enabled_at IS NULL AND totp_secret <> ''", id).
+		Updates(map[string]any{"two_factor_enabled_at": time.Now(), "totp_last_step": step})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrTwoFactorEnabled
+	}
+	return nil
+}
+
+func (r *UserRepo) DisableTwoFactor(ctx context.Context, id int64) error {
+	return r.db.WithContext(ctx).Model(&core.User{}).Where("id = ?", id).
+		Updates(map[string]any{"totp_secret": "", "two_factor_enabled_at": nil, "totp_last_step": 0}).Error
+}
+
+// UseTOTPStep атомарно отмечает шаг как использованный; при гонке двух запросов
+// с одним кодом успешен только первый
+func (r *UserRepo) UseTOTPStep(ctx context.Context, id, step int64) error {
+	res := r.db.WithContext(ctx).Model(&core.User{}).
+		Where("id = ? AND totp_last_step < ?", id, step).
+		Update("totp_last_step", step)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrCodeReused
+	}
+	return nil
+}
@@ -0,0 +1,97 @@
+package tokens
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+)
+
+const (
+	PurposeVerifyEmail   = "verify_email"
+	PurposeResetPassword = "reset_password"
+	PurposeTwoFactor     = "2fa_login"
+)
+
+var (
+	ErrInvalid = errors.New("invalid token")
+	ErrExpired = errors.New("token expired")
+)
+
+type Claims struct {
+	Purpose   string `json:"p"`
+	UserID    int64  `json:"u"`
+	Nonce     string `json:"n"`
+	ExpiresAt int64  `json:"e"`
+}
+
+// Signer выпускает одноразовые токены вида base64(payload).base64(hmac).
+// Подпись защищает от подделки, а однократность обеспечивается записью nonce в БД.
+type Signer struct {
+	secret []byte
+	now    func() time.Time
+}
+
+func NewSigner(secret string) *Signer {
+	return &Signer{secret: []byte(secret), now: time.Now}
+}
+
+func (s *Signer) Issue(purpose string, userID int64, ttl time.Duration) (string, Claims, error) {
+	nonce := make([]byte, 16)
+	if _, err := rand.Read(nonce); err != nil {
+		return "", Claims{}, err
+	}
+	c := Claims{
+		Purpose:   purpose,
+		UserID:    userID,
+		Nonce:     hex.EncodeToString(nonce),
+		ExpiresAt: s.now().Add(ttl).Unix(),
+	}
+	payload, err := json.Marshal(c)
+	if err != nil {
+		return "", Claims{}, err
+	}
+	p := base64.RawURLEncoding.EncodeToString(payload)
+	return p + "." + base64.RawURLEncoding.EncodeToString(s.sign(p)), c, nil
+}
+
+// Parse проверяет подпись, назначение и срок действия токена
+func (s *Signer) Parse(token, purpose string) (Claims, error) {
+	p, sig, ok := strings.Cut(token, ".")
+	if !ok {
+		return Claims{}, ErrInvalid
+	}
+	got, err := base64.RawURLEncoding.DecodeString(sig)
+	if err != nil || !hmac.Equal(got, s.sign(p)) {
+		return Claims{}, ErrInvalid
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(p)
+	if err != nil {
+		return Claims{}, ErrInvalid
+	}
+	var c Claims
+	if err := json.Unmarshal(payload, &c); err != nil || c.Purpose != purpose {
+		return Claims{}, ErrInvalid
+	}
+	if s.now().Unix() >= c.ExpiresAt {
+		return Claims{}, ErrExpired
+	}
+	return c, nil
+}
+
+func (s *Signer) sign(payload string) []byte {
+	m := hmac.New(sha256.New, s.secret)
+	m.Write([]byte(payload))
+	return m.Sum(nil)
+}
+
+// HashNonce значение для хранения в БД: сам nonce не сохраняется
+func HashNonce(nonce string) string {
+	sum := sha256.Sum256([]byte(nonce))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,42 @@
+package tokens
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestIssueAndParse(t *testing.T) {
+	s := NewSigner("secret")
+	token, issued, err := s.Issue(PurposeVerifyEmail, 7, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := s.Parse(token, PurposeVerifyEmail)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != issued {
+		t.Fatalf("want %+v got %+v", issued, got)
+	}
+
+	if _, err := s.Parse(token, PurposeResetPassword); !errors.Is(err, ErrInvalid) {
+		t.Fatalf("token must be bound to purpose, got %v", err)
+	}
+	if _, err := NewSigner("other").Parse(token, PurposeVerifyEmail); !errors.Is(err, ErrInvalid) {
+		t.Fatalf("token signed with another secret must be rejected, got %v", err)
+	}
+}
+
+func TestParseExpired(t *testing.T) {
+	s := NewSigner("secret")
+	token, _, err := s.Issue(PurposeResetPassword, 1, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.now = func() time.Time { return time.Now().Add(2 * time.Minute) }
+	if _, err := s.Parse(token, PurposeResetPassword); !errors.Is(err, ErrExpired) {
+		t.Fatalf("want ErrExpired got %v", err)
+	}
+}
@@ -0,0 +1,64 @@
+package totp
+
+import (
+	"encoding/base32"
+	"strings"
+	"testing"
+	"time"
+)
+
+// тестовые векторы RFC 6238 (приложение B, SHA1), последние 6 цифр
+func TestCodeRFC6238(t *testing.T) {
+	secret := base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString([]byte("12345678901234567890"))
+	cases := map[int64]string{
+		59:          "287082",
+		1111111109:  "081804",
+		1111111111:  "050471",
+		1234567890:  "005924",
+		2000000000:  "279037",
+		20000000000: "353130",
+	}
+	for ts, want := range cases {
+		got, err := Code(secret, Step(time.Unix(ts, 0)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != want {
+			t.Errorf("t=%d: want %s got %s", ts, want, got)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	secret, err := GenerateSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	prev, _ := Code(secret, Step(now)-1)
+
+	step, ok := Validate(secret, prev, now, 1, 0)
+	if !ok || step != Step(now)-1 {
+		t.Fatalf("code from previous window must be accepted, got %d %v", step, ok)
+	}
+	if _, ok := Validate(secret, prev, now, 0, 0); ok {
+		t.Fatal("code outside skew accepted")
+	}
+	if _, ok := Validate(secret, prev, now, 1, step); ok {
+		t.Fatal("replayed code accepted")
+	}
+	if _, ok := Validate(secret, "12345", now, 1, 0); ok {
+		t.Fatal("short code accepted")
+	}
+}
+
+func TestURI(t *testing.T) {
+	uri := URI("pz9-auth", "user@example.com", "JBSWY3DPEHPK3PXP")
+	if !strings.HasPrefix(uri, "otpauth://totp/pz9-auth:user@example.com?") || !strings.Contains(uri, "secret=JBSWY3DPEHPK3PXP") {
+		t.Fatalf("unexpected uri %s", uri)
+	}
+	png, err := QRCode(uri, 256)
+	if err != nil || len(png) == 0 {
+		t.Fatalf("qr: %v", err)
+	}
+}